@@ -3,8 +3,10 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"time"
 
@@ -17,6 +19,8 @@ import (
 type APIGateway struct {
 	router   *mux.Router
 	upgrader websocket.Upgrader
+	nodeURL  string // Base URL of the blockchain node RPC
+	client   *http.Client
 }
 
 // Response structures
@@ -58,9 +62,16 @@ type TransactionInfo struct {
 
 func NewAPIGateway() *APIGateway {
 	router := mux.NewRouter()
+
+	nodeURL := os.Getenv("NODE_RPC_URL")
+	if nodeURL == "" {
+		nodeURL = "http://localhost:8545"
+	}
 	
 	api := &APIGateway{
-		router: router,
+		router:  router,
+		nodeURL: nodeURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins (configure properly in production)
@@ -84,11 +95,19 @@ func (api *APIGateway) setupRoutes() {
 	v1.HandleFunc("/blockchain/block/{number}", api.getBlock).Methods("GET")
 	v1.HandleFunc("/blockchain/latest-blocks", api.getLatestBlocks).Methods("GET")
 	v1.HandleFunc("/blockchain/stats", api.getBlockchainStats).Methods("GET")
+	v1.HandleFunc("/blockchain/supply", api.getSupply).Methods("GET")
+	v1.HandleFunc("/blockchain/logs", api.getLogs).Methods("GET")
+	v1.HandleFunc("/metrics/blocks", api.getBlockMetrics).Methods("GET")
+	v1.HandleFunc("/metrics/cache", api.getCacheMetrics).Methods("GET")
+	v1.HandleFunc("/search/{hash}", api.search).Methods("GET")
 
 	// Transaction endpoints
+	v1.HandleFunc("/transaction/cancel", api.getCancelTransaction).Methods("GET")
 	v1.HandleFunc("/transaction/{hash}", api.getTransaction).Methods("GET")
+	v1.HandleFunc("/transaction/{hash}/receipt", api.getTransactionReceipt).Methods("GET")
 	v1.HandleFunc("/transaction/send", api.sendTransaction).Methods("POST")
 	v1.HandleFunc("/transaction/pending", api.getPendingTransactions).Methods("GET")
+	v1.HandleFunc("/mempool/events", api.getMempoolEvents).Methods("GET")
 
 	// Validator endpoints
 	v1.HandleFunc("/validators", api.getValidators).Methods("GET")
@@ -112,6 +131,16 @@ func (api *APIGateway) setupRoutes() {
 	v1.HandleFunc("/account/{address}/balance", api.getBalance).Methods("GET")
 	v1.HandleFunc("/account/{address}/transactions", api.getAccountTransactions).Methods("GET")
 	v1.HandleFunc("/account/{address}/nonce", api.getNonce).Methods("GET")
+	v1.HandleFunc("/account/{address}/timelocks", api.getTimeLocks).Methods("GET")
+	v1.HandleFunc("/account/{address}/assets", api.getAssetBalances).Methods("GET")
+	v1.HandleFunc("/account/{address}/escrows", api.getAccountEscrows).Methods("GET")
+
+	// Escrow endpoints
+	v1.HandleFunc("/escrow/{id}", api.getEscrow).Methods("GET")
+
+	// Native assets
+	v1.HandleFunc("/assets", api.getAssets).Methods("GET")
+	v1.HandleFunc("/assets/allowance", api.getAllowance).Methods("GET")
 
 	// Network endpoints
 	v1.HandleFunc("/network/peers", api.getNetworkPeers).Methods("GET")
@@ -125,6 +154,8 @@ func (api *APIGateway) setupRoutes() {
 	admin.HandleFunc("/pause", api.pauseContract).Methods("POST")
 	admin.HandleFunc("/unpause", api.unpauseContract).Methods("POST")
 	admin.HandleFunc("/emergency-withdraw", api.emergencyWithdraw).Methods("POST")
+	admin.HandleFunc("/frozen-accounts", api.getFrozenAccounts).Methods("GET")
+	admin.HandleFunc("/pause-status", api.getPauseStatus).Methods("GET")
 }
 
 // Health check endpoint
@@ -201,6 +232,45 @@ func (api *APIGateway) getBlockchainStats(w http.ResponseWriter, r *http.Request
 	api.sendSuccess(w, stats)
 }
 
+// Get supply breakdown (total, circulating, staked, burned, locked)
+// Accepts an optional ?height= query parameter
+func (api *APIGateway) getSupply(w http.ResponseWriter, r *http.Request) {
+	api.proxyToNode(w, r)
+}
+
+// Get logs filtered by block range, address and topics
+// Accepts from_block, to_block, address (repeatable) and topic0..topic3
+func (api *APIGateway) getLogs(w http.ResponseWriter, r *http.Request) {
+	api.forwardToNode(w, r, "/api/v1/logs")
+}
+
+// Get the execution receipt of a transaction
+func (api *APIGateway) getTransactionReceipt(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	r.URL.RawQuery = url.Values{"hash": {vars["hash"]}}.Encode()
+	api.forwardToNode(w, r, "/api/v1/transaction/receipt")
+}
+
+// Get the unsigned cancel transaction for a pending ?from=&nonce=
+func (api *APIGateway) getCancelTransaction(w http.ResponseWriter, r *http.Request) {
+	api.forwardToNode(w, r, "/api/v1/transaction/cancel")
+}
+
+// Get transactions recently replaced or dropped from the mempool
+func (api *APIGateway) getMempoolEvents(w http.ResponseWriter, r *http.Request) {
+	api.proxyToNode(w, r)
+}
+
+// Get block pipeline latency histograms
+func (api *APIGateway) getBlockMetrics(w http.ResponseWriter, r *http.Request) {
+	api.proxyToNode(w, r)
+}
+
+// Get storage cache hit and miss counters
+func (api *APIGateway) getCacheMetrics(w http.ResponseWriter, r *http.Request) {
+	api.proxyToNode(w, r)
+}
+
 // Get transaction by hash
 func (api *APIGateway) getTransaction(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -365,19 +435,11 @@ func (api *APIGateway) claimTokens(w http.ResponseWriter, r *http.Request) {
 	api.sendSuccess(w, map[string]string{"status": "success"})
 }
 
-// Get vesting schedule
+// Get the vesting schedule of a presale or team allocation
 func (api *APIGateway) getVestingSchedule(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	address := vars["address"]
-
-	schedule := map[string]interface{}{
-		"address":         address,
-		"total_amount":    "1000000000000000000000000",
-		"released_amount": "300000000000000000000000",
-		"vesting_end":     time.Now().Add(180 * 24 * time.Hour).Unix(),
-	}
-
-	api.sendSuccess(w, schedule)
+	r.URL.RawQuery = url.Values{"address": {vars["address"]}}.Encode()
+	api.forwardToNode(w, r, "/api/v1/vesting")
 }
 
 // Get account balance
@@ -393,9 +455,13 @@ func (api *APIGateway) getBalance(w http.ResponseWriter, r *http.Request) {
 	api.sendSuccess(w, balance)
 }
 
-// Get account transactions
+// Get account transactions, paginated with ?cursor=, ?limit= and ?order=
 func (api *APIGateway) getAccountTransactions(w http.ResponseWriter, r *http.Request) {
-	api.sendSuccess(w, []map[string]interface{}{})
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+	query.Set("address", vars["address"])
+	r.URL.RawQuery = query.Encode()
+	api.forwardToNode(w, r, "/api/v1/account/transactions")
 }
 
 // Get account nonce
@@ -403,6 +469,51 @@ func (api *APIGateway) getNonce(w http.ResponseWriter, r *http.Request) {
 	api.sendSuccess(w, map[string]interface{}{"nonce": 0})
 }
 
+// Get pending time-locks sent from or to an address
+func (api *APIGateway) getTimeLocks(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	r.URL.RawQuery = url.Values{"address": {vars["address"]}}.Encode()
+	api.forwardToNode(w, r, "/api/v1/timelocks")
+}
+
+// Get per-asset balances of an address
+func (api *APIGateway) getAssetBalances(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	r.URL.RawQuery = url.Values{"address": {vars["address"]}}.Encode()
+	api.forwardToNode(w, r, "/api/v1/assets/balances")
+}
+
+// Get escrows an address is party to (depositor, beneficiary or arbiter)
+func (api *APIGateway) getAccountEscrows(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	r.URL.RawQuery = url.Values{"address": {vars["address"]}}.Encode()
+	api.forwardToNode(w, r, "/api/v1/escrows")
+}
+
+// Resolve a block or transaction hash
+func (api *APIGateway) search(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	r.URL.RawQuery = url.Values{"hash": {vars["hash"]}}.Encode()
+	api.forwardToNode(w, r, "/api/v1/search")
+}
+
+// Get an escrow by ID
+func (api *APIGateway) getEscrow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	r.URL.RawQuery = url.Values{"id": {vars["id"]}}.Encode()
+	api.forwardToNode(w, r, "/api/v1/escrows")
+}
+
+// Get native assets, or one asset with ?symbol=
+func (api *APIGateway) getAssets(w http.ResponseWriter, r *http.Request) {
+	api.proxyToNode(w, r)
+}
+
+// Get an asset allowance (?symbol=&owner=&spender=)
+func (api *APIGateway) getAllowance(w http.ResponseWriter, r *http.Request) {
+	api.proxyToNode(w, r)
+}
+
 // Get network peers
 func (api *APIGateway) getNetworkPeers(w http.ResponseWriter, r *http.Request) {
 	peers := []map[string]interface{}{
@@ -450,19 +561,66 @@ func (api *APIGateway) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 }
 
 // Admin endpoints
+
+// pauseContract submits a multi-sig pause transaction signed by super admins.
+// The chain refuses user transactions once it executes.
 func (api *APIGateway) pauseContract(w http.ResponseWriter, r *http.Request) {
-	api.sendSuccess(w, map[string]string{"status": "paused"})
+	api.forwardToNode(w, r, "/api/v1/transaction/send")
 }
 
+// unpauseContract submits a multi-sig resume transaction, optionally with
+// a resume_height for an automatic unpause
 func (api *APIGateway) unpauseContract(w http.ResponseWriter, r *http.Request) {
-	api.sendSuccess(w, map[string]string{"status": "unpaused"})
+	api.forwardToNode(w, r, "/api/v1/transaction/send")
+}
+
+// Get chain-wide pause state
+func (api *APIGateway) getPauseStatus(w http.ResponseWriter, r *http.Request) {
+	api.proxyToNode(w, r)
 }
 
 func (api *APIGateway) emergencyWithdraw(w http.ResponseWriter, r *http.Request) {
 	api.sendSuccess(w, map[string]string{"status": "withdrawn"})
 }
 
+// Get accounts frozen on-chain (freeze_user_wallet audit trail)
+func (api *APIGateway) getFrozenAccounts(w http.ResponseWriter, r *http.Request) {
+	api.proxyToNode(w, r)
+}
+
 // Helper functions
+
+// proxyToNode forwards a request to the same path on the blockchain node RPC
+func (api *APIGateway) proxyToNode(w http.ResponseWriter, r *http.Request) {
+	api.forwardToNode(w, r, r.URL.Path)
+}
+
+// forwardToNode forwards a request to the given path on the blockchain node RPC
+func (api *APIGateway) forwardToNode(w http.ResponseWriter, r *http.Request, path string) {
+	url := api.nodeURL + path
+	if r.URL.RawQuery != "" {
+		url += "?" + r.URL.RawQuery
+	}
+
+	req, err := http.NewRequest(r.Method, url, r.Body)
+	if err != nil {
+		api.sendError(w, http.StatusInternalServerError, "Failed to build node request")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := api.client.Do(req)
+	if err != nil {
+		api.sendError(w, http.StatusBadGateway, "Blockchain node unavailable")
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
 func (api *APIGateway) sendJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteStatus(status)
@@ -21,6 +21,7 @@ type SmartGateway struct {
 	mu           sync.RWMutex
 	blockchain   BlockchainInterface
 	authSystem   *AuthorizationSystem
+	sponsor      *FeeSponsor // Optional fee payer for onboarding requests
 }
 
 // GatewayRequest represents a request passing through the gateway
@@ -163,13 +164,14 @@ func (sg *SmartGateway) isBlockchainAction(action string) bool {
 // executeOnBlockchain - Execute validated request on blockchain
 func (sg *SmartGateway) executeOnBlockchain(req *GatewayRequest) error {
 	// Convert request to blockchain transaction
-	tx := map[string]interface{}{
-		"type":      req.Action,
-		"from":      req.UserID,
-		"data":      req.Payload,
-		"hash":      req.Hash,
-		"timestamp": req.CreatedAt.Unix(),
+	tx, err := chainTransaction(req)
+	if err != nil {
+		return err
 	}
+	tx.Hash = tx.CanonicalHash()
+	
+	// New users have no VNC for gas: let the configured sponsor pay
+	sg.sponsorTransaction(tx, req)
 	
 	return sg.blockchain.SubmitTransaction(tx)
 }
@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Fee Sponsorship - Meta-transactions for onboarding
+// New users (e.g. presale buyers) hold no VNC for gas, so the gateway can
+// attach a sponsor as fee payer. The chain charges the fee to the sponsor
+// if its on-chain sponsor policy accepts the sender and action.
+
+// FeeSponsor is an account that pays gas for matching gateway requests
+type FeeSponsor struct {
+	Key    ed25519.PrivateKey // the sponsor account's key; its address is derived from it
+	Target PolicyTarget       // roles and actions the gateway sponsors ("*" matches any)
+}
+
+// Address - The sponsor's account address, as the node derives it from the key
+func (fs *FeeSponsor) Address() string {
+	hash := sha256.Sum256(fs.Key.Public().(ed25519.PublicKey))
+	return "0x" + hex.EncodeToString(hash[:20])
+}
+
+// SetFeeSponsor - Configure the sponsor used for onboarding requests (nil disables)
+func (sg *SmartGateway) SetFeeSponsor(sponsor *FeeSponsor) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	sg.sponsor = sponsor
+}
+
+// sponsorTransaction - Attach the fee payer and its signature when the
+// request qualifies. The fee payer is part of the transaction hash, so the
+// sender must have signed the hash with this sponsor set.
+func (sg *SmartGateway) sponsorTransaction(tx *ChainTransaction, req *GatewayRequest) {
+	sg.mu.RLock()
+	sponsor := sg.sponsor
+	sg.mu.RUnlock()
+
+	if sponsor == nil || !sponsor.covers(req.UserRole, req.Action) {
+		return
+	}
+
+	tx.FeePayer = sponsor.Address()
+	tx.Hash = tx.CanonicalHash()
+	tx.FeePayerSignature = sponsor.sign(tx.Hash)
+}
+
+// covers - Check whether the sponsor pays for this role and action
+func (fs *FeeSponsor) covers(role, action string) bool {
+	roleMatch := false
+	for _, r := range fs.Target.Roles {
+		if r == "*" || r == role {
+			roleMatch = true
+			break
+		}
+	}
+	if !roleMatch {
+		return false
+	}
+
+	for _, a := range fs.Target.Actions {
+		if a == "*" || a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// sign - Fee payer signature over a transaction hash, in the node's format:
+// the hex of the public key followed by the ed25519 signature of the hex hash
+func (fs *FeeSponsor) sign(txHash string) string {
+	signature := append([]byte(nil), fs.Key.Public().(ed25519.PublicKey)...)
+	signature = append(signature, ed25519.Sign(fs.Key, []byte(txHash))...)
+	return hex.EncodeToString(signature)
+}
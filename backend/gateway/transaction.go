@@ -0,0 +1,136 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Chain Transactions - The node's transaction format
+// Gateway requests are submitted to the node as the JSON transactions its
+// RPC accepts. The hash must match the node's canonical transaction hash,
+// as the sender and the fee payer sign it.
+
+// Node transaction types used by gateway actions (consensus.TxType values)
+const (
+	chainTxTransfer uint8 = 0
+	chainTxMint     uint8 = 1
+	chainTxBurn     uint8 = 2
+	chainTxCall     uint8 = 8
+)
+
+// ChainTransaction - A node transaction as accepted by /api/v1/transaction/send
+type ChainTransaction struct {
+	Type      uint8  `json:"type"`
+	Hash      string `json:"hash"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Value     string `json:"value"`
+	Nonce     uint64 `json:"nonce"`
+	GasPrice  string `json:"gas_price"`
+	GasLimit  uint64 `json:"gas_limit"`
+	Data      string `json:"data"` // hex
+	Signature string `json:"signature"`
+
+	FeePayer          string `json:"fee_payer,omitempty"`
+	FeePayerSignature string `json:"fee_payer_signature,omitempty"`
+}
+
+// chainTransaction - Build the node transaction for a request. The sender's
+// signature is taken from the payload; it must sign CanonicalHash.
+func chainTransaction(req *GatewayRequest) (*ChainTransaction, error) {
+	tx := &ChainTransaction{
+		From:      req.UserID,
+		To:        payloadString(req.Payload, "to"),
+		Data:      payloadString(req.Payload, "data"),
+		Signature: payloadString(req.Payload, "signature"),
+	}
+
+	switch req.Action {
+	case "transfer_tokens":
+		tx.Type = chainTxTransfer
+	case "buy_presale":
+		tx.Type = chainTxCall
+		tx.To = payloadString(req.Payload, "contract")
+	case "mint_burn_token":
+		tx.Type = chainTxMint
+		if payloadString(req.Payload, "operation") == "burn" {
+			tx.Type = chainTxBurn
+		}
+	default:
+		return nil, fmt.Errorf("action %s has no chain transaction", req.Action)
+	}
+
+	var err error
+	if tx.Value, err = payloadAmount(req.Payload, "amount"); err != nil {
+		return nil, err
+	}
+	if tx.GasPrice, err = payloadAmount(req.Payload, "gas_price"); err != nil {
+		return nil, err
+	}
+	if tx.Nonce, err = payloadUint(req.Payload, "nonce"); err != nil {
+		return nil, err
+	}
+	if tx.GasLimit, err = payloadUint(req.Payload, "gas_limit"); err != nil {
+		return nil, err
+	}
+	if _, err := hex.DecodeString(tx.Data); err != nil {
+		return nil, fmt.Errorf("invalid data: %v", err)
+	}
+	return tx, nil
+}
+
+// CanonicalHash - The node's transaction hash (consensus.CalculateTxHash).
+// A fee payer is part of the hash, so it must be set before hashing.
+func (tx *ChainTransaction) CanonicalHash() string {
+	data, _ := hex.DecodeString(tx.Data)
+	preimage := fmt.Sprintf("%d|%s|%s|%s|%d|%s|%d|%x",
+		tx.Type, tx.From, tx.To, tx.Value, tx.Nonce, tx.GasPrice, tx.GasLimit, data)
+	if tx.FeePayer != "" {
+		preimage += "|" + tx.FeePayer
+	}
+	hash := sha256.Sum256([]byte(preimage))
+	return hex.EncodeToString(hash[:])
+}
+
+// payloadString - A string payload field, empty if missing
+func payloadString(payload map[string]interface{}, key string) string {
+	switch v := payload[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// payloadAmount - A decimal amount payload field, normalized as the node prints it
+func payloadAmount(payload map[string]interface{}, key string) (string, error) {
+	s := payloadString(payload, key)
+	if s == "" {
+		return "0", nil
+	}
+	amount, ok := new(big.Int).SetString(s, 10)
+	if !ok || amount.Sign() < 0 {
+		return "", fmt.Errorf("invalid %s: %s", key, s)
+	}
+	return amount.String(), nil
+}
+
+// payloadUint - An unsigned integer payload field, zero if missing
+func payloadUint(payload map[string]interface{}, key string) (uint64, error) {
+	s := payloadString(payload, key)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, s)
+	}
+	return n, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"vnc-blockchain/consensus"
+	"vnc-blockchain/storage"
+)
+
+// runExport implements `vnc-node export`: write stored blocks to a chain
+// file for backups and for seeding other nodes
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dataDir := fs.String("datadir", "./data/chaindata", "database directory")
+	from := fs.Uint64("from", 1, "first height to export")
+	to := fs.Uint64("to", 0, "last height to export (0 = latest stored)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("❌ Usage: vnc-node export [--from A] [--to B] FILE.vncchain")
+	}
+	path := fs.Arg(0)
+
+	db, err := storage.NewReadOnlyBlockchainDB(*dataDir)
+	if err != nil {
+		log.Fatal("❌ Failed to open database:", err)
+	}
+	defer db.Close()
+
+	last := *to
+	if last == 0 {
+		if last, err = db.GetLatestBlockNumber(); err != nil {
+			db.Close()
+			log.Fatal("❌ Failed to read latest block:", err)
+		}
+	}
+
+	if err := writeChainFile(db, path, *from, last); err != nil {
+		db.Close()
+		log.Fatal("❌ Export failed:", err)
+	}
+	fmt.Printf("✅ Exported blocks #%d - #%d to %s\n", *from, last, path)
+}
+
+// writeChainFile exports blocks from through to, writing to a temporary file
+// renamed into place once complete
+func writeChainFile(db *storage.BlockchainDB, path string, from, to uint64) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	writer := bufio.NewWriter(file)
+	err = consensus.ExportChain(writer, db, nodeConfig().ChainID, from, to, func(height uint64) {
+		if height%1000 == 0 {
+			fmt.Printf("📤 Exported block #%d\n", height)
+		}
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// runImport implements `vnc-node import`: validate and execute the blocks of
+// a chain file on top of the stored chain. The node must be stopped.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dataDir := fs.String("datadir", "./data/chaindata", "database directory")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("❌ Usage: vnc-node import FILE.vncchain")
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatal("❌ Failed to open chain file:", err)
+	}
+	defer file.Close()
+
+	db, err := storage.NewBlockchainDB(*dataDir)
+	if err != nil {
+		log.Fatal("❌ Failed to open database:", err)
+	}
+	defer db.Close()
+
+	// Rebuild the state at the stored head, checking the stored chain
+	base, err := db.GetSnapshotBase()
+	if err != nil {
+		db.Close()
+		log.Fatal("❌ Failed to read snapshot base:", err)
+	}
+	engine := consensus.NewDPoSBFT(nodeConfig())
+	replay, err := engine.VerifyChain(db, consensus.VerifyOptions{Resume: base})
+	if err != nil {
+		db.Close()
+		log.Fatal("❌ Failed to replay stored chain:", err)
+	}
+	if replay.Divergence != nil {
+		db.Close()
+		log.Fatal("❌ Stored chain diverges from replay at ", replay.Divergence)
+	}
+	engine.SetStore(db)
+
+	result, err := engine.ImportChain(file, db, func(height uint64) {
+		if height%1000 == 0 {
+			fmt.Printf("📥 Imported block #%d\n", height)
+		}
+	})
+	if err != nil {
+		db.Close()
+		if result != nil {
+			fmt.Printf("⚠️  %d blocks imported before the failure\n", result.Imported)
+		}
+		log.Fatal("❌ Import failed:", err)
+	}
+
+	fmt.Println("📥 Chain Import")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("File range:   #%d - #%d\n", result.From, result.To)
+	fmt.Printf("Imported:     %d blocks in %v\n", result.Imported, result.Duration)
+	fmt.Printf("Skipped:      %d already stored\n", result.Skipped)
+	fmt.Printf("Head:         #%d\n", result.Head)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"vnc-blockchain/storage"
+)
+
+// runDB implements `vnc-node db check`
+func runDB(args []string) {
+	if len(args) == 0 || args[0] != "check" {
+		log.Fatal("❌ Usage: vnc-node db check [--datadir DIR] [--repair-indexes]")
+	}
+	runDBCheck(args[1:])
+}
+
+// runDBCheck verifies the stored chain and prints the report as JSON. The
+// database is opened read-only unless indexes are to be repaired, in which
+// case the node must be stopped. It exits non-zero if problems remain.
+func runDBCheck(args []string) {
+	fs := flag.NewFlagSet("db check", flag.ExitOnError)
+	dataDir := fs.String("datadir", "./data/chaindata", "database directory")
+	repair := fs.Bool("repair-indexes", false, "rebuild the indexes if they are inconsistent")
+	fs.Parse(args)
+
+	// Opened on the store directly so only the report reaches stdout
+	cfg := storage.Config{Backend: storage.BackendLevelDB, DataDir: *dataDir, ReadOnly: !*repair}
+	store, err := storage.OpenKVStore(cfg)
+	if err != nil {
+		log.Fatal("❌ Failed to open database:", err)
+	}
+	db, err := storage.NewBlockchainDBWithStore(store, cfg)
+	if err != nil {
+		store.Close()
+		log.Fatal("❌ Failed to open database:", err)
+	}
+	defer db.Close()
+
+	report, err := db.CheckChain(*repair)
+	if err != nil {
+		db.Close()
+		log.Fatal("❌ Database check failed:", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		db.Close()
+		log.Fatal("❌ Failed to write report:", err)
+	}
+	if !report.OK() {
+		db.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+	"vnc-blockchain/storage"
+)
+
+// runRebuildIndexes implements `vnc-node rebuild-indexes`: drop the
+// secondary indexes and rebuild them from the stored blocks. The node must
+// be stopped.
+func runRebuildIndexes(args []string) {
+	fs := flag.NewFlagSet("rebuild-indexes", flag.ExitOnError)
+	dataDir := fs.String("datadir", "./data/chaindata", "database directory")
+	fs.Parse(args)
+
+	db, err := storage.NewBlockchainDB(*dataDir)
+	if err != nil {
+		log.Fatal("❌ Failed to open database:", err)
+	}
+	defer db.Close()
+
+	start := time.Now()
+	indexed, err := rebuildIndexes(db)
+	if err != nil {
+		db.Close()
+		log.Fatal("❌ Failed to rebuild indexes:", err)
+	}
+	fmt.Printf("✅ Indexed %d blocks in %v\n", indexed, time.Since(start))
+}
+
+// rebuildIndexes rebuilds the secondary indexes, printing progress
+func rebuildIndexes(db *storage.BlockchainDB) (uint64, error) {
+	fmt.Println("🗂️  Rebuilding indexes from stored blocks...")
+	return db.RebuildIndexes(func(height uint64) {
+		fmt.Printf("   indexed through block #%d\n", height)
+	})
+}
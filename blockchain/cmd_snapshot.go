@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"vnc-blockchain/consensus"
+	"vnc-blockchain/storage"
+)
+
+// runSnapshot implements `vnc-node snapshot export|import`
+func runSnapshot(args []string) {
+	if len(args) == 0 {
+		log.Fatal("❌ Usage: vnc-node snapshot export|import [flags]")
+	}
+	switch args[0] {
+	case "export":
+		runSnapshotExport(args[1:])
+	case "import":
+		runSnapshotImport(args[1:])
+	default:
+		log.Fatalf("❌ Unknown snapshot command %q", args[0])
+	}
+}
+
+// runSnapshotExport replays the stored chain up to a height and writes the
+// resulting state, validators and recent headers as a snapshot
+func runSnapshotExport(args []string) {
+	fs := flag.NewFlagSet("snapshot export", flag.ExitOnError)
+	dataDir := fs.String("datadir", "./data/chaindata", "database directory")
+	height := fs.Uint64("height", 0, "height to snapshot (0 = latest stored)")
+	out := fs.String("out", "", "snapshot file to write (default snapshot-<height>.vncsnap)")
+	fs.Parse(args)
+
+	db, err := storage.NewReadOnlyBlockchainDB(*dataDir)
+	if err != nil {
+		log.Fatal("❌ Failed to open database:", err)
+	}
+	defer db.Close()
+
+	// The state at the height is rebuilt by replay, which also checks every
+	// block up to it against its header
+	base, err := db.GetSnapshotBase()
+	if err != nil {
+		db.Close()
+		log.Fatal("❌ Failed to read snapshot base:", err)
+	}
+	engine := consensus.NewDPoSBFT(nodeConfig())
+	result, err := engine.VerifyChain(db, consensus.VerifyOptions{
+		To:     *height,
+		Resume: base,
+		OnProgress: func(height uint64) {
+			if height%1000 == 0 {
+				fmt.Printf("🔁 Replayed block #%d\n", height)
+			}
+		},
+	})
+	if err != nil {
+		db.Close()
+		log.Fatal("❌ Replay failed:", err)
+	}
+	if result.Divergence != nil {
+		db.Close()
+		log.Fatal("❌ Stored chain diverges from replay at ", result.Divergence)
+	}
+
+	headers, err := snapshotHeaders(db, engine.GetCurrentBlock())
+	if err != nil {
+		db.Close()
+		log.Fatal("❌ Failed to read headers:", err)
+	}
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("snapshot-%d.vncsnap", engine.GetCurrentBlock())
+	}
+	manifest, err := writeSnapshotFile(path, engine, headers)
+	if err != nil {
+		db.Close()
+		log.Fatal("❌ Failed to write snapshot:", err)
+	}
+
+	fmt.Println("📸 Snapshot Exported")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	printManifest(manifest)
+	fmt.Printf("File:         %s\n", path)
+}
+
+// runSnapshotImport verifies a snapshot against a trusted block hash and
+// starts an empty database from it. The node must be stopped.
+func runSnapshotImport(args []string) {
+	fs := flag.NewFlagSet("snapshot import", flag.ExitOnError)
+	dataDir := fs.String("datadir", "./data/chaindata", "database directory")
+	trustedHash := fs.String("trusted-hash", "", "hash of the snapshot block, from a trusted source")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *trustedHash == "" {
+		log.Fatal("❌ Usage: vnc-node snapshot import --trusted-hash HASH FILE")
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatal("❌ Failed to open snapshot:", err)
+	}
+	defer file.Close()
+
+	db, err := storage.NewBlockchainDB(*dataDir)
+	if err != nil {
+		log.Fatal("❌ Failed to open database:", err)
+	}
+	defer db.Close()
+
+	engine := consensus.NewDPoSBFT(nodeConfig())
+	manifest, err := engine.ImportSnapshot(file, *trustedHash, db)
+	if err != nil {
+		db.Close()
+		log.Fatal("❌ Snapshot rejected:", err)
+	}
+
+	fmt.Println("📸 Snapshot Imported")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	printManifest(manifest)
+	fmt.Println("✅ The node will continue from block", manifest.Height)
+}
+
+// snapshotHeaders reads the stored headers ending at height, skipping pruned
+// and unstored blocks below it
+func snapshotHeaders(db *storage.BlockchainDB, height uint64) ([]*consensus.Block, error) {
+	if height == 0 {
+		return nil, fmt.Errorf("no blocks stored to snapshot")
+	}
+	from := uint64(1)
+	if height > consensus.SnapshotHeaders {
+		from = height - consensus.SnapshotHeaders + 1
+	}
+	var headers []*consensus.Block
+	for number := height; number >= from; number-- {
+		block, err := db.GetHeader(number)
+		if err != nil {
+			if number == height {
+				return nil, err
+			}
+			break
+		}
+		headers = append([]*consensus.Block{block}, headers...)
+	}
+	return headers, nil
+}
+
+// writeSnapshotFile writes a snapshot next to path and renames it into
+// place, so an interrupted export leaves no partial file
+func writeSnapshotFile(path string, engine *consensus.DPoSBFT, headers []*consensus.Block) (*consensus.SnapshotManifest, error) {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	writer := bufio.NewWriter(file)
+	manifest, err := engine.WriteSnapshot(writer, headers)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return manifest, os.Rename(tmp, path)
+}
+
+// printManifest prints the summary of a snapshot
+func printManifest(manifest *consensus.SnapshotManifest) {
+	fmt.Printf("Height:       #%d\n", manifest.Height)
+	fmt.Printf("Block hash:   %s\n", manifest.BlockHash)
+	fmt.Printf("State root:   %s\n", manifest.StateRoot)
+	fmt.Printf("Headers:      %d\n", manifest.Headers)
+	fmt.Printf("Validators:   %d\n", manifest.Validators)
+	fmt.Printf("State:        %d bytes in %d chunks\n", manifest.StateSize, len(manifest.StateChunks))
+}
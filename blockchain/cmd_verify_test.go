@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"vnc-blockchain/consensus"
+)
+
+func TestCheckpointFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	for height := uint64(1000); height <= 2000; height += 1000 {
+		checkpoint := &consensus.ReplayCheckpoint{
+			Height:        height,
+			BlockHash:     "0xblock",
+			StateRoot:     "0xroot",
+			LastBlockTime: 1750000000,
+			State:         []byte(`{"balances":{}}`),
+		}
+		if err := writeCheckpoint(path, checkpoint); err != nil {
+			t.Fatal(err)
+		}
+		read, err := readCheckpoint(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(read, checkpoint) {
+			t.Fatalf("read back %+v, want %+v", read, checkpoint)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary checkpoint file left behind: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package consensus
+
+import "fmt"
+
+// AdminRole is an on-chain administrative role. The names match the roles
+// in backend/middleware/rbac.go so the permission matrix is enforced by
+// the state machine and not only by the API layer.
+type AdminRole string
+
+const (
+	RoleSuperAdmin AdminRole = "super_admin"
+	RoleAdminOps   AdminRole = "admin_ops"
+)
+
+// txPermissions maps privileged transaction types to the roles allowed to send them
+var txPermissions = map[TxType][]AdminRole{
+	TxMint: {RoleSuperAdmin}, // mint_burn_token
+	TxBurn: {RoleSuperAdmin}, // mint_burn_token
+
+	TxVestingGrant: {RoleSuperAdmin}, // mint_burn_token
+
+	TxFreeze:   {RoleSuperAdmin, RoleAdminOps}, // freeze_user_wallet
+	TxUnfreeze: {RoleSuperAdmin, RoleAdminOps}, // freeze_user_wallet
+
+	TxPause:  {RoleSuperAdmin}, // pause_blockchain
+	TxResume: {RoleSuperAdmin}, // pause_blockchain
+}
+
+// IsPrivileged reports whether a transaction type requires an admin role
+func (t TxType) IsPrivileged() bool {
+	_, exists := txPermissions[t]
+	return exists
+}
+
+// Validate checks the admin configuration: every role must be known, and a
+// chain with admins must require at least MinPauseThreshold signatures to
+// pause, no more than the admins allowed to pause
+func (c Config) Validate() error {
+	if len(c.AdminRoles) == 0 {
+		return nil
+	}
+
+	pausers := 0
+	for address, role := range c.AdminRoles {
+		switch role {
+		case RoleSuperAdmin:
+			pausers++
+		case RoleAdminOps:
+		default:
+			return fmt.Errorf("unknown admin role %q for %s", role, address)
+		}
+	}
+	if c.PauseThreshold < MinPauseThreshold {
+		return fmt.Errorf("pause threshold must be at least %d when admin roles are set, got %d",
+			MinPauseThreshold, c.PauseThreshold)
+	}
+	if c.PauseThreshold > pausers {
+		return fmt.Errorf("pause threshold %d exceeds the %d admins allowed to pause", c.PauseThreshold, pausers)
+	}
+	return nil
+}
+
+// authorizeAdmin checks that an admin transaction is signed by its sender
+// and that the sender holds a role permitted for the transaction type
+func (d *DPoSBFT) authorizeAdmin(tx *Transaction) error {
+	if !VerifyTransactionSignature(tx) {
+		return fmt.Errorf("invalid signature on admin transaction %s", tx.Hash)
+	}
+
+	role, exists := d.config.AdminRoles[tx.From]
+	if !exists {
+		return fmt.Errorf("sender %s has no admin role", tx.From)
+	}
+
+	for _, allowed := range txPermissions[tx.Type] {
+		if role == allowed {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("role %s not permitted for transaction type %d", role, tx.Type)
+}
@@ -0,0 +1,372 @@
+package consensus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+)
+
+// NativeSymbol is the symbol of the chain's native token; assets cannot reuse it
+const NativeSymbol = "VNC"
+
+// MaxAssetDecimals bounds the precision of a native asset
+const MaxAssetDecimals = 18
+
+// assetSymbolPattern is the accepted form of an asset symbol
+var assetSymbolPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{1,11}$`)
+
+// Asset is a fungible token held natively in chain state
+type Asset struct {
+	Symbol        string   `json:"symbol"`
+	Name          string   `json:"name"`
+	Decimals      uint8    `json:"decimals"`
+	Cap           *big.Int `json:"cap"` // 0 = uncapped
+	Supply        *big.Int `json:"supply"`
+	Issuer        string   `json:"issuer"`
+	CreatedHeight uint64   `json:"created_height"`
+}
+
+// AssetPayload is the JSON body carried in Data by asset transactions.
+// Amounts travel in tx.Value; the counterparty (recipient or spender) is tx.To.
+type AssetPayload struct {
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name,omitempty"`     // create
+	Decimals uint8  `json:"decimals,omitempty"` // create
+	Cap      string `json:"cap,omitempty"`      // create: decimal string, empty = uncapped
+	Owner    string `json:"owner,omitempty"`    // transfer-from: account being debited
+}
+
+// AssetBalance is one asset holding of an address
+type AssetBalance struct {
+	Symbol   string   `json:"symbol"`
+	Decimals uint8    `json:"decimals"`
+	Balance  *big.Int `json:"balance"`
+}
+
+// assetKey identifies a balance by (address, asset)
+type assetKey struct {
+	Address string
+	Symbol  string
+}
+
+// allowanceKey identifies an allowance by (asset, owner, spender)
+type allowanceKey struct {
+	Symbol  string
+	Owner   string
+	Spender string
+}
+
+// GetAsset returns an asset definition
+func (s *StateDB) GetAsset(symbol string) (*Asset, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	asset, exists := s.assets[symbol]
+	if !exists {
+		return nil, false
+	}
+	return asset.copy(), true
+}
+
+// GetAssets returns all asset definitions sorted by symbol
+func (s *StateDB) GetAssets() []*Asset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	assets := make([]*Asset, 0, len(s.assets))
+	for _, asset := range s.assets {
+		assets = append(assets, asset.copy())
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].Symbol < assets[j].Symbol })
+	return assets
+}
+
+// GetAssetBalance returns an address's balance of an asset
+func (s *StateDB) GetAssetBalance(address, symbol string) *big.Int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if balance, exists := s.assetBalances[assetKey{address, symbol}]; exists {
+		return new(big.Int).Set(balance)
+	}
+	return big.NewInt(0)
+}
+
+// GetAssetBalances returns every non-zero asset balance of an address
+func (s *StateDB) GetAssetBalances(address string) []*AssetBalance {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	balances := make([]*AssetBalance, 0)
+	for key, balance := range s.assetBalances {
+		if key.Address != address {
+			continue
+		}
+		balances = append(balances, &AssetBalance{
+			Symbol:   key.Symbol,
+			Decimals: s.assets[key.Symbol].Decimals,
+			Balance:  new(big.Int).Set(balance),
+		})
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Symbol < balances[j].Symbol })
+	return balances
+}
+
+// GetAllowance returns how much spender may move from owner's asset balance
+func (s *StateDB) GetAllowance(symbol, owner, spender string) *big.Int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if allowance, exists := s.allowances[allowanceKey{symbol, owner, spender}]; exists {
+		return new(big.Int).Set(allowance)
+	}
+	return big.NewInt(0)
+}
+
+// setAssetBalance stores a balance, dropping zero entries. Caller must hold s.mu.
+func (s *StateDB) setAssetBalance(address, symbol string, amount *big.Int) {
+	key := assetKey{address, symbol}
+	if amount.Sign() == 0 {
+		delete(s.assetBalances, key)
+		return
+	}
+	s.assetBalances[key] = amount
+}
+
+// assetBalance returns a balance without copying. Caller must hold s.mu.
+func (s *StateDB) assetBalance(address, symbol string) *big.Int {
+	if balance, exists := s.assetBalances[assetKey{address, symbol}]; exists {
+		return balance
+	}
+	return big.NewInt(0)
+}
+
+// moveAsset moves amount of an asset between addresses. Caller must hold s.mu.
+func (s *StateDB) moveAsset(symbol, from, to string, amount *big.Int) error {
+	balance := s.assetBalance(from, symbol)
+	if balance.Cmp(amount) < 0 {
+		return fmt.Errorf("insufficient %s balance: have %s, need %s", symbol, balance.String(), amount.String())
+	}
+	s.setAssetBalance(from, symbol, new(big.Int).Sub(balance, amount))
+	s.setAssetBalance(to, symbol, new(big.Int).Add(s.assetBalance(to, symbol), amount))
+	return nil
+}
+
+// assetDigest hashes asset definitions, balances and allowances in key order.
+// Caller must hold s.mu.
+func (s *StateDB) assetDigest() string {
+	h := sha256.New()
+
+	symbols := make([]string, 0, len(s.assets))
+	for symbol := range s.assets {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		asset := s.assets[symbol]
+		fmt.Fprintf(h, "asset:%s:%d:%s:%s:%s;", symbol, asset.Decimals, asset.Cap.String(),
+			asset.Supply.String(), asset.Issuer)
+	}
+
+	balances := make([]assetKey, 0, len(s.assetBalances))
+	for key := range s.assetBalances {
+		balances = append(balances, key)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].Address != balances[j].Address {
+			return balances[i].Address < balances[j].Address
+		}
+		return balances[i].Symbol < balances[j].Symbol
+	})
+	for _, key := range balances {
+		fmt.Fprintf(h, "balance:%s:%s=%s;", key.Address, key.Symbol, s.assetBalances[key].String())
+	}
+
+	allowances := make([]allowanceKey, 0, len(s.allowances))
+	for key := range s.allowances {
+		allowances = append(allowances, key)
+	}
+	sort.Slice(allowances, func(i, j int) bool {
+		a, b := allowances[i], allowances[j]
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		if a.Owner != b.Owner {
+			return a.Owner < b.Owner
+		}
+		return a.Spender < b.Spender
+	})
+	for _, key := range allowances {
+		fmt.Fprintf(h, "allowance:%s:%s:%s=%s;", key.Symbol, key.Owner, key.Spender, s.allowances[key].String())
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// copy returns a deep copy of an asset definition
+func (a *Asset) copy() *Asset {
+	copied := *a
+	copied.Cap = new(big.Int).Set(a.Cap)
+	copied.Supply = new(big.Int).Set(a.Supply)
+	return &copied
+}
+
+// applyAssetTransaction executes an asset create, transfer, approve,
+// transfer-from or mint. The payload is carried in tx.Data.
+func (d *DPoSBFT) applyAssetTransaction(tx *Transaction, block *Block) error {
+	var payload AssetPayload
+	if err := json.Unmarshal(tx.Data, &payload); err != nil {
+		return fmt.Errorf("invalid asset payload: %w", err)
+	}
+	if tx.Value.Sign() < 0 {
+		return fmt.Errorf("asset amount cannot be negative")
+	}
+
+	s := d.stateDB
+	if s.IsFrozen(tx.From) && tx.Type != TxAssetApprove {
+		return fmt.Errorf("account %s is frozen", tx.From)
+	}
+
+	var err error
+	s.mu.Lock()
+	if tx.Type == TxAssetCreate {
+		err = s.createAsset(tx, &payload, block)
+	} else {
+		err = s.updateAsset(tx, &payload)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// createAsset registers a new asset and mints tx.Value to the issuer.
+// Caller must hold s.mu.
+func (s *StateDB) createAsset(tx *Transaction, payload *AssetPayload, block *Block) error {
+	if !assetSymbolPattern.MatchString(payload.Symbol) || payload.Symbol == NativeSymbol {
+		return fmt.Errorf("invalid asset symbol %q", payload.Symbol)
+	}
+	if _, exists := s.assets[payload.Symbol]; exists {
+		return fmt.Errorf("asset %s already exists", payload.Symbol)
+	}
+	if payload.Decimals > MaxAssetDecimals {
+		return fmt.Errorf("asset decimals %d exceed %d", payload.Decimals, MaxAssetDecimals)
+	}
+
+	limit := big.NewInt(0)
+	if payload.Cap != "" {
+		if _, ok := limit.SetString(payload.Cap, 10); !ok || limit.Sign() < 0 {
+			return fmt.Errorf("invalid asset cap %q", payload.Cap)
+		}
+	}
+	if limit.Sign() > 0 && tx.Value.Cmp(limit) > 0 {
+		return fmt.Errorf("initial supply %s exceeds cap %s", tx.Value.String(), limit.String())
+	}
+
+	s.assets[payload.Symbol] = &Asset{
+		Symbol:        payload.Symbol,
+		Name:          payload.Name,
+		Decimals:      payload.Decimals,
+		Cap:           limit,
+		Supply:        new(big.Int).Set(tx.Value),
+		Issuer:        tx.From,
+		CreatedHeight: block.Number,
+	}
+	s.setAssetBalance(tx.From, payload.Symbol, new(big.Int).Set(tx.Value))
+
+	fmt.Printf("🪙 Asset %s created by %s (supply %s)\n", payload.Symbol, tx.From, tx.Value.String())
+	return nil
+}
+
+// updateAsset applies a transfer, approve, transfer-from or mint to an
+// existing asset. Caller must hold s.mu.
+func (s *StateDB) updateAsset(tx *Transaction, payload *AssetPayload) error {
+	asset, exists := s.assets[payload.Symbol]
+	if !exists {
+		return fmt.Errorf("asset %s not found", payload.Symbol)
+	}
+	if tx.To == "" {
+		return fmt.Errorf("asset transaction needs a recipient")
+	}
+
+	switch tx.Type {
+	case TxAssetTransfer:
+		return s.moveAsset(asset.Symbol, tx.From, tx.To, tx.Value)
+
+	case TxAssetApprove:
+		key := allowanceKey{asset.Symbol, tx.From, tx.To}
+		if tx.Value.Sign() == 0 {
+			delete(s.allowances, key)
+		} else {
+			s.allowances[key] = new(big.Int).Set(tx.Value)
+		}
+		return nil
+
+	case TxAssetTransferFrom:
+		if payload.Owner == "" {
+			return fmt.Errorf("transfer-from needs an owner")
+		}
+		if _, frozen := s.frozen[payload.Owner]; frozen {
+			return fmt.Errorf("account %s is frozen", payload.Owner)
+		}
+		key := allowanceKey{asset.Symbol, payload.Owner, tx.From}
+		allowance, exists := s.allowances[key]
+		if !exists || allowance.Cmp(tx.Value) < 0 {
+			return fmt.Errorf("allowance exceeded for %s on %s", tx.From, asset.Symbol)
+		}
+		if err := s.moveAsset(asset.Symbol, payload.Owner, tx.To, tx.Value); err != nil {
+			return err
+		}
+		remaining := new(big.Int).Sub(allowance, tx.Value)
+		if remaining.Sign() == 0 {
+			delete(s.allowances, key)
+		} else {
+			s.allowances[key] = remaining
+		}
+		return nil
+
+	case TxAssetMint:
+		if tx.From != asset.Issuer {
+			return fmt.Errorf("only the issuer can mint %s", asset.Symbol)
+		}
+		supply := new(big.Int).Add(asset.Supply, tx.Value)
+		if asset.Cap.Sign() > 0 && supply.Cmp(asset.Cap) > 0 {
+			return fmt.Errorf("mint would exceed %s cap: supply %s, cap %s",
+				asset.Symbol, supply.String(), asset.Cap.String())
+		}
+		asset.Supply = supply
+		s.setAssetBalance(tx.To, asset.Symbol, new(big.Int).Add(s.assetBalance(tx.To, asset.Symbol), tx.Value))
+		return nil
+	}
+
+	return fmt.Errorf("unknown asset transaction type: %d", tx.Type)
+}
+
+// GetAssets returns all asset definitions
+func (d *DPoSBFT) GetAssets() []*Asset {
+	return d.stateDB.GetAssets()
+}
+
+// GetAsset returns an asset definition
+func (d *DPoSBFT) GetAsset(symbol string) (*Asset, error) {
+	asset, exists := d.stateDB.GetAsset(symbol)
+	if !exists {
+		return nil, fmt.Errorf("asset %s not found", symbol)
+	}
+	return asset, nil
+}
+
+// GetAssetBalances returns every asset balance of an address
+func (d *DPoSBFT) GetAssetBalances(address string) []*AssetBalance {
+	return d.stateDB.GetAssetBalances(address)
+}
+
+// GetAllowance returns an asset allowance
+func (d *DPoSBFT) GetAllowance(symbol, owner, spender string) *big.Int {
+	return d.stateDB.GetAllowance(symbol, owner, spender)
+}
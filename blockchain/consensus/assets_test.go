@@ -0,0 +1,82 @@
+package consensus
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// assetTx builds a signed asset transaction on the TEST asset
+func assetTx(from string, txType TxType, to string, value int64, nonce uint64, owner string) *Transaction {
+	tx := &Transaction{
+		Type:  txType,
+		Value: big.NewInt(value),
+		Nonce: nonce,
+		Data:  []byte(`{"symbol":"TEST","name":"Test","decimals":2}`),
+	}
+	if to != "" {
+		tx.To = testAddress(to)
+	}
+	if owner != "" {
+		tx.Data = []byte(fmt.Sprintf(`{"symbol":"TEST","owner":%q}`, testAddress(owner)))
+	}
+	return signedTx(testKey(from), tx)
+}
+
+func TestAssetTransfer(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice", "bob")
+	receipts := runBlock(t, d,
+		assetTx("alice", TxAssetCreate, "", 1000, 0, ""),
+		assetTx("alice", TxAssetTransfer, "bob", 300, 1, ""),
+		assetTx("bob", TxAssetTransfer, "carol", 301, 0, ""),
+	)
+	requireStatus(t, receipts[0], ReceiptStatusSuccess)
+	requireStatus(t, receipts[1], ReceiptStatusSuccess)
+	requireStatus(t, receipts[2], ReceiptStatusFailed)
+
+	for name, want := range map[string]int64{"alice": 700, "bob": 300, "carol": 0} {
+		if balance := d.stateDB.GetAssetBalance(testAddress(name), "TEST"); balance.Int64() != want {
+			t.Fatalf("%s holds %s TEST, want %d", name, balance, want)
+		}
+	}
+	if asset, err := d.GetAsset("TEST"); err != nil || asset.Supply.Int64() != 1000 || asset.Issuer != testAddress("alice") {
+		t.Fatalf("asset %+v (%v), want a supply of 1000 issued by alice", asset, err)
+	}
+}
+
+func TestAssetTransferFromAllowance(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice", "bob", "carol")
+	receipts := runBlock(t, d,
+		assetTx("alice", TxAssetCreate, "", 1000, 0, ""),
+		assetTx("alice", TxAssetApprove, "bob", 100, 1, ""),
+	)
+	requireStatus(t, receipts[1], ReceiptStatusSuccess)
+	if allowance := d.GetAllowance("TEST", testAddress("alice"), testAddress("bob")); allowance.Int64() != 100 {
+		t.Fatalf("allowance %s, want 100", allowance)
+	}
+
+	receipts = runBlock(t, d,
+		assetTx("bob", TxAssetTransferFrom, "carol", 60, 0, "alice"),
+		assetTx("bob", TxAssetTransferFrom, "carol", 41, 1, "alice"),
+		assetTx("carol", TxAssetTransferFrom, "carol", 1, 0, "alice"),
+	)
+	requireStatus(t, receipts[0], ReceiptStatusSuccess)
+	requireStatus(t, receipts[1], ReceiptStatusFailed)
+	requireStatus(t, receipts[2], ReceiptStatusFailed)
+	if allowance := d.GetAllowance("TEST", testAddress("alice"), testAddress("bob")); allowance.Int64() != 40 {
+		t.Fatalf("allowance %s after spending 60, want 40", allowance)
+	}
+	if balance := d.stateDB.GetAssetBalance(testAddress("carol"), "TEST"); balance.Int64() != 60 {
+		t.Fatalf("carol holds %s TEST, want 60", balance)
+	}
+
+	// Spending the rest removes the allowance
+	receipts = runBlock(t, d, assetTx("bob", TxAssetTransferFrom, "bob", 40, 2, "alice"))
+	requireStatus(t, receipts[0], ReceiptStatusSuccess)
+	if allowance := d.GetAllowance("TEST", testAddress("alice"), testAddress("bob")); allowance.Sign() != 0 {
+		t.Fatalf("allowance %s left after spending it all", allowance)
+	}
+	if balance := d.stateDB.GetAssetBalance(testAddress("alice"), "TEST"); balance.Int64() != 900 {
+		t.Fatalf("alice holds %s TEST, want 900", balance)
+	}
+}
@@ -0,0 +1,186 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+	"vnc-blockchain/vm"
+)
+
+// Serial, parallel and pipelined execution of the same transfer-heavy block.
+// The test checks the three produce identical state and receipts; the
+// benchmarks compare their speed:
+//
+//	go test ./consensus -run '^$' -bench Execute
+
+const (
+	benchTransactions = 10000
+	benchAccounts     = 5000
+)
+
+// benchmarkBlock builds a deterministic block of signed transfers
+func benchmarkBlock(txCount, accounts int) *Block {
+	rng := rand.New(rand.NewSource(20250))
+	nonces := make(map[int]uint64)
+	txs := make([]*Transaction, 0, txCount)
+
+	for i := 0; i < txCount; i++ {
+		sender := rng.Intn(accounts)
+		tx := &Transaction{
+			Type:     TxTransfer,
+			From:     benchmarkAddress(sender),
+			To:       benchmarkAddress(rng.Intn(accounts)),
+			Value:    big.NewInt(rng.Int63n(1e18)),
+			Nonce:    nonces[sender],
+			GasPrice: big.NewInt(1e9),
+			GasLimit: vm.GasTxCall,
+		}
+		nonces[sender]++
+		SignTransaction(tx, benchmarkKey(sender))
+		txs = append(txs, tx)
+	}
+
+	return &Block{
+		Number:       1,
+		Timestamp:    1750000000,
+		Transactions: txs,
+		Validator:    benchmarkAddress(0),
+		GasLimit:     uint64(txCount) * vm.GasTxCall,
+	}
+}
+
+// benchmarkKey derives a deterministic key for benchmark account i
+func benchmarkKey(i int) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte(fmt.Sprintf("bench-account-%d", i)))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+func benchmarkAddress(i int) string {
+	return AddressOf(benchmarkKey(i))
+}
+
+// newBenchmarkEngine funds the benchmark accounts. When pipelined, the
+// block builder prepares a candidate for the block as it would while the
+// previous block is voted on.
+func newBenchmarkEngine(tb testing.TB, block *Block, accounts, workers int, pipelined bool) *DPoSBFT {
+	tb.Helper()
+	engine := NewDPoSBFT(Config{ExecutionWorkers: workers})
+	for i := 0; i < accounts; i++ {
+		if err := engine.stateDB.Mint(benchmarkAddress(i), vnc(1000)); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if pipelined {
+		req := buildRequest{height: block.Number, timestamp: block.Timestamp}
+		engine.prepared = engine.builder.prepare(req, block.Transactions)
+	}
+	return engine
+}
+
+// encodedReceipts returns the canonical encoding of a block's receipts
+func encodedReceipts(tb testing.TB, engine *DPoSBFT, height uint64) [][]byte {
+	tb.Helper()
+	var encoded [][]byte
+	for _, receipt := range engine.blockReceipts[height] {
+		data, err := receipt.MarshalBinary()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		encoded = append(encoded, data)
+	}
+	return encoded
+}
+
+// requireIdentical fails unless two engines hold the same state and receipts
+func requireIdentical(tb testing.TB, name string, want, got *DPoSBFT, block *Block, accounts int) {
+	tb.Helper()
+	if want.stateDB.GetRoot() != got.stateDB.GetRoot() {
+		tb.Fatalf("%s: state root differs from serial execution", name)
+	}
+	for i := 0; i < accounts; i++ {
+		address := benchmarkAddress(i)
+		if want.stateDB.GetNonce(address) != got.stateDB.GetNonce(address) {
+			tb.Fatalf("%s: nonce of %s differs from serial execution", name, address)
+		}
+	}
+	wantReceipts := encodedReceipts(tb, want, block.Number)
+	gotReceipts := encodedReceipts(tb, got, block.Number)
+	if len(wantReceipts) != len(gotReceipts) {
+		tb.Fatalf("%s: %d receipts, serial execution has %d", name, len(gotReceipts), len(wantReceipts))
+	}
+	for i := range wantReceipts {
+		if !bytes.Equal(wantReceipts[i], gotReceipts[i]) {
+			tb.Fatalf("%s: receipt %d differs from serial execution", name, i)
+		}
+	}
+}
+
+func TestParallelExecutionMatchesSerial(t *testing.T) {
+	const txCount, accounts = 2000, 300
+	block := benchmarkBlock(txCount, accounts)
+
+	// Mix in failures where the order of the checks decides the error
+	// reported, using unfunded accounts outside the block
+	frozen, poor := accounts, accounts+1
+	failing := []*Transaction{
+		{From: benchmarkAddress(frozen), Nonce: 7},         // signed by another key: signature before nonce
+		{From: benchmarkAddress(frozen), Nonce: 7},         // nonce before frozen
+		{From: benchmarkAddress(frozen), Value: vnc(5000)}, // frozen before balance
+		{From: benchmarkAddress(poor), Value: vnc(5000)},   // balance
+	}
+	for i, tx := range failing {
+		tx.Type, tx.To, tx.GasPrice = TxTransfer, benchmarkAddress(0), big.NewInt(1)
+		if tx.Value == nil {
+			tx.Value = big.NewInt(1)
+		}
+		signer := frozen
+		if i == 0 || i == 3 {
+			signer = poor
+		}
+		SignTransaction(tx, benchmarkKey(signer))
+	}
+	failing = append(failing, failing[3]) // a replay
+	txs := append([]*Transaction(nil), block.Transactions[:30]...)
+	txs = append(txs, failing...)
+	block.Transactions = append(txs, block.Transactions[30:]...)
+	block.GasLimit += uint64(len(failing)) * vm.GasTxCall
+
+	run := func(workers int, pipelined bool) *DPoSBFT {
+		engine := newBenchmarkEngine(t, block, accounts, workers, pipelined)
+		engine.stateDB.frozen[benchmarkAddress(frozen)] = &FreezeRecord{Address: benchmarkAddress(frozen)}
+		engine.executeTransactions(block)
+		return engine
+	}
+
+	serial := run(1, false)
+	requireIdentical(t, "parallel", serial, run(4, false), block, accounts)
+	requireIdentical(t, "pipelined", serial, run(4, true), block, accounts)
+
+	wantErrors := []string{"invalid transaction signature", "nonce too high", "is frozen", "insufficient spendable balance", "nonce too low"}
+	for i, want := range wantErrors {
+		receipt := serial.blockReceipts[block.Number][30+i]
+		if !strings.Contains(receipt.Error, want) {
+			t.Fatalf("failing transaction %d: error %q, want %q", i, receipt.Error, want)
+		}
+	}
+}
+
+func benchmarkExecution(b *testing.B, workers int, pipelined bool) {
+	block := benchmarkBlock(benchTransactions, benchAccounts)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		engine := newBenchmarkEngine(b, block, benchAccounts, workers, pipelined)
+		b.StartTimer()
+		engine.executeTransactions(block)
+	}
+	b.ReportMetric(float64(benchTransactions*b.N)/b.Elapsed().Seconds(), "tx/s")
+}
+
+func BenchmarkExecuteSerial(b *testing.B)    { benchmarkExecution(b, 1, false) }
+func BenchmarkExecuteParallel(b *testing.B)  { benchmarkExecution(b, 0, false) }
+func BenchmarkExecutePipelined(b *testing.B) { benchmarkExecution(b, 0, true) }
@@ -0,0 +1,87 @@
+package consensus
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// BloomByteLength is the size of a logs bloom (2048 bits)
+const BloomByteLength = 256
+
+// Bloom is a 2048-bit bloom filter over log addresses and topics,
+// built the same way as Ethereum's logsBloom
+type Bloom [BloomByteLength]byte
+
+// Add inserts a value into the bloom filter
+func (b *Bloom) Add(value []byte) {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(value)
+	hash := h.Sum(nil)
+
+	// Three 11-bit indexes taken from the first six bytes of the hash
+	for i := 0; i < 6; i += 2 {
+		bit := (uint(hash[i])<<8 | uint(hash[i+1])) & 2047
+		b[BloomByteLength-1-bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// Test reports whether a value may be in the filter
+func (b *Bloom) Test(value []byte) bool {
+	var probe Bloom
+	probe.Add(value)
+	for i := range probe {
+		if b[i]&probe[i] != probe[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Hex returns the 0x-prefixed hex encoding of the bloom
+func (b Bloom) Hex() string {
+	return "0x" + hex.EncodeToString(b[:])
+}
+
+// MarshalText encodes the bloom as hex for JSON
+func (b Bloom) MarshalText() ([]byte, error) {
+	return []byte(b.Hex()), nil
+}
+
+// UnmarshalText decodes a hex bloom
+func (b *Bloom) UnmarshalText(text []byte) error {
+	raw, err := hex.DecodeString(strings.TrimPrefix(string(text), "0x"))
+	if err != nil {
+		return err
+	}
+	if len(raw) > BloomByteLength {
+		return fmt.Errorf("bloom of %d bytes exceeds %d", len(raw), BloomByteLength)
+	}
+	*b = Bloom{}
+	copy(b[BloomByteLength-len(raw):], raw)
+	return nil
+}
+
+// CreateBloom builds the logs bloom for a block's receipts
+func CreateBloom(receipts []*Receipt) Bloom {
+	var bloom Bloom
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			bloom.Add(bloomValue(log.Address))
+			for _, topic := range log.Topics {
+				bloom.Add(bloomValue(topic))
+			}
+		}
+	}
+	return bloom
+}
+
+// bloomValue returns the bytes inserted into the bloom for an address or topic
+func bloomValue(value string) []byte {
+	if raw, err := hex.DecodeString(strings.TrimPrefix(value, "0x")); err == nil {
+		return raw
+	}
+	return []byte(value)
+}
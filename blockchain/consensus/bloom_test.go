@@ -0,0 +1,45 @@
+package consensus
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBloomJSONRoundTrip(t *testing.T) {
+	var bloom Bloom
+	bloom.Add([]byte("topic"))
+	data, err := json.Marshal(bloom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Bloom
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != bloom || !decoded.Test([]byte("topic")) {
+		t.Fatal("bloom changed in a JSON round trip")
+	}
+
+	// Short encodings are right-aligned
+	if err := decoded.UnmarshalText([]byte("0x01")); err != nil || decoded[BloomByteLength-1] != 1 {
+		t.Fatalf("short bloom decoded to %s (%v)", decoded.Hex(), err)
+	}
+}
+
+func TestOversizedBloomRejected(t *testing.T) {
+	oversized := `"0x` + strings.Repeat("ff", BloomByteLength+1) + `"`
+	var bloom Bloom
+	if err := json.Unmarshal([]byte(oversized), &bloom); err == nil {
+		t.Fatal("bloom longer than 256 bytes accepted")
+	}
+
+	var receipt Receipt
+	if err := json.Unmarshal([]byte(`{"logs_bloom":`+oversized+`}`), &receipt); err == nil {
+		t.Fatal("receipt with an oversized bloom accepted")
+	}
+	var block Block
+	if err := json.Unmarshal([]byte(`{"LogsBloom":`+oversized+`}`), &block); err == nil {
+		t.Fatal("block with an oversized bloom accepted")
+	}
+}
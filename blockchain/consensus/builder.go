@@ -0,0 +1,186 @@
+package consensus
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Optimistic block building.
+//
+// While a proposed block is being voted on, the builder prepares the next
+// one: it selects the candidate transactions from the mempool, verifies
+// their signatures and executes plain transfers speculatively against the
+// state left by the proposed block. When the next block is produced the
+// prepared work is reused: cached signature checks are skipped, and
+// speculative transfers are committed if every value they read is still
+// current, otherwise they are re-executed. Block contents and state roots
+// are therefore identical to building the block from scratch.
+
+// maxBlockTransactions is the most transactions taken from the mempool per block
+const maxBlockTransactions = 1000
+
+// blockCandidate is the work prepared ahead of time for one block height
+type blockCandidate struct {
+	height    uint64
+	timestamp int64                         // block time the transfers were executed at
+	verified  map[string]*Transaction       // tx hash -> transaction whose signature checked out
+	results   map[string]*speculativeResult // tx hash -> speculative transfer execution
+}
+
+// buildRequest asks the builder to prepare a height
+type buildRequest struct {
+	height    uint64
+	timestamp int64
+}
+
+// blockBuilder prepares block candidates in the background
+type blockBuilder struct {
+	engine    *DPoSBFT
+	requests  chan buildRequest
+	candidate *blockCandidate
+	mu        sync.Mutex
+}
+
+func newBlockBuilder(engine *DPoSBFT) *blockBuilder {
+	return &blockBuilder{
+		engine:   engine,
+		requests: make(chan buildRequest, 1),
+	}
+}
+
+// run prepares candidates as they are requested
+func (b *blockBuilder) run() {
+	for req := range b.requests {
+		candidate := b.build(req)
+
+		b.mu.Lock()
+		b.candidate = candidate
+		b.mu.Unlock()
+	}
+}
+
+// schedule requests a candidate for a height, replacing any request not yet started
+func (b *blockBuilder) schedule(height uint64, timestamp int64) {
+	req := buildRequest{height: height, timestamp: timestamp}
+	for {
+		select {
+		case b.requests <- req:
+			return
+		default:
+			select {
+			case <-b.requests:
+			default:
+			}
+		}
+	}
+}
+
+// take hands over the candidate for a height. A candidate is used at most
+// once; nil is returned if none is ready for that height.
+func (b *blockBuilder) take(height uint64) *blockCandidate {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	candidate := b.candidate
+	b.candidate = nil
+	if candidate == nil || candidate.height != height {
+		return nil
+	}
+	return candidate
+}
+
+// build selects and pre-executes the transactions for a height. It reads
+// the mempool and state through their own locks and never takes the
+// engine lock, so it runs alongside voting.
+func (b *blockBuilder) build(req buildRequest) *blockCandidate {
+	start := time.Now()
+	txs := b.engine.mempool.GetPendingTransactions(maxBlockTransactions, BlockGasLimit, b.engine.stateDB.GetNonce)
+	candidate := b.prepare(req, txs)
+	b.engine.metrics.Build.Observe(time.Since(start))
+
+	fmt.Printf("🏗️  Candidate for block #%d prepared: %d transactions, %d pre-executed\n",
+		req.height, len(txs), len(candidate.results))
+	return candidate
+}
+
+// prepare verifies signatures and pre-executes transfers for a set of transactions
+func (b *blockBuilder) prepare(req buildRequest, txs []*Transaction) *blockCandidate {
+	d := b.engine
+	candidate := &blockCandidate{
+		height:    req.height,
+		timestamp: req.timestamp,
+		verified:  make(map[string]*Transaction, len(txs)),
+		results:   make(map[string]*speculativeResult),
+	}
+
+	// Transfers are only run ahead when the block will execute them in parallel
+	speculate := d.executionWorkers() >= 2 && !d.stateDB.IsPaused()
+	overlay := newOverlayState(d.stateDB)
+	results := make([]*speculativeResult, len(txs))
+
+	parallelFor(len(txs), d.executionWorkers(), func(i int) {
+		tx := txs[i]
+		if !VerifyTransactionSignature(tx) {
+			return
+		}
+		result := &speculativeResult{tx: tx, sigValid: true}
+		if speculate && tx.Type == TxTransfer && !tx.IsSponsored() {
+			result.view = newTxView(overlay)
+			result.prepared = true
+			result.frozen = d.stateDB.IsFrozen(tx.From)
+			result.vesting = d.stateDB.GetVestingAccount(tx.From) != nil
+			result.err = d.stateDB.executeTransfer(result.view, tx, req.timestamp, transferFee(tx))
+		}
+		results[i] = result
+	})
+
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		candidate.verified[result.tx.Hash] = result.tx
+		if result.prepared {
+			candidate.results[result.tx.Hash] = result
+		}
+	}
+	return candidate
+}
+
+// signatureVerified reports whether the builder already verified a transaction
+func (c *blockCandidate) signatureVerified(tx *Transaction) bool {
+	return c != nil && c.verified[tx.Hash] == tx
+}
+
+// speculation returns the prepared execution of a transfer if it still
+// applies to a block at the given time. Besides the values recorded in its
+// view, a transfer depends on the sender's frozen status and, for vesting
+// accounts, on the block time.
+func (c *blockCandidate) speculation(tx *Transaction, timestamp int64, s *StateDB) (speculativeResult, bool) {
+	if c == nil {
+		return speculativeResult{}, false
+	}
+	result, exists := c.results[tx.Hash]
+	if !exists || result.tx != tx {
+		return speculativeResult{}, false
+	}
+	if result.frozen || s.IsFrozen(tx.From) {
+		return speculativeResult{}, false
+	}
+	vesting := s.GetVestingAccount(tx.From) != nil
+	if vesting != result.vesting || (vesting && timestamp != c.timestamp) {
+		return speculativeResult{}, false
+	}
+	return *result, true
+}
+
+// verifySignature checks a transaction's signature, using the result cached
+// by the builder when there is one. Caller must hold d.mu.
+func (d *DPoSBFT) verifySignature(tx *Transaction) bool {
+	return d.prepared.signatureVerified(tx) || VerifyTransactionSignature(tx)
+}
+
+// GetBlockMetrics returns latency histograms for the block pipeline
+func (d *DPoSBFT) GetBlockMetrics() BlockMetricsSnapshot {
+	return d.metrics.Snapshot(time.Duration(d.config.BlockTime) * time.Second)
+}
@@ -0,0 +1,65 @@
+package consensus
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestStaleCandidateReExecuted prepares transfers, then changes the balances
+// half of them read before the block runs. Those are re-executed, the rest
+// reused, and the block ends in the state of executing it from scratch.
+func TestStaleCandidateReExecuted(t *testing.T) {
+	senders := make([]string, minParallelRun)
+	txs := make([]*Transaction, minParallelRun)
+	for i := range senders {
+		senders[i] = fmt.Sprintf("sender%d", i)
+		txs[i] = transfer(senders[i], fmt.Sprintf("recipient%d", i), 1, 0)
+	}
+	changed := senders[:minParallelRun/2]
+
+	run := func(prepare bool) *DPoSBFT {
+		d := newTestEngine(t, Config{ExecutionWorkers: 4}, senders...)
+		var candidate *blockCandidate
+		if prepare {
+			candidate = d.builder.prepare(buildRequest{height: 1, timestamp: 1}, txs)
+		}
+		for _, name := range changed {
+			if err := d.stateDB.Mint(testAddress(name), vnc(1)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		d.prepared = candidate
+		for _, receipt := range runBlock(t, d, txs...) {
+			requireStatus(t, receipt, ReceiptStatusSuccess)
+		}
+		d.prepared = nil
+		return d
+	}
+
+	fresh, pipelined := run(false), run(true)
+	metrics := pipelined.GetBlockMetrics()
+	if metrics.SpeculativeStale != uint64(len(changed)) || metrics.SpeculativeReused != uint64(len(txs)-len(changed)) {
+		t.Fatalf("%d prepared transfers re-executed and %d reused, want %d and %d",
+			metrics.SpeculativeStale, metrics.SpeculativeReused, len(changed), len(txs)-len(changed))
+	}
+	if pipelined.stateDB.GetRoot() != fresh.stateDB.GetRoot() {
+		t.Fatal("block built from a stale candidate differs from building it from scratch")
+	}
+	for _, name := range changed {
+		want := fresh.stateDB.GetBalance(testAddress(name))
+		if got := pipelined.stateDB.GetBalance(testAddress(name)); got.Cmp(want) != 0 {
+			t.Fatalf("%s holds %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestCandidateForAnotherHeightDiscarded(t *testing.T) {
+	d := newTestEngine(t, Config{})
+	d.builder.candidate = &blockCandidate{height: 2}
+	if candidate := d.builder.take(3); candidate != nil {
+		t.Fatal("candidate for block #2 taken for block #3")
+	}
+	if candidate := d.builder.take(2); candidate != nil {
+		t.Fatal("candidate kept after a take for another height")
+	}
+}
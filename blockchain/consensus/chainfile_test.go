@@ -0,0 +1,93 @@
+package consensus
+
+import (
+	"crypto/ed25519"
+	"strings"
+	"testing"
+)
+
+// validatorConfig has two genesis validators
+func validatorConfig() Config {
+	return Config{GenesisValidators: []string{testAddress("validator-a"), testAddress("validator-b")}}
+}
+
+// scheduledProposer is the name of the validator scheduled for block #1
+func scheduledProposer(t *testing.T) string {
+	t.Helper()
+	d := NewDPoSBFT(validatorConfig())
+	for _, name := range []string{"validator-a", "validator-b"} {
+		if d.selectProposer() == testAddress(name) {
+			return name
+		}
+	}
+	t.Fatal("no genesis validator scheduled")
+	return ""
+}
+
+// sealedBlock builds an empty block #1 proposed by validator and signed with key
+func sealedBlock(validator string, key ed25519.PrivateKey) *Block {
+	block := &Block{
+		Number:       1,
+		PreviousHash: GenesisHeader().Hash,
+		Timestamp:    1,
+		Validator:    validator,
+		GasLimit:     BlockGasLimit,
+	}
+	twin := NewDPoSBFT(validatorConfig())
+	twin.executeTransactions(block)
+	block.StateRoot = twin.stateRoot()
+	block.Hash = twin.calculateBlockHash(block)
+	block.Signature = signHash(key, sealHash(block))
+	return block
+}
+
+// importBlock imports a block into a fresh follower
+func importBlock(block *Block) error {
+	d := NewDPoSBFT(validatorConfig())
+	d.SetStore(newTestStore())
+	return d.ImportBlock(block)
+}
+
+func TestImportBlockChecksProposer(t *testing.T) {
+	proposer := scheduledProposer(t)
+	other := "validator-a"
+	if proposer == other {
+		other = "validator-b"
+	}
+
+	if err := importBlock(sealedBlock(testAddress(proposer), testKey(proposer))); err != nil {
+		t.Fatalf("block from the scheduled proposer rejected: %v", err)
+	}
+
+	keyless := sealedBlock(testAddress(proposer), testKey(proposer))
+	keyless.Signature = keylessSignature(keyless.Hash, keyless.Validator)
+
+	tampered := sealedBlock(testAddress(proposer), testKey(proposer))
+	tampered.GasLimit = 2 * BlockGasLimit
+
+	cases := []struct {
+		name  string
+		block *Block
+		err   string
+	}{
+		{"keyless signature", keyless, "invalid signature"},
+		{"signed by another key", sealedBlock(testAddress(proposer), testKey("mallory")), "invalid signature"},
+		{"unregistered proposer", sealedBlock(testAddress("mallory"), testKey("mallory")), "not an active validator"},
+		{"out of turn", sealedBlock(testAddress(other), testKey(other)), "scheduled proposer"},
+		{"sealed fields changed", tampered, "invalid signature"},
+	}
+	for _, c := range cases {
+		if err := importBlock(c.block); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: got %v, want %q", c.name, err, c.err)
+		}
+	}
+}
+
+func TestProposerScheduleIsDeterministic(t *testing.T) {
+	want := scheduledProposer(t)
+	for i := 0; i < 20; i++ {
+		if got := scheduledProposer(t); got != want {
+			t.Fatalf("proposer changed from %s to %s", want, got)
+		}
+	}
+}
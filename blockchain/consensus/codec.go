@@ -0,0 +1,501 @@
+package consensus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Canonical binary encoding of chain records.
+//
+// Every record starts with a codec version byte followed by its fields in
+// declaration order. Unsigned integers are uvarints, signed integers
+// zig-zag varints, strings and byte slices are length-prefixed, big
+// integers are a sign byte (0 nil, 1 non-negative, 2 negative) followed by
+// the length-prefixed magnitude, and lists are a count followed by their
+// elements. The same value always encodes to the same bytes.
+
+// CodecVersion is the version byte written at the start of every record
+const CodecVersion byte = 1
+
+// encoder appends canonical encodings to a buffer
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) int(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) float(v float64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+func (e *encoder) bytes(v []byte) {
+	e.uint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) string(v string) {
+	e.uint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) bigInt(v *big.Int) {
+	switch {
+	case v == nil:
+		e.buf = append(e.buf, 0)
+		return
+	case v.Sign() < 0:
+		e.buf = append(e.buf, 2)
+	default:
+		e.buf = append(e.buf, 1)
+	}
+	e.bytes(v.Bytes())
+}
+
+// decoder reads canonical encodings. The first error is kept and every
+// later read returns a zero value, so callers check err once at the end.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (d *decoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail("invalid uvarint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) int() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail("invalid varint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) < 1 {
+		d.fail("unexpected end of record")
+		return 0
+	}
+	v := d.data[0]
+	d.data = d.data[1:]
+	return v
+}
+
+func (d *decoder) bool() bool {
+	switch d.byte() {
+	case 0:
+		return false
+	case 1:
+		return true
+	}
+	d.fail("invalid bool")
+	return false
+}
+
+func (d *decoder) float() float64 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) < 8 {
+		d.fail("unexpected end of record")
+		return 0
+	}
+	v := math.Float64frombits(binary.BigEndian.Uint64(d.data))
+	d.data = d.data[8:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uint()
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.data)) < n {
+		d.fail("unexpected end of record")
+		return nil
+	}
+	if n == 0 {
+		return nil
+	}
+	v := make([]byte, n)
+	copy(v, d.data[:n])
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) bigInt() *big.Int {
+	sign := d.byte()
+	if sign == 0 || d.err != nil {
+		return nil
+	}
+	if sign > 2 {
+		d.fail("invalid big integer sign")
+		return nil
+	}
+	v := new(big.Int).SetBytes(d.bytes())
+	if sign == 2 {
+		v.Neg(v)
+	}
+	return v
+}
+
+// count reads a list length, rejecting lengths the remaining data cannot hold
+func (d *decoder) count() int {
+	n := d.uint()
+	if n > uint64(len(d.data)) {
+		d.fail("list length %d exceeds record", n)
+		return 0
+	}
+	return int(n)
+}
+
+// marshalRecord encodes a record behind the codec version byte
+func marshalRecord(encode func(e *encoder)) []byte {
+	e := &encoder{buf: []byte{CodecVersion}}
+	encode(e)
+	return e.buf
+}
+
+// unmarshalRecord checks the version byte, decodes a record and rejects trailing bytes
+func unmarshalRecord(data []byte, kind string, decode func(d *decoder)) error {
+	if len(data) == 0 {
+		return fmt.Errorf("empty %s record", kind)
+	}
+	if data[0] != CodecVersion {
+		return fmt.Errorf("unsupported %s record version %d", kind, data[0])
+	}
+	d := &decoder{data: data[1:]}
+	decode(d)
+	if d.err == nil && len(d.data) > 0 {
+		d.fail("%d trailing bytes", len(d.data))
+	}
+	if d.err != nil {
+		return fmt.Errorf("invalid %s record: %w", kind, d.err)
+	}
+	return nil
+}
+
+// Account is the stored view of an account's native state
+type Account struct {
+	Address string   `json:"address"`
+	Balance *big.Int `json:"balance"`
+	Nonce   uint64   `json:"nonce"`
+}
+
+// GetAccount returns an account's balance and nonce
+func (s *StateDB) GetAccount(address string) *Account {
+	return &Account{
+		Address: address,
+		Balance: new(big.Int).Set(s.GetBalance(address)),
+		Nonce:   s.GetNonce(address),
+	}
+}
+
+func (tx *Transaction) encode(e *encoder) {
+	e.uint(uint64(tx.Type))
+	e.string(tx.Hash)
+	e.string(tx.From)
+	e.string(tx.To)
+	e.bigInt(tx.Value)
+	e.uint(tx.Nonce)
+	e.bigInt(tx.GasPrice)
+	e.uint(tx.GasLimit)
+	e.bytes(tx.Data)
+	e.string(tx.Signature)
+	e.uint(uint64(len(tx.Approvals)))
+	for _, approval := range tx.Approvals {
+		e.string(approval.Signer)
+		e.string(approval.Signature)
+	}
+	e.string(tx.FeePayer)
+	e.string(tx.FeePayerSignature)
+}
+
+func (tx *Transaction) decode(d *decoder) {
+	tx.Type = TxType(d.uint())
+	tx.Hash = d.string()
+	tx.From = d.string()
+	tx.To = d.string()
+	tx.Value = d.bigInt()
+	tx.Nonce = d.uint()
+	tx.GasPrice = d.bigInt()
+	tx.GasLimit = d.uint()
+	tx.Data = d.bytes()
+	tx.Signature = d.string()
+	if n := d.count(); n > 0 {
+		tx.Approvals = make([]TxApproval, n)
+		for i := range tx.Approvals {
+			tx.Approvals[i].Signer = d.string()
+			tx.Approvals[i].Signature = d.string()
+		}
+	}
+	tx.FeePayer = d.string()
+	tx.FeePayerSignature = d.string()
+}
+
+// MarshalBinary returns the canonical encoding of a transaction
+func (tx *Transaction) MarshalBinary() ([]byte, error) {
+	return marshalRecord(tx.encode), nil
+}
+
+// UnmarshalBinary decodes a transaction written by MarshalBinary
+func (tx *Transaction) UnmarshalBinary(data []byte) error {
+	return unmarshalRecord(data, "transaction", tx.decode)
+}
+
+func (b *Block) encode(e *encoder) {
+	e.uint(b.Number)
+	e.string(b.Hash)
+	e.string(b.PreviousHash)
+	e.int(b.Timestamp)
+	e.uint(uint64(len(b.Transactions)))
+	for _, tx := range b.Transactions {
+		tx.encode(e)
+	}
+	e.string(b.Validator)
+	e.string(b.Signature)
+	e.string(b.StateRoot)
+	e.string(b.TxRoot)
+	e.uint(b.GasUsed)
+	e.uint(b.GasLimit)
+	encodeBloom(e, b.LogsBloom)
+}
+
+func (b *Block) decode(d *decoder) {
+	b.Number = d.uint()
+	b.Hash = d.string()
+	b.PreviousHash = d.string()
+	b.Timestamp = d.int()
+	b.Transactions = make([]*Transaction, d.count())
+	for i := range b.Transactions {
+		b.Transactions[i] = new(Transaction)
+		b.Transactions[i].decode(d)
+	}
+	b.Validator = d.string()
+	b.Signature = d.string()
+	b.StateRoot = d.string()
+	b.TxRoot = d.string()
+	b.GasUsed = d.uint()
+	b.GasLimit = d.uint()
+	b.LogsBloom = decodeBloom(d)
+}
+
+// MarshalBinary returns the canonical encoding of a block and its transactions
+func (b *Block) MarshalBinary() ([]byte, error) {
+	return marshalRecord(b.encode), nil
+}
+
+// UnmarshalBinary decodes a block written by MarshalBinary
+func (b *Block) UnmarshalBinary(data []byte) error {
+	return unmarshalRecord(data, "block", b.decode)
+}
+
+// encodeBloom writes a bloom, or an empty value when no bit is set
+func encodeBloom(e *encoder, bloom Bloom) {
+	if bloom == (Bloom{}) {
+		e.bytes(nil)
+		return
+	}
+	e.bytes(bloom[:])
+}
+
+// decodeBloom reads a bloom written by encodeBloom
+func decodeBloom(d *decoder) Bloom {
+	var bloom Bloom
+	raw := d.bytes()
+	if len(raw) != 0 && len(raw) != len(bloom) {
+		d.fail("bloom is %d bytes, want %d", len(raw), len(bloom))
+		return bloom
+	}
+	copy(bloom[:], raw)
+	return bloom
+}
+
+func (l *Log) encode(e *encoder) {
+	e.string(l.Address)
+	e.uint(uint64(len(l.Topics)))
+	for _, topic := range l.Topics {
+		e.string(topic)
+	}
+	e.bytes(l.Data)
+	e.uint(l.BlockNumber)
+	e.string(l.TxHash)
+	e.uint(uint64(l.TxIndex))
+	e.uint(uint64(l.LogIndex))
+}
+
+func (l *Log) decode(d *decoder) {
+	l.Address = d.string()
+	l.Topics = make([]string, d.count())
+	for i := range l.Topics {
+		l.Topics[i] = d.string()
+	}
+	l.Data = d.bytes()
+	l.BlockNumber = d.uint()
+	l.TxHash = d.string()
+	l.TxIndex = uint(d.uint())
+	l.LogIndex = uint(d.uint())
+}
+
+func (r *Receipt) encode(e *encoder) {
+	e.uint(uint64(r.Type))
+	e.string(r.TxHash)
+	e.uint(uint64(r.TxIndex))
+	e.uint(r.BlockNumber)
+	e.uint(r.Status)
+	e.uint(r.GasUsed)
+	e.uint(r.CumulativeGasUsed)
+	e.uint(uint64(len(r.Logs)))
+	for _, log := range r.Logs {
+		log.encode(e)
+	}
+	encodeBloom(e, r.Bloom)
+	e.string(r.ContractAddress)
+	e.string(r.Error)
+}
+
+func (r *Receipt) decode(d *decoder) {
+	r.Type = TxType(d.uint())
+	r.TxHash = d.string()
+	r.TxIndex = uint(d.uint())
+	r.BlockNumber = d.uint()
+	r.Status = d.uint()
+	r.GasUsed = d.uint()
+	r.CumulativeGasUsed = d.uint()
+	r.Logs = make([]*Log, d.count())
+	for i := range r.Logs {
+		r.Logs[i] = new(Log)
+		r.Logs[i].decode(d)
+	}
+	r.Bloom = decodeBloom(d)
+	r.ContractAddress = d.string()
+	r.Error = d.string()
+}
+
+// MarshalBinary returns the canonical encoding of a receipt
+func (r *Receipt) MarshalBinary() ([]byte, error) {
+	return marshalRecord(r.encode), nil
+}
+
+// UnmarshalBinary decodes a receipt written by MarshalBinary
+func (r *Receipt) UnmarshalBinary(data []byte) error {
+	return unmarshalRecord(data, "receipt", r.decode)
+}
+
+func (v *Validator) encode(e *encoder) {
+	e.string(v.Address)
+	e.bigInt(v.Stake)
+	e.bigInt(v.DelegatedStake)
+	e.float(v.Commission)
+	e.bool(v.IsActive)
+	e.uint(v.VotingPower)
+	e.uint(v.BlocksProduced)
+	e.uint(v.MissedBlocks)
+}
+
+func (v *Validator) decode(d *decoder) {
+	v.Address = d.string()
+	v.Stake = d.bigInt()
+	v.DelegatedStake = d.bigInt()
+	v.Commission = d.float()
+	v.IsActive = d.bool()
+	v.VotingPower = d.uint()
+	v.BlocksProduced = d.uint()
+	v.MissedBlocks = d.uint()
+}
+
+// MarshalBinary returns the canonical encoding of a validator
+func (v *Validator) MarshalBinary() ([]byte, error) {
+	return marshalRecord(v.encode), nil
+}
+
+// UnmarshalBinary decodes a validator written by MarshalBinary
+func (v *Validator) UnmarshalBinary(data []byte) error {
+	return unmarshalRecord(data, "validator", v.decode)
+}
+
+// MarshalBinary returns the canonical encoding of an account
+func (a *Account) MarshalBinary() ([]byte, error) {
+	return marshalRecord(func(e *encoder) {
+		e.string(a.Address)
+		e.bigInt(a.Balance)
+		e.uint(a.Nonce)
+	}), nil
+}
+
+// UnmarshalBinary decodes an account written by MarshalBinary
+func (a *Account) UnmarshalBinary(data []byte) error {
+	return unmarshalRecord(data, "account", func(d *decoder) {
+		a.Address = d.string()
+		a.Balance = d.bigInt()
+		a.Nonce = d.uint()
+	})
+}
+
+// MarshalBinary returns the canonical encoding of a supply breakdown
+func (s *SupplyInfo) MarshalBinary() ([]byte, error) {
+	return marshalRecord(func(e *encoder) {
+		e.uint(s.Height)
+		e.bigInt(s.Total)
+		e.bigInt(s.Circulating)
+		e.bigInt(s.Staked)
+		e.bigInt(s.Burned)
+		e.bigInt(s.Locked)
+		e.bigInt(s.MaxSupply)
+	}), nil
+}
+
+// UnmarshalBinary decodes a supply breakdown written by MarshalBinary
+func (s *SupplyInfo) UnmarshalBinary(data []byte) error {
+	return unmarshalRecord(data, "supply", func(d *decoder) {
+		s.Height = d.uint()
+		s.Total = d.bigInt()
+		s.Circulating = d.bigInt()
+		s.Staked = d.bigInt()
+		s.Burned = d.bigInt()
+		s.Locked = d.bigInt()
+		s.MaxSupply = d.bigInt()
+	})
+}
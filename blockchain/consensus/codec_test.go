@@ -0,0 +1,137 @@
+package consensus
+
+import (
+	"bytes"
+	"encoding"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// binaryRecord is a value with the canonical binary encoding
+type binaryRecord interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// codecRecords returns one populated value of every record type, with a
+// function allocating an empty one to decode into
+func codecRecords() map[string]struct {
+	value binaryRecord
+	alloc func() binaryRecord
+} {
+	tx := transfer("alice", "bob", 7, 3)
+	tx.Approvals = []TxApproval{{Signer: testAddress("admin"), Signature: "0xsig"}}
+	receipt := &Receipt{
+		Type:        TxCall,
+		TxHash:      tx.Hash,
+		BlockNumber: 9,
+		Status:      ReceiptStatusFailed,
+		GasUsed:     21000,
+		Logs: []*Log{{
+			Address: testAddress("contract"),
+			Topics:  []string{"0x" + strings.Repeat("ab", 32)},
+			Data:    []byte{1, 2, 3},
+			TxHash:  tx.Hash,
+		}},
+		Error: "execution reverted",
+	}
+	receipt.Bloom = CreateBloom([]*Receipt{receipt})
+
+	return map[string]struct {
+		value binaryRecord
+		alloc func() binaryRecord
+	}{
+		"transaction": {tx, func() binaryRecord { return new(Transaction) }},
+		"block": {&Block{
+			Number:       9,
+			Hash:         "0xblock9",
+			PreviousHash: "0xblock8",
+			Timestamp:    -1,
+			Transactions: []*Transaction{tx},
+			StateRoot:    "0xroot",
+			LogsBloom:    receipt.Bloom,
+		}, func() binaryRecord { return new(Block) }},
+		"receipt": {receipt, func() binaryRecord { return new(Receipt) }},
+		"validator": {&Validator{
+			Address:        testAddress("validator"),
+			Stake:          vnc(10000),
+			DelegatedStake: big.NewInt(-1),
+			Commission:     0.05,
+			IsActive:       true,
+		}, func() binaryRecord { return new(Validator) }},
+		"account": {&Account{Address: testAddress("alice"), Balance: vnc(1000), Nonce: 4},
+			func() binaryRecord { return new(Account) }},
+		"supply": {&SupplyInfo{Height: 9, Total: vnc(1), MaxSupply: vnc(2)},
+			func() binaryRecord { return new(SupplyInfo) }},
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for kind, record := range codecRecords() {
+		data, err := record.value.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data[0] != CodecVersion {
+			t.Fatalf("%s record starts with %d, want the codec version %d", kind, data[0], CodecVersion)
+		}
+
+		decoded := record.alloc()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		again, _ := decoded.MarshalBinary()
+		if !bytes.Equal(again, data) {
+			t.Fatalf("%s record changed in a round trip", kind)
+		}
+	}
+}
+
+func TestCodecPreservesFields(t *testing.T) {
+	records := codecRecords()
+	data, _ := records["block"].value.MarshalBinary()
+	var block Block
+	if err := block.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	want := records["block"].value.(*Block)
+	tx := block.Transactions[0]
+	if block.Timestamp != -1 || block.LogsBloom != want.LogsBloom || len(block.Transactions) != 1 ||
+		tx.Value.Int64() != 7 || tx.Approvals[0] != want.Transactions[0].Approvals[0] ||
+		!VerifyTransactionSignature(tx) {
+		t.Fatalf("decoded block %+v differs from the encoded one", block)
+	}
+
+	data, _ = records["validator"].value.MarshalBinary()
+	var validator Validator
+	if err := validator.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if validator.Stake.Cmp(vnc(10000)) != 0 || validator.DelegatedStake.Int64() != -1 ||
+		validator.Commission != 0.05 || !validator.IsActive {
+		t.Fatalf("decoded validator %+v differs from the encoded one", validator)
+	}
+}
+
+func TestCodecRejectsMalformedRecords(t *testing.T) {
+	data, _ := codecRecords()["receipt"].value.MarshalBinary()
+	cases := map[string][]byte{
+		"empty":     nil,
+		"version":   append([]byte{CodecVersion + 1}, data[1:]...),
+		"truncated": data[:len(data)-1],
+		"trailing":  append(append([]byte(nil), data...), 0),
+	}
+	want := map[string]string{
+		"empty":     "empty receipt record",
+		"version":   "unsupported receipt record version 2",
+		"truncated": "invalid receipt record",
+		"trailing":  "1 trailing bytes",
+	}
+	for name, input := range cases {
+		err := new(Receipt).UnmarshalBinary(input)
+		if err == nil || !strings.Contains(err.Error(), want[name]) {
+			t.Fatalf("%s: got %v, want %q", name, err, want[name])
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package consensus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"vnc-blockchain/vm"
+)
+
+// GetCode returns the code deployed at an address
+func (s *StateDB) GetCode(address string) []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.code[address]
+}
+
+// GetStorage returns a contract storage slot. It implements vm.StateReader.
+func (s *StateDB) GetStorage(address string, key vm.Word) vm.Word {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if slots, exists := s.storage[address]; exists {
+		return slots[key]
+	}
+	return vm.Word{}
+}
+
+// commitContractState stores code (when non-nil) and storage writes for a contract
+func (s *StateDB) commitContractState(address string, code []byte, result *vm.Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if code != nil {
+		s.code[address] = code
+	}
+	if len(result.WriteOrder) == 0 {
+		return
+	}
+
+	slots, exists := s.storage[address]
+	if !exists {
+		slots = make(map[vm.Word]vm.Word)
+		s.storage[address] = slots
+	}
+	for _, key := range result.WriteOrder {
+		value := result.StorageWrites[key]
+		if value == (vm.Word{}) {
+			delete(slots, key)
+		} else {
+			slots[key] = value
+		}
+	}
+}
+
+// contractDigest hashes all contract code and storage in address/slot order.
+// Caller must hold s.mu.
+func (s *StateDB) contractDigest() string {
+	addresses := make([]string, 0, len(s.code))
+	for address := range s.code {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	h := sha256.New()
+	for _, address := range addresses {
+		codeHash := sha256.Sum256(s.code[address])
+		fmt.Fprintf(h, "%s:%x;", address, codeHash)
+
+		slots := s.storage[address]
+		keys := make([]vm.Word, 0, len(slots))
+		for key := range slots {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return string(keys[i][:]) < string(keys[j][:])
+		})
+		for _, key := range keys {
+			value := slots[key]
+			fmt.Fprintf(h, "%x=%x;", key, value)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ContractAddress derives the address of a contract deployed by sender at nonce
+func ContractAddress(sender string, nonce uint64) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", sender, nonce)))
+	return "0x" + hex.EncodeToString(hash[:20])
+}
+
+// intrinsicGas is the gas charged before any code runs
+func intrinsicGas(tx *Transaction) uint64 {
+	gas := vm.GasTxCall
+	if tx.Type == TxDeploy {
+		gas = vm.GasTxDeploy
+	}
+	return gas + uint64(len(tx.Data))*vm.GasTxDataByte
+}
+
+// applyContractTransaction deploys or calls a contract. Code runs in the
+// sandboxed VM metered against tx.GasLimit; its storage writes and logs are
+// only applied if execution succeeds.
+func (d *DPoSBFT) applyContractTransaction(tx *Transaction, block *Block) *TxResult {
+	intrinsic := intrinsicGas(tx)
+	if tx.GasLimit < intrinsic {
+		return &TxResult{Err: fmt.Errorf("gas limit %d below intrinsic gas %d", tx.GasLimit, intrinsic)}
+	}
+	if d.stateDB.IsFrozen(tx.From) {
+		return &TxResult{Err: fmt.Errorf("account %s is frozen", tx.From)}
+	}
+	spendable := d.stateDB.GetSpendableBalance(tx.From, block.Timestamp)
+	if tx.Value.Cmp(spendable) > 0 {
+		return &TxResult{Err: fmt.Errorf("insufficient spendable balance: have %s, need %s",
+			spendable.String(), tx.Value.String())}
+	}
+
+	ctx := &vm.Context{
+		Caller:      tx.From,
+		Value:       tx.Value,
+		Gas:         tx.GasLimit - intrinsic,
+		BlockNumber: block.Number,
+		Timestamp:   block.Timestamp,
+	}
+
+	switch tx.Type {
+	case TxDeploy:
+		ctx.Address = ContractAddress(tx.From, d.stateDB.GetNonce(tx.From))
+		if len(d.stateDB.GetCode(ctx.Address)) > 0 {
+			return &TxResult{Err: fmt.Errorf("contract already deployed at %s", ctx.Address)}
+		}
+		ctx.Code = tx.Data
+	case TxCall:
+		ctx.Address = tx.To
+		ctx.Code = d.stateDB.GetCode(tx.To)
+		if len(ctx.Code) == 0 {
+			return &TxResult{Err: fmt.Errorf("no contract code at %s", tx.To)}
+		}
+		ctx.Input = tx.Data
+	}
+
+	result := vm.Execute(ctx, d.stateDB)
+	gasUsed := intrinsic + result.GasUsed
+
+	var code []byte
+	if !result.Failed() && tx.Type == TxDeploy {
+		// Runtime code is whatever the init code returned
+		code = result.ReturnData
+		deposit := uint64(len(code)) * vm.GasCodeDeposit
+		switch {
+		case len(code) > vm.MaxCodeSize:
+			result.Err = vm.ErrCodeSizeExceeded
+			gasUsed = tx.GasLimit
+		case gasUsed+deposit > tx.GasLimit:
+			result.Err = vm.ErrOutOfGas
+			gasUsed = tx.GasLimit
+		default:
+			gasUsed += deposit
+		}
+	}
+
+	if result.Failed() {
+		// Failed executions are still included; state does not change
+		return &TxResult{
+			GasUsed:         gasUsed,
+			ContractAddress: contractAddressFor(tx, ctx.Address),
+			Err:             result.Err,
+		}
+	}
+
+	// Move the call value into the contract (also advances the nonce).
+	// The execution's gas is charged even if the value cannot move.
+	transfer := *tx
+	transfer.To = ctx.Address
+	if err := d.applyTransfer(&transfer, block.Timestamp); err != nil {
+		return &TxResult{GasUsed: gasUsed, Err: err}
+	}
+	d.stateDB.commitContractState(ctx.Address, code, result)
+
+	return &TxResult{
+		GasUsed:         gasUsed,
+		Logs:            convertLogs(result.Logs),
+		ContractAddress: contractAddressFor(tx, ctx.Address),
+	}
+}
+
+// contractAddressFor returns the created contract address for deploy transactions
+func contractAddressFor(tx *Transaction, address string) string {
+	if tx.Type == TxDeploy {
+		return address
+	}
+	return ""
+}
+
+// GetCode returns the code of a deployed contract
+func (d *DPoSBFT) GetCode(address string) []byte {
+	return d.stateDB.GetCode(address)
+}
+
+// GetStorageAt returns a contract storage slot
+func (d *DPoSBFT) GetStorageAt(address string, key vm.Word) vm.Word {
+	return d.stateDB.GetStorage(address, key)
+}
@@ -0,0 +1,82 @@
+package consensus
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"vnc-blockchain/vm"
+)
+
+// storeInput is runtime code that stores the first call data word in slot 0
+var storeInput = []byte{byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+
+// deployCode is init code that returns runtime as the contract code
+func deployCode(runtime []byte) []byte {
+	size := byte(len(runtime))
+	init := []byte{
+		byte(vm.PUSH1), size, byte(vm.PUSH1), 12, byte(vm.PUSH1), 0, byte(vm.CODECOPY),
+		byte(vm.PUSH1), size, byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}
+	return append(init, runtime...)
+}
+
+func TestContractDeployAndCall(t *testing.T) {
+	d := newTestEngine(t, Config{ExecutionWorkers: 1}, "alice")
+	deploy := signedTx(testKey("alice"), &Transaction{Type: TxDeploy, GasLimit: 200_000, Data: deployCode(storeInput)})
+	receipt := runBlock(t, d, deploy)[0]
+	requireStatus(t, receipt, ReceiptStatusSuccess)
+	contract := receipt.ContractAddress
+	if contract != ContractAddress(testAddress("alice"), 0) {
+		t.Fatalf("contract deployed at %s, want %s", contract, ContractAddress(testAddress("alice"), 0))
+	}
+	if code := d.GetCode(contract); !bytes.Equal(code, storeInput) {
+		t.Fatalf("contract code %x, want the runtime %x", code, storeInput)
+	}
+
+	input := vm.Word{31: 42}
+	call := signedTx(testKey("alice"), &Transaction{Type: TxCall, To: contract, Nonce: 1, GasLimit: 100_000, Data: input[:]})
+	before := new(big.Int).Set(d.stateDB.GetBalance(testAddress("alice")))
+	receipt = runBlock(t, d, call)[0]
+	requireStatus(t, receipt, ReceiptStatusSuccess)
+	if slot := d.GetStorageAt(contract, vm.Word{}); slot != input {
+		t.Fatalf("slot 0 holds %x, want %x", slot, input)
+	}
+
+	// Only the gas used is charged; the rest of the limit is refunded
+	if receipt.GasUsed >= call.GasLimit {
+		t.Fatalf("call used its whole gas limit %d", call.GasLimit)
+	}
+	charged := new(big.Int).Sub(before, d.stateDB.GetBalance(testAddress("alice")))
+	if charged.Uint64() != receipt.GasUsed {
+		t.Fatalf("charged %s for %d gas at price 1", charged, receipt.GasUsed)
+	}
+}
+
+func TestContractOutOfGas(t *testing.T) {
+	d := newTestEngine(t, Config{ExecutionWorkers: 1}, "alice")
+	deploy := signedTx(testKey("alice"), &Transaction{Type: TxDeploy, GasLimit: 200_000, Data: deployCode(storeInput)})
+	contract := runBlock(t, d, deploy)[0].ContractAddress
+
+	// Enough for the intrinsic gas but not for the store
+	input := vm.Word{31: 42}
+	call := signedTx(testKey("alice"), &Transaction{Type: TxCall, To: contract, Nonce: 1, Data: input[:]})
+	call.GasLimit = intrinsicGas(call) + 100
+	SignTransaction(call, testKey("alice"))
+
+	before := new(big.Int).Set(d.stateDB.GetBalance(testAddress("alice")))
+	receipt := runBlock(t, d, call)[0]
+	requireStatus(t, receipt, ReceiptStatusFailed)
+	if receipt.Error != vm.ErrOutOfGas.Error() || receipt.GasUsed != call.GasLimit {
+		t.Fatalf("got %q using %d gas, want out of gas using the limit %d", receipt.Error, receipt.GasUsed, call.GasLimit)
+	}
+	if slot := d.GetStorageAt(contract, vm.Word{}); slot != (vm.Word{}) {
+		t.Fatalf("failed call wrote slot 0: %x", slot)
+	}
+	charged := new(big.Int).Sub(before, d.stateDB.GetBalance(testAddress("alice")))
+	if charged.Uint64() != call.GasLimit {
+		t.Fatalf("charged %s, want the whole limit %d", charged, call.GasLimit)
+	}
+	if nonce := d.stateDB.GetNonce(testAddress("alice")); nonce != 2 {
+		t.Fatalf("nonce %d after the failed call, want 2", nonce)
+	}
+}
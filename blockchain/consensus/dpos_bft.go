@@ -1,12 +1,15 @@
 package consensus
 
 import (
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"sort"
 	"sync"
 	"time"
+	"vnc-blockchain/vm"
 )
 
 // DPoSBFT implements Delegated Proof of Stake with Byzantine Fault Tolerance
@@ -21,6 +24,18 @@ type DPoSBFT struct {
 	mempool        *Mempool
 	stateDB        *StateDB
 	isRunning      bool
+	lastBlockTime  int64
+	lastBlockHash  string
+	supplyHistory  map[uint64]*SupplyInfo
+	receipts       map[string]*Receipt
+	blockReceipts  map[uint64][]*Receipt
+	blockBlooms    map[uint64]Bloom
+	store          BlockStore
+	builder        *blockBuilder
+	prepared       *blockCandidate // candidate in use while a block is produced
+	unpersisted    *Block          // executed block whose commit failed, retried before the next
+	validatorKeys  map[string]ed25519.PrivateKey // keys of the validators this node proposes for
+	metrics        *BlockMetrics
 }
 
 // Config holds consensus configuration
@@ -31,6 +46,11 @@ type Config struct {
 	FinalityBlocks    int
 	MinValidatorStake float64
 	QuantumSecured    bool // Enable quantum security features
+	AdminRoles        map[string]AdminRole // address -> on-chain admin role
+	PauseThreshold    int                  // admin signatures required to pause/resume
+	ExecutionWorkers  int                  // parallel execution workers (0 = NumCPU, 1 = serial)
+	PriceBump         uint64               // min gas price increase (%) to replace a pending tx (0 = 10)
+	GenesisValidators []string             // validators active from genesis, staked at MinValidatorStake
 }
 
 // Validator represents a network validator
@@ -58,56 +78,124 @@ type Block struct {
 	TxRoot        string
 	GasUsed       uint64
 	GasLimit      uint64
-}
+	LogsBloom     Bloom
+}
+
+// TxType identifies how the state machine interprets a transaction
+type TxType uint8
+
+const (
+	TxTransfer          TxType = iota // Native VNC transfer (default)
+	TxMint                            // Privileged: create new supply
+	TxBurn                            // Privileged: destroy supply
+	TxFreeze                          // Privileged: freeze an account's outgoing transfers
+	TxUnfreeze                        // Privileged: lift an account freeze
+	TxPause                           // Privileged multi-sig: halt user transactions
+	TxResume                          // Privileged multi-sig: resume user transactions
+	TxDeploy                          // Deploy contract: Data is init code
+	TxCall                            // Call contract at To: Data is call data
+	TxTimeLock                        // Escrow Value for To until the target in Data
+	TxTimeLockCancel                  // Refund an immature time-lock: Data is the lock ID
+	TxAssetCreate                     // Create an asset; Value is minted to the issuer
+	TxAssetTransfer                   // Move Value of an asset to To
+	TxAssetApprove                    // Allow To to spend Value of the sender's asset
+	TxAssetTransferFrom               // Spend an allowance: move Value from the payload owner to To
+	TxAssetMint                       // Issuer-only: mint Value of an asset to To
+	TxSponsorPolicy                   // Set which meta-transactions the sender pays fees for
+	TxEscrowCreate                    // Deposit Value in escrow for To with the terms in Data
+	TxEscrowRelease                   // Pay an escrow to its beneficiary: Data is the escrow ID
+	TxEscrowRefund                    // Return an escrow to its depositor: Data is the escrow ID
+	TxVestingGrant                    // Privileged: mint Value to To, locked by the schedule in Data
+)
 
 // Transaction represents a blockchain transaction
 type Transaction struct {
-	Hash     string
-	From     string
-	To       string
-	Value    *big.Int
-	Nonce    uint64
-	GasPrice *big.Int
-	GasLimit uint64
-	Data     []byte
-	Signature string
-}
-
-// Mempool holds pending transactions
-type Mempool struct {
-	transactions map[string]*Transaction
-	mu           sync.RWMutex
+	Type              TxType
+	Hash              string
+	From              string
+	To                string
+	Value             *big.Int
+	Nonce             uint64
+	GasPrice          *big.Int
+	GasLimit          uint64
+	Data              []byte
+	Signature         string
+	Approvals         []TxApproval // Co-signatures for multi-sig admin transactions
+	FeePayer          string       // Sponsor paying the fee of a meta-transaction
+	FeePayerSignature string
 }
 
 // StateDB manages blockchain state
 type StateDB struct {
-	balances map[string]*big.Int
-	nonces   map[string]uint64
-	mu       sync.RWMutex
+	balances        map[string]*big.Int
+	nonces          map[string]uint64
+	vesting         map[string]*VestingAccount
+	frozen          map[string]*FreezeRecord
+	freezeLog       []*FreezeEvent
+	pause           PauseState
+	code            map[string][]byte
+	storage         map[string]map[vm.Word]vm.Word
+	timeLocks       map[string]*TimeLock
+	assets          map[string]*Asset
+	assetBalances   map[assetKey]*big.Int
+	allowances      map[allowanceKey]*big.Int
+	sponsorPolicies map[string]*SponsorPolicy
+	escrows         map[string]*Escrow
+	dirty           map[string]struct{} // accounts changed since the last commit
+	totalSupply     *big.Int
+	burned          *big.Int
+	mu              sync.RWMutex
 }
 
 // NewDPoSBFT creates a new consensus engine
 func NewDPoSBFT(config Config) *DPoSBFT {
-	return &DPoSBFT{
+	d := &DPoSBFT{
 		config:        config,
 		validators:    make(map[string]*Validator),
 		pendingBlocks: make(map[uint64]*Block),
 		blockVotes:    make(map[uint64]map[string]bool),
-		mempool:       NewMempool(),
+		mempool:       NewMempool(config.PriceBump),
 		stateDB:       NewStateDB(),
+		supplyHistory: make(map[uint64]*SupplyInfo),
+		receipts:      make(map[string]*Receipt),
+		blockReceipts: make(map[uint64][]*Receipt),
+		blockBlooms:   make(map[uint64]Bloom),
+		metrics:       NewBlockMetrics(),
+		validatorKeys: make(map[string]ed25519.PrivateKey),
 		currentBlock:  0,
 		currentEpoch:  0,
 		isRunning:     false,
 	}
+	d.builder = newBlockBuilder(d)
+
+	genesisStake := big.NewInt(int64(config.MinValidatorStake * 1e18))
+	for _, address := range config.GenesisValidators {
+		d.addValidator(address, genesisStake, 0)
+	}
+	return d
+}
+
+// SetValidatorKey gives the engine the key of a validator it proposes
+// blocks for. Slots of validators without a key are left to other nodes.
+func (d *DPoSBFT) SetValidatorKey(key ed25519.PrivateKey) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.validatorKeys[AddressOf(key)] = key
 }
 
 // Start begins the consensus process
 func (d *DPoSBFT) Start() {
 	d.mu.Lock()
 	d.isRunning = true
+	if _, exists := d.supplyHistory[d.currentBlock]; !exists {
+		d.recordSupply(d.currentBlock, time.Now().Unix())
+	}
 	d.mu.Unlock()
 
 	fmt.Println("🎯 Consensus Engine Started")
+
+	// Prepare each next block while the current one is voted on
+	go d.builder.run()
 	
 	ticker := time.NewTicker(time.Duration(d.config.BlockTime) * time.Second)
 	defer ticker.Stop()
@@ -125,14 +213,22 @@ func (d *DPoSBFT) produceBlock() {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	// Select block proposer
+	// State already includes a block that failed to commit; nothing is
+	// built on it until it is stored
+	if d.unpersisted != nil && d.finalizeBlock(d.unpersisted) != nil {
+		return
+	}
+
+	// Select block proposer; only slots of local validators are produced here
 	proposer := d.selectProposer()
-	if proposer == "" {
+	key, local := d.validatorKeys[proposer]
+	if !local {
 		return
 	}
+	start := time.Now()
 
 	// Collect transactions from mempool
-	txs := d.mempool.GetPendingTransactions(1000)
+	txs := d.mempool.GetPendingTransactions(maxBlockTransactions, BlockGasLimit, d.stateDB.GetNonce)
 
 	// Create block
 	block := &Block{
@@ -141,24 +237,35 @@ func (d *DPoSBFT) produceBlock() {
 		Timestamp:    time.Now().Unix(),
 		Transactions: txs,
 		Validator:    proposer,
-		GasLimit:     30_000_000,
+		GasLimit:     BlockGasLimit,
 	}
 
+	// Included transactions leave the pool whether or not they succeed
+	d.mempool.RemoveTransactions(txs)
+
 	// Calculate transaction root
 	block.TxRoot = d.calculateTxRoot(txs)
 
-	// Execute transactions and update state
-	gasUsed := d.executeTransactions(txs)
+	// Execute transactions and update state, reusing the work the builder
+	// prepared for this height while the previous block was voted on
+	d.prepared = d.builder.take(block.Number)
+	d.metrics.recordCandidate(d.prepared != nil)
+	gasUsed := d.executeTransactions(block)
+	d.prepared = nil
 	block.GasUsed = gasUsed
+	block.LogsBloom = CreateBloom(d.blockReceipts[block.Number])
 
 	// Calculate state root
-	block.StateRoot = d.stateDB.GetRoot()
+	block.StateRoot = d.stateRoot()
+
+	// Record supply breakdown at this height
+	d.recordSupply(block.Number, block.Timestamp)
 
 	// Generate block hash
 	block.Hash = d.calculateBlockHash(block)
 
 	// Sign block
-	block.Signature = d.signBlock(block, proposer)
+	block.Signature = signHash(key, sealHash(block))
 
 	// Broadcast for validation
 	d.pendingBlocks[block.Number] = block
@@ -167,6 +274,11 @@ func (d *DPoSBFT) produceBlock() {
 	fmt.Printf("📦 Block #%d proposed by %s with %d transactions\n", 
 		block.Number, proposer[:10], len(txs))
 
+	d.metrics.Produce.Observe(time.Since(start))
+
+	// Build the next block optimistically while this one is voted on
+	d.builder.schedule(block.Number+1, block.Timestamp+int64(d.config.BlockTime))
+
 	// Simulate validation (in production, wait for network votes)
 	go d.validateBlock(block)
 }
@@ -185,6 +297,7 @@ func (d *DPoSBFT) selectProposer() string {
 
 // validateBlock validates a proposed block
 func (d *DPoSBFT) validateBlock(block *Block) {
+	proposed := time.Now()
 	time.Sleep(500 * time.Millisecond) // Simulate validation time
 
 	d.mu.Lock()
@@ -207,12 +320,24 @@ func (d *DPoSBFT) validateBlock(block *Block) {
 	requiredVotes := (len(d.validators) * 2) / 3
 	if len(votes) >= requiredVotes {
 		d.finalizeBlock(block)
+		d.metrics.Finalize.Observe(time.Since(proposed))
 	}
 }
 
-// finalizeBlock adds block to chain after consensus
-func (d *DPoSBFT) finalizeBlock(block *Block) {
+// finalizeBlock commits block to the store and adds it to the chain after
+// consensus. If the commit fails the head stays put and the block is held
+// for produceBlock to retry.
+func (d *DPoSBFT) finalizeBlock(block *Block) error {
+	if err := d.persistBlock(block); err != nil {
+		d.unpersisted = block
+		fmt.Printf("❌ Failed to persist block #%d, head held at #%d: %v\n", block.Number, d.currentBlock, err)
+		return err
+	}
+	d.unpersisted = nil
+
 	d.currentBlock = block.Number
+	d.lastBlockTime = block.Timestamp
+	d.lastBlockHash = block.Hash
 	delete(d.pendingBlocks, block.Number)
 	delete(d.blockVotes, block.Number)
 
@@ -221,8 +346,17 @@ func (d *DPoSBFT) finalizeBlock(block *Block) {
 		validator.BlocksProduced++
 	}
 
+	d.blockBlooms[block.Number] = block.LogsBloom
+	d.saveCheckpoint(block)
+	d.trimReceiptCache(block.Number)
+	d.metrics.recordFinalized(time.Now(), time.Duration(d.config.BlockTime)*time.Second)
+
+	// Pending transactions overtaken by this block can never execute
+	d.mempool.DropStale(d.stateDB.GetNonce)
+
 	fmt.Printf("✅ Block #%d finalized (Hash: %s...)\n", 
 		block.Number, block.Hash[:10])
+	return nil
 }
 
 // collectVotes simulates BFT voting
@@ -236,25 +370,182 @@ func (d *DPoSBFT) collectVotes(block *Block) map[string]bool {
 }
 
 // executeTransactions processes transactions and updates state
-func (d *DPoSBFT) executeTransactions(txs []*Transaction) uint64 {
+func (d *DPoSBFT) executeTransactions(block *Block) uint64 {
 	var totalGas uint64
+	fees := big.NewInt(0)
 
-	for _, tx := range txs {
-		// Verify signature
-		// Verify nonce
-		// Check balance
-		
-		// Execute transaction
-		d.stateDB.SubBalance(tx.From, tx.Value)
-		d.stateDB.AddBalance(tx.To, tx.Value)
-		d.stateDB.IncrementNonce(tx.From)
+	d.stateDB.checkAutoResume(block.Number)
+
+	// Matured time-locks pay out before user transactions; they wait out a pause
+	if !d.stateDB.IsPaused() {
+		d.stateDB.releaseTimeLocks(block.Number, block.Timestamp)
+	}
 
-		totalGas += 21000 // Base gas cost
+	// Receipts are rebuilt if a block at this height is produced again
+	delete(d.blockReceipts, block.Number)
+
+	txs := block.Transactions
+	for i := 0; i < len(txs); {
+		// Runs of plain transfers are executed in parallel; admin
+		// transactions act as barriers and run serially
+		if j := d.transferRunEnd(txs, i, block.GasLimit-totalGas); j-i >= minParallelRun {
+			errs := d.executeTransfersParallel(txs[i:j], block)
+			for k, err := range errs {
+				gas := d.recordResult(txs[i+k], block, newTxResult(err))
+				totalGas += gas
+				fees.Add(fees, txFee(txs[i+k], gas))
+			}
+			i = j
+			continue
+		}
+
+		tx := txs[i]
+		var result *TxResult
+		if limit, left := txGasLimit(tx), block.GasLimit-totalGas; limit > left {
+			result = newTxResult(fmt.Errorf("gas limit %d exceeds remaining block gas %d", limit, left))
+		} else {
+			result = d.executeTransaction(tx, block)
+		}
+		gas := d.recordResult(tx, block, result)
+		totalGas += gas
+		fees.Add(fees, txFee(tx, gas))
+		i++
 	}
 
+	// The proposer is paid once every transaction has run
+	if fees.Sign() > 0 {
+		d.stateDB.AddBalance(block.Validator, fees)
+	}
 	return totalGas
 }
 
+// transferRunEnd returns the end of the run of parallelizable transfers
+// starting at index i that fits in gasLeft
+func (d *DPoSBFT) transferRunEnd(txs []*Transaction, i int, gasLeft uint64) int {
+	if d.executionWorkers() < 2 || d.stateDB.IsPaused() {
+		return i
+	}
+	j := i
+	for j < len(txs) && txs[j].Type == TxTransfer && !txs[j].IsSponsored() {
+		// Transfers with negative amounts or an unusable gas limit are
+		// rejected serially
+		limit := txGasLimit(txs[j])
+		if checkAmounts(txs[j]) != nil || checkGasLimit(txs[j]) != nil || limit > gasLeft {
+			break
+		}
+		gasLeft -= limit
+		j++
+	}
+	return j
+}
+
+// executeTransaction verifies and applies a single transaction
+func (d *DPoSBFT) executeTransaction(tx *Transaction, block *Block) *TxResult {
+	if !d.verifySignature(tx) {
+		return newTxResult(fmt.Errorf("invalid transaction signature"))
+	}
+
+	// Only the sender's next nonce executes; a used nonce never does
+	if err := requireNonce(tx, d.stateDB.GetNonce(tx.From)); err != nil {
+		return newTxResult(err)
+	}
+
+	result := d.executeSigned(tx, block)
+
+	// Handlers leave the nonce to the dispatcher; only transfers, which also
+	// run in parallel, advance it themselves. An executed transaction uses
+	// its nonce even if it fails, so the sender's next transactions stay
+	// executable.
+	if d.stateDB.GetNonce(tx.From) == tx.Nonce {
+		d.stateDB.IncrementNonce(tx.From)
+	}
+	return result
+}
+
+// executeSigned applies a transaction whose signature and nonce are valid
+func (d *DPoSBFT) executeSigned(tx *Transaction, block *Block) *TxResult {
+	// Blocks keep flowing while paused, but only admin transactions execute
+	if d.stateDB.IsPaused() && !tx.Type.IsPrivileged() {
+		return newTxResult(fmt.Errorf("chain is paused"))
+	}
+	if err := checkAmounts(tx); err != nil {
+		return newTxResult(err)
+	}
+	if err := checkGasLimit(tx); err != nil {
+		return newTxResult(err)
+	}
+
+	// Meta-transaction: the fee payer covers gas, the sender only the value
+	if tx.IsSponsored() {
+		if err := d.verifySponsorship(tx); err != nil {
+			return newTxResult(err)
+		}
+	} else if tx.Type == TxTransfer {
+		return d.applyTransaction(tx, block)
+	}
+
+	paid, err := d.buyGas(tx, block)
+	if err != nil {
+		return newTxResult(err)
+	}
+	result := d.applyTransaction(tx, block)
+	d.refundGas(tx, paid, result.GasUsed)
+	return result
+}
+
+// recordResult stores the receipt for a transaction and returns the gas it used
+func (d *DPoSBFT) recordResult(tx *Transaction, block *Block, result *TxResult) uint64 {
+	if result.Err != nil {
+		fmt.Printf("⚠️  Transaction %s rejected: %v\n", tx.Hash, result.Err)
+	}
+
+	receipt := newReceipt(tx, block, d.blockReceipts[block.Number], result)
+	d.receipts[tx.Hash] = receipt
+	d.blockReceipts[block.Number] = append(d.blockReceipts[block.Number], receipt)
+
+	return result.GasUsed
+}
+
+// applyTransaction executes a single transaction against the state
+func (d *DPoSBFT) applyTransaction(tx *Transaction, block *Block) *TxResult {
+	if tx.Type.IsPrivileged() {
+		if err := d.authorizeAdmin(tx); err != nil {
+			return newTxResult(err)
+		}
+	}
+
+	switch tx.Type {
+	case TxTransfer:
+		return newTxResult(d.stateDB.executeTransfer(directState{d.stateDB}, tx, block.Timestamp, transferFee(tx)))
+	case TxMint, TxBurn:
+		return newTxResult(d.applySupplyTransaction(tx))
+	case TxVestingGrant:
+		return newTxResult(d.applyVestingGrant(tx))
+	case TxFreeze, TxUnfreeze:
+		return newTxResult(d.applyFreezeTransaction(tx, block))
+	case TxPause, TxResume:
+		return newTxResult(d.applyPauseTransaction(tx, block))
+	case TxDeploy, TxCall:
+		return d.applyContractTransaction(tx, block)
+	case TxTimeLock, TxTimeLockCancel:
+		return newTxResult(d.applyTimeLockTransaction(tx, block))
+	case TxAssetCreate, TxAssetTransfer, TxAssetApprove, TxAssetTransferFrom, TxAssetMint:
+		return newTxResult(d.applyAssetTransaction(tx, block))
+	case TxSponsorPolicy:
+		return newTxResult(d.applySponsorPolicy(tx, block))
+	case TxEscrowCreate, TxEscrowRelease, TxEscrowRefund:
+		return newTxResult(d.applyEscrowTransaction(tx, block))
+	default:
+		return newTxResult(fmt.Errorf("unknown transaction type: %d", tx.Type))
+	}
+}
+
+// applyTransfer moves native VNC between accounts on behalf of another
+// transaction type, which pays its fee separately
+func (d *DPoSBFT) applyTransfer(tx *Transaction, timestamp int64) error {
+	return d.stateDB.executeTransfer(directState{d.stateDB}, tx, timestamp, nil)
+}
+
 // RegisterValidator adds a new validator
 func (d *DPoSBFT) RegisterValidator(address string, stake *big.Int, commission float64) error {
 	d.mu.Lock()
@@ -269,6 +560,12 @@ func (d *DPoSBFT) RegisterValidator(address string, stake *big.Int, commission f
 		return fmt.Errorf("max validators reached")
 	}
 
+	d.addValidator(address, stake, commission)
+	return nil
+}
+
+// addValidator registers an active validator. Caller must hold d.mu.
+func (d *DPoSBFT) addValidator(address string, stake *big.Int, commission float64) {
 	d.validators[address] = &Validator{
 		Address:       address,
 		Stake:         stake,
@@ -279,10 +576,10 @@ func (d *DPoSBFT) RegisterValidator(address string, stake *big.Int, commission f
 	}
 
 	fmt.Printf("👥 Validator registered: %s (Stake: %s)\n", address[:10], stake.String())
-	return nil
 }
 
-// getActiveValidators returns list of active validators
+// getActiveValidators returns the active validators in address order, so
+// every node derives the same proposer schedule
 func (d *DPoSBFT) getActiveValidators() []*Validator {
 	var active []*Validator
 	for _, v := range d.validators {
@@ -290,17 +587,19 @@ func (d *DPoSBFT) getActiveValidators() []*Validator {
 			active = append(active, v)
 		}
 	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Address < active[j].Address })
 	return active
 }
 
 // calculateBlockHash generates block hash
 func (d *DPoSBFT) calculateBlockHash(block *Block) string {
-	data := fmt.Sprintf("%d%s%d%s%s",
+	data := fmt.Sprintf("%d%s%d%s%s%s",
 		block.Number,
 		block.PreviousHash,
 		block.Timestamp,
 		block.TxRoot,
 		block.StateRoot,
+		block.LogsBloom.Hex(),
 	)
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
@@ -320,21 +619,57 @@ func (d *DPoSBFT) calculateTxRoot(txs []*Transaction) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// signBlock signs a block
-func (d *DPoSBFT) signBlock(block *Block, validator string) string {
-	// In production: use ECDSA signature
-	data := block.Hash + validator
+// sealHash is the hash a proposer signs: the block hash together with the
+// header fields it does not cover
+func sealHash(block *Block) string {
+	data := fmt.Sprintf("%s|%s|%d|%d", block.Hash, block.Validator, block.GasLimit, block.GasUsed)
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
 
+// checkProposer verifies that a block is signed by its validator, which must
+// be an active validator scheduled to propose at its height. Caller must
+// hold d.mu and the engine must be at the block's parent.
+func (d *DPoSBFT) checkProposer(block *Block) error {
+	validator, exists := d.validators[block.Validator]
+	if !exists || !validator.IsActive {
+		return fmt.Errorf("block #%d proposer %s is not an active validator", block.Number, block.Validator)
+	}
+	if scheduled := d.selectProposer(); scheduled != block.Validator {
+		return fmt.Errorf("block #%d is proposed by %s, scheduled proposer is %s", block.Number, block.Validator, scheduled)
+	}
+	if !verifyHashSignature(block.Validator, sealHash(block), block.Signature) {
+		return fmt.Errorf("block #%d has an invalid signature", block.Number)
+	}
+	return nil
+}
+
 // getPreviousBlockHash returns hash of previous block
 func (d *DPoSBFT) getPreviousBlockHash() string {
-	if d.currentBlock == 0 {
-		return "0x0000000000000000000000000000000000000000000000000000000000000000"
+	if d.lastBlockHash != "" {
+		return d.lastBlockHash
 	}
-	// In production: retrieve from blockchain storage
-	return fmt.Sprintf("0x%064d", d.currentBlock)
+	return legacyPreviousHash(d.currentBlock)
+}
+
+// legacyPreviousHash is the placeholder parent hash of blocks produced
+// before the engine tracked the hash of its last block
+func legacyPreviousHash(parent uint64) string {
+	return fmt.Sprintf("0x%064d", parent)
+}
+
+// GenesisHeader is the header of block 0 as the engine links block 1 to it
+func GenesisHeader() *Block {
+	return &Block{Number: 0, Hash: legacyPreviousHash(0)}
+}
+
+// LinksTo reports whether block is the child of parent. Blocks produced
+// before parent hashes were tracked carry a placeholder instead.
+func LinksTo(parent, block *Block) bool {
+	if block.Number != parent.Number+1 {
+		return false
+	}
+	return block.PreviousHash == parent.Hash || block.PreviousHash == legacyPreviousHash(parent.Number)
 }
 
 // GetCurrentBlock returns current block number
@@ -351,42 +686,24 @@ func (d *DPoSBFT) GetValidatorCount() int {
 	return len(d.getActiveValidators())
 }
 
-// NewMempool creates a new mempool
-func NewMempool() *Mempool {
-	return &Mempool{
-		transactions: make(map[string]*Transaction),
-	}
-}
-
-// AddTransaction adds a transaction to mempool
-func (m *Mempool) AddTransaction(tx *Transaction) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.transactions[tx.Hash] = tx
-}
-
-// GetPendingTransactions returns pending transactions
-func (m *Mempool) GetPendingTransactions(limit int) []*Transaction {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	var txs []*Transaction
-	count := 0
-	for _, tx := range m.transactions {
-		if count >= limit {
-			break
-		}
-		txs = append(txs, tx)
-		count++
-	}
-	return txs
-}
-
 // NewStateDB creates a new state database
 func NewStateDB() *StateDB {
 	return &StateDB{
-		balances: make(map[string]*big.Int),
-		nonces:   make(map[string]uint64),
+		balances:        make(map[string]*big.Int),
+		nonces:          make(map[string]uint64),
+		vesting:         make(map[string]*VestingAccount),
+		frozen:          make(map[string]*FreezeRecord),
+		code:            make(map[string][]byte),
+		storage:         make(map[string]map[vm.Word]vm.Word),
+		timeLocks:       make(map[string]*TimeLock),
+		assets:          make(map[string]*Asset),
+		assetBalances:   make(map[assetKey]*big.Int),
+		allowances:      make(map[allowanceKey]*big.Int),
+		sponsorPolicies: make(map[string]*SponsorPolicy),
+		escrows:         make(map[string]*Escrow),
+		dirty:           make(map[string]struct{}),
+		totalSupply:     big.NewInt(0),
+		burned:          big.NewInt(0),
 	}
 }
 
@@ -408,6 +725,7 @@ func (s *StateDB) AddBalance(address string, amount *big.Int) {
 		s.balances[address] = big.NewInt(0)
 	}
 	s.balances[address].Add(s.balances[address], amount)
+	s.touch(address)
 }
 
 // SubBalance subtracts from account balance
@@ -418,6 +736,14 @@ func (s *StateDB) SubBalance(address string, amount *big.Int) {
 		s.balances[address] = big.NewInt(0)
 	}
 	s.balances[address].Sub(s.balances[address], amount)
+	s.touch(address)
+}
+
+// GetNonce returns account nonce
+func (s *StateDB) GetNonce(address string) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nonces[address]
 }
 
 // IncrementNonce increments account nonce
@@ -425,6 +751,7 @@ func (s *StateDB) IncrementNonce(address string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.nonces[address]++
+	s.touch(address)
 }
 
 // GetRoot returns state root hash
@@ -432,6 +759,72 @@ func (s *StateDB) GetRoot() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	// Simplified - in production use Merkle Patricia Trie
-	hash := sha256.Sum256([]byte(fmt.Sprintf("%v", s.balances)))
+	data := fmt.Sprintf("%v", s.balances)
+	if len(s.code) > 0 {
+		data += s.contractDigest()
+	}
+	if len(s.timeLocks) > 0 {
+		data += s.timeLockDigest()
+	}
+	if len(s.assets) > 0 {
+		data += s.assetDigest()
+	}
+	if len(s.sponsorPolicies) > 0 {
+		data += s.sponsorDigest()
+	}
+	if len(s.escrows) > 0 {
+		data += s.escrowDigest()
+	}
+	if len(s.frozen) > 0 {
+		data += s.freezeDigest()
+	}
+	if len(s.vesting) > 0 {
+		data += s.vestingDigest()
+	}
+	if s.pause.Paused {
+		data += s.pauseDigest()
+	}
+	if len(s.nonces) > 0 {
+		data += s.nonceDigest()
+	}
+	if len(s.freezeLog) > 0 {
+		data += s.freezeLogDigest()
+	}
+	if s.totalSupply.Sign() != 0 || s.burned.Sign() != 0 {
+		data += fmt.Sprintf("supply:%s:%s", s.totalSupply.String(), s.burned.String())
+	}
+	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
+
+// nonceDigest hashes the account nonces in address order. Caller must hold s.mu.
+func (s *StateDB) nonceDigest() string {
+	addresses := make([]string, 0, len(s.nonces))
+	for address := range s.nonces {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	h := sha256.New()
+	for _, address := range addresses {
+		fmt.Fprintf(h, "%s:%d;", address, s.nonces[address])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// stateRoot is the root recorded in block headers: the state root combined
+// with the validator set. Caller must hold d.mu.
+func (d *DPoSBFT) stateRoot() string {
+	root := d.stateDB.GetRoot()
+	if len(d.validators) == 0 {
+		return root
+	}
+
+	h := sha256.New()
+	h.Write([]byte(root))
+	for _, v := range d.sortedValidators() {
+		fmt.Fprintf(h, "%s:%s:%s:%g:%t:%d;", v.Address, bigString(v.Stake), bigString(v.DelegatedStake),
+			v.Commission, v.IsActive, v.VotingPower)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
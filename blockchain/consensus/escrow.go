@@ -0,0 +1,249 @@
+package consensus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// EscrowAddress is the module account holding funds of open escrows
+const EscrowAddress = "0x0000000000000000000000000000000000001002"
+
+// Escrow statuses
+const (
+	EscrowOpen     = "open"
+	EscrowReleased = "released"
+	EscrowRefunded = "refunded"
+)
+
+// Escrow holds a depositor's funds until they are released to the
+// beneficiary or refunded. The arbiter, if any, may settle either way.
+type Escrow struct {
+	ID            string   `json:"id"` // hash of the creating transaction
+	Depositor     string   `json:"depositor"`
+	Beneficiary   string   `json:"beneficiary"`
+	Arbiter       string   `json:"arbiter,omitempty"`
+	Amount        *big.Int `json:"amount"`
+	ExpiresAt     int64    `json:"expires_at"` // unix time after which the depositor may refund
+	Status        string   `json:"status"`
+	CreatedHeight uint64   `json:"created_height"`
+	SettledHeight uint64   `json:"settled_height,omitempty"`
+	SettledBy     string   `json:"settled_by,omitempty"`
+}
+
+// EscrowPayload is the JSON body carried in Data by an escrow create transaction
+type EscrowPayload struct {
+	Arbiter   string `json:"arbiter,omitempty"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// Expired reports whether the escrow's expiry has passed
+func (e *Escrow) Expired(timestamp int64) bool {
+	return timestamp >= e.ExpiresAt
+}
+
+// canRelease reports whether caller may pay the escrow to the beneficiary
+func (e *Escrow) canRelease(caller string) bool {
+	return caller == e.Depositor || (e.Arbiter != "" && caller == e.Arbiter)
+}
+
+// canRefund reports whether caller may return the escrow to the depositor.
+// The beneficiary and arbiter can refund at any time; the depositor only
+// once the escrow has expired.
+func (e *Escrow) canRefund(caller string, timestamp int64) bool {
+	switch {
+	case caller == e.Beneficiary:
+		return true
+	case e.Arbiter != "" && caller == e.Arbiter:
+		return true
+	case caller == e.Depositor:
+		return e.Expired(timestamp)
+	}
+	return false
+}
+
+// copy returns a deep copy of an escrow
+func (e *Escrow) copy() *Escrow {
+	copied := *e
+	copied.Amount = new(big.Int).Set(e.Amount)
+	return &copied
+}
+
+// GetEscrow returns an escrow by ID
+func (s *StateDB) GetEscrow(id string) (*Escrow, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	escrow, exists := s.escrows[id]
+	if !exists {
+		return nil, false
+	}
+	return escrow.copy(), true
+}
+
+// GetEscrows returns the escrows an address is party to, oldest first
+func (s *StateDB) GetEscrows(address string) []*Escrow {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	escrows := make([]*Escrow, 0)
+	for _, escrow := range s.escrows {
+		if escrow.Depositor == address || escrow.Beneficiary == address || escrow.Arbiter == address {
+			escrows = append(escrows, escrow.copy())
+		}
+	}
+	sort.Slice(escrows, func(i, j int) bool {
+		if escrows[i].CreatedHeight != escrows[j].CreatedHeight {
+			return escrows[i].CreatedHeight < escrows[j].CreatedHeight
+		}
+		return escrows[i].ID < escrows[j].ID
+	})
+	return escrows
+}
+
+// escrowedSupply sums value held in open escrows. Caller must hold s.mu.
+func (s *StateDB) escrowedSupply() *big.Int {
+	total := big.NewInt(0)
+	for _, escrow := range s.escrows {
+		if escrow.Status == EscrowOpen {
+			total.Add(total, escrow.Amount)
+		}
+	}
+	return total
+}
+
+// escrowDigest hashes all escrows in ID order. Caller must hold s.mu.
+func (s *StateDB) escrowDigest() string {
+	ids := make([]string, 0, len(s.escrows))
+	for id := range s.escrows {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	h := sha256.New()
+	for _, id := range ids {
+		e := s.escrows[id]
+		fmt.Fprintf(h, "%s:%s:%s:%s:%s:%d:%s;", id, e.Depositor, e.Beneficiary, e.Arbiter,
+			e.Amount.String(), e.ExpiresAt, e.Status)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// applyEscrowTransaction creates, releases or refunds an escrow.
+// Creating deposits tx.Value for beneficiary tx.To with the terms in tx.Data;
+// release and refund carry the escrow ID in tx.Data.
+func (d *DPoSBFT) applyEscrowTransaction(tx *Transaction, block *Block) error {
+	switch tx.Type {
+	case TxEscrowCreate:
+		return d.createEscrow(tx, block)
+	case TxEscrowRelease, TxEscrowRefund:
+		return d.settleEscrow(tx, block)
+	}
+	return fmt.Errorf("unknown escrow transaction type: %d", tx.Type)
+}
+
+// createEscrow deposits value into a new escrow
+func (d *DPoSBFT) createEscrow(tx *Transaction, block *Block) error {
+	var payload EscrowPayload
+	if err := json.Unmarshal(tx.Data, &payload); err != nil {
+		return fmt.Errorf("invalid escrow payload: %w", err)
+	}
+	if tx.To == "" || tx.To == tx.From || tx.To == EscrowAddress {
+		return fmt.Errorf("invalid escrow beneficiary %q", tx.To)
+	}
+	if payload.Arbiter == tx.From || (payload.Arbiter != "" && payload.Arbiter == tx.To) {
+		return fmt.Errorf("arbiter must be independent of depositor and beneficiary")
+	}
+	if payload.ExpiresAt <= block.Timestamp {
+		return fmt.Errorf("escrow expiry must be in the future")
+	}
+	if tx.Value.Sign() <= 0 {
+		return fmt.Errorf("escrow amount must be positive")
+	}
+
+	// Deposit with transfer semantics: frozen, vesting and nonce checks apply
+	deposit := *tx
+	deposit.To = EscrowAddress
+	if err := d.applyTransfer(&deposit, block.Timestamp); err != nil {
+		return err
+	}
+
+	escrow := &Escrow{
+		ID:            tx.Hash,
+		Depositor:     tx.From,
+		Beneficiary:   tx.To,
+		Arbiter:       payload.Arbiter,
+		Amount:        new(big.Int).Set(tx.Value),
+		ExpiresAt:     payload.ExpiresAt,
+		Status:        EscrowOpen,
+		CreatedHeight: block.Number,
+	}
+
+	s := d.stateDB
+	s.mu.Lock()
+	s.escrows[escrow.ID] = escrow
+	s.mu.Unlock()
+
+	fmt.Printf("🤝 Escrow %s: %s from %s for %s\n", escrow.ID[:10], escrow.Amount.String(),
+		escrow.Depositor, escrow.Beneficiary)
+	return nil
+}
+
+// settleEscrow pays an open escrow to the beneficiary (release) or back to
+// the depositor (refund)
+func (d *DPoSBFT) settleEscrow(tx *Transaction, block *Block) error {
+	id := string(tx.Data)
+
+	s := d.stateDB
+	s.mu.Lock()
+	escrow, exists := s.escrows[id]
+	if !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("escrow %s not found", id)
+	}
+	if escrow.Status != EscrowOpen {
+		s.mu.Unlock()
+		return fmt.Errorf("escrow %s is already %s", id, escrow.Status)
+	}
+
+	var payee string
+	switch tx.Type {
+	case TxEscrowRelease:
+		if !escrow.canRelease(tx.From) {
+			s.mu.Unlock()
+			return fmt.Errorf("%s may not release escrow %s", tx.From, id)
+		}
+		payee, escrow.Status = escrow.Beneficiary, EscrowReleased
+	case TxEscrowRefund:
+		if !escrow.canRefund(tx.From, block.Timestamp) {
+			s.mu.Unlock()
+			return fmt.Errorf("%s may not refund escrow %s", tx.From, id)
+		}
+		payee, escrow.Status = escrow.Depositor, EscrowRefunded
+	}
+
+	s.payFromModule(EscrowAddress, payee, escrow.Amount)
+	escrow.SettledHeight = block.Number
+	escrow.SettledBy = tx.From
+	s.mu.Unlock()
+
+	fmt.Printf("🤝 Escrow %s %s to %s by %s\n", id[:10], escrow.Status, payee, tx.From)
+	return nil
+}
+
+// GetEscrow returns an escrow by ID
+func (d *DPoSBFT) GetEscrow(id string) (*Escrow, error) {
+	escrow, exists := d.stateDB.GetEscrow(id)
+	if !exists {
+		return nil, fmt.Errorf("escrow %s not found", id)
+	}
+	return escrow, nil
+}
+
+// GetEscrows returns the escrows an address is party to
+func (d *DPoSBFT) GetEscrows(address string) []*Escrow {
+	return d.stateDB.GetEscrows(address)
+}
@@ -0,0 +1,82 @@
+package consensus
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// openEscrow builds a signed escrow of 10 VNC from alice for bob with
+// carol as arbiter, expiring at the given block time
+func openEscrow(expiresAt int64) *Transaction {
+	return signedTx(testKey("alice"), &Transaction{
+		Type:  TxEscrowCreate,
+		To:    testAddress("bob"),
+		Value: vnc(10),
+		Data:  []byte(fmt.Sprintf(`{"arbiter":%q,"expires_at":%d}`, testAddress("carol"), expiresAt)),
+	})
+}
+
+// settle builds a signed release or refund of an escrow
+func settle(from string, txType TxType, id string, nonce uint64) *Transaction {
+	return signedTx(testKey(from), &Transaction{Type: txType, Nonce: nonce, Data: []byte(id)})
+}
+
+func TestEscrowSettledByArbiter(t *testing.T) {
+	for _, tc := range []struct {
+		txType TxType
+		status string
+		payee  string
+	}{
+		{TxEscrowRelease, EscrowReleased, "bob"},
+		{TxEscrowRefund, EscrowRefunded, "alice"},
+	} {
+		d := newTestEngine(t, Config{}, "alice", "bob", "carol")
+		escrow := openEscrow(100)
+		requireStatus(t, runBlock(t, d, escrow)[0], ReceiptStatusSuccess)
+
+		// The beneficiary cannot release to itself
+		requireStatus(t, runBlock(t, d, settle("bob", TxEscrowRelease, escrow.Hash, 0))[0], ReceiptStatusFailed)
+
+		payee := new(big.Int).Set(d.stateDB.GetBalance(testAddress(tc.payee)))
+		requireStatus(t, runBlock(t, d, settle("carol", tc.txType, escrow.Hash, 0))[0], ReceiptStatusSuccess)
+		settled, err := d.GetEscrow(escrow.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if settled.Status != tc.status || settled.SettledBy != testAddress("carol") {
+			t.Fatalf("escrow %s by %s, want %s by the arbiter", settled.Status, settled.SettledBy, tc.status)
+		}
+		paid := new(big.Int).Sub(d.stateDB.GetBalance(testAddress(tc.payee)), payee)
+		if paid.Cmp(vnc(10)) != 0 {
+			t.Fatalf("%s: %s paid %s, want 10 VNC", tc.status, tc.payee, paid)
+		}
+		if held := d.stateDB.GetBalance(EscrowAddress); held.Sign() != 0 {
+			t.Fatalf("%s: escrow account still holds %s", tc.status, held)
+		}
+
+		// A settled escrow cannot be settled again
+		requireStatus(t, runBlock(t, d, settle("carol", TxEscrowRelease, escrow.Hash, 1))[0], ReceiptStatusFailed)
+	}
+}
+
+func TestEscrowRefundAfterExpiry(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice")
+	escrow := openEscrow(3)
+	requireStatus(t, runBlock(t, d, escrow)[0], ReceiptStatusSuccess)
+
+	// The depositor refunds only once the escrow has expired
+	requireStatus(t, runBlock(t, d, settle("alice", TxEscrowRefund, escrow.Hash, 1))[0], ReceiptStatusFailed)
+	before := new(big.Int).Set(d.stateDB.GetBalance(testAddress("alice")))
+	receipt := runBlock(t, d, settle("alice", TxEscrowRefund, escrow.Hash, 2))[0]
+	requireStatus(t, receipt, ReceiptStatusSuccess)
+
+	refund := new(big.Int).Sub(d.stateDB.GetBalance(testAddress("alice")), before)
+	refund.Add(refund, new(big.Int).SetUint64(receipt.GasUsed))
+	if refund.Cmp(vnc(10)) != 0 {
+		t.Fatalf("refund of %s, want 10 VNC", refund)
+	}
+	if settled, _ := d.GetEscrow(escrow.Hash); settled.Status != EscrowRefunded {
+		t.Fatalf("escrow %s after the refund", settled.Status)
+	}
+}
@@ -0,0 +1,52 @@
+package consensus
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFrozenSenderRejectedAtAdmission(t *testing.T) {
+	d := newTestEngine(t, adminConfig(), "admin", "alice")
+	freeze := signedTx(testKey("admin"), &Transaction{Type: TxFreeze, To: testAddress("alice")})
+	requireStatus(t, runBlock(t, d, freeze)[0], ReceiptStatusSuccess)
+
+	blocked := []*Transaction{
+		{Type: TxTransfer, To: testAddress("bob"), Value: big.NewInt(1)},
+		{Type: TxCall, To: testAddress("contract"), GasLimit: 100000},
+		{Type: TxDeploy, GasLimit: 100000, Data: []byte{0x00}},
+		{Type: TxAssetTransfer, To: testAddress("bob"), Value: big.NewInt(1), Data: []byte(`{"symbol":"GOLD"}`)},
+		{Type: TxAssetCreate, Value: big.NewInt(1), Data: []byte(`{"symbol":"GOLD"}`)},
+		{Type: TxTimeLock, To: testAddress("bob"), Value: big.NewInt(1)},
+		{Type: TxEscrowCreate, To: testAddress("bob"), Value: big.NewInt(1)},
+	}
+	for _, tx := range blocked {
+		if err := d.SubmitTransaction(signedTx(testKey("alice"), tx)); err == nil {
+			t.Errorf("type %d from a frozen account admitted", tx.Type)
+		}
+	}
+
+	// Spending a frozen owner's allowance is blocked as well
+	spend := signedTx(testKey("bob"), &Transaction{
+		Type:  TxAssetTransferFrom,
+		To:    testAddress("bob"),
+		Value: big.NewInt(1),
+		Data:  []byte(`{"symbol":"GOLD","owner":"` + testAddress("alice") + `"}`),
+	})
+	if err := d.SubmitTransaction(spend); err == nil {
+		t.Error("transfer-from a frozen owner admitted")
+	}
+
+	approve := signedTx(testKey("alice"), &Transaction{Type: TxAssetApprove, To: testAddress("bob"), Data: []byte(`{"symbol":"GOLD"}`)})
+	if err := d.SubmitTransaction(approve); err != nil {
+		t.Errorf("approval from a frozen account rejected: %v", err)
+	}
+}
+
+func TestFreezeChangesStateRoot(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice")
+	before := d.stateDB.GetRoot()
+	d.stateDB.frozen[testAddress("alice")] = &FreezeRecord{Address: testAddress("alice"), Height: 1}
+	if d.stateDB.GetRoot() == before {
+		t.Fatal("freezing an account left the state root unchanged")
+	}
+}
@@ -0,0 +1,89 @@
+package consensus
+
+import (
+	"fmt"
+	"math/big"
+	"vnc-blockchain/vm"
+)
+
+// Fees.
+//
+// Every executed transaction pays GasPrice for each unit of gas it used,
+// from the sender or, for a meta-transaction, the fee payer. The most a
+// transaction can cost, GasLimit × GasPrice, is taken before it runs and
+// the unused part refunded afterwards, so execution only sees what is left.
+// Plain transfers use a fixed amount of gas and pay their fee together with
+// the value, which keeps parallel runs within the transfer state they track.
+// Fees collected in a block are paid to its proposer after its last
+// transaction.
+
+// BlockGasLimit is the gas available to the transactions of one block
+const BlockGasLimit uint64 = 30_000_000
+
+// txGasLimit is the gas a transaction may use. Native transactions may
+// leave GasLimit unset to get the flat call gas.
+func txGasLimit(tx *Transaction) uint64 {
+	if tx.GasLimit == 0 {
+		return vm.GasTxCall
+	}
+	return tx.GasLimit
+}
+
+// txFee is the fee for an amount of gas at the transaction's gas price
+func txFee(tx *Transaction, gas uint64) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(gas), bigOrZero(tx.GasPrice))
+}
+
+// maxTxFee is the most a transaction can cost in fees
+func maxTxFee(tx *Transaction) *big.Int {
+	return txFee(tx, txGasLimit(tx))
+}
+
+// transferFee is the fee a plain transfer pays with its value. Sponsored
+// transfers are paid for by the fee payer instead.
+func transferFee(tx *Transaction) *big.Int {
+	if tx.IsSponsored() {
+		return nil
+	}
+	return txFee(tx, vm.GasTxCall)
+}
+
+// feePayer returns the account paying a transaction's fee
+func feePayer(tx *Transaction) string {
+	if tx.IsSponsored() {
+		return tx.FeePayer
+	}
+	return tx.From
+}
+
+// checkGasLimit rejects transactions that cannot fit in a block or pay
+// for the flat call gas
+func checkGasLimit(tx *Transaction) error {
+	limit := txGasLimit(tx)
+	if limit < vm.GasTxCall {
+		return fmt.Errorf("gas limit %d below intrinsic gas %d", limit, vm.GasTxCall)
+	}
+	if limit > BlockGasLimit {
+		return fmt.Errorf("gas limit %d exceeds block gas limit %d", limit, BlockGasLimit)
+	}
+	return nil
+}
+
+// buyGas takes the maximum fee from the fee payer before execution
+func (d *DPoSBFT) buyGas(tx *Transaction, block *Block) (*big.Int, error) {
+	payer := feePayer(tx)
+	maxFee := maxTxFee(tx)
+	if spendable := d.stateDB.GetSpendableBalance(payer, block.Timestamp); spendable.Cmp(maxFee) < 0 {
+		return nil, fmt.Errorf("insufficient balance for gas: %s has %s, needs %s", payer, spendable.String(), maxFee.String())
+	}
+	d.stateDB.SubBalance(payer, maxFee)
+	return maxFee, nil
+}
+
+// refundGas returns the part of the maximum fee not used
+func (d *DPoSBFT) refundGas(tx *Transaction, paid *big.Int, gasUsed uint64) {
+	refund := new(big.Int).Sub(paid, txFee(tx, gasUsed))
+	if refund.Sign() > 0 {
+		d.stateDB.AddBalance(feePayer(tx), refund)
+	}
+}
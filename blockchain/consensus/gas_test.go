@@ -0,0 +1,82 @@
+package consensus
+
+import (
+	"math/big"
+	"testing"
+	"time"
+	"vnc-blockchain/vm"
+)
+
+// infiniteLoop is init code that jumps to itself until it runs out of gas
+var infiniteLoop = []byte{byte(vm.JUMPDEST), byte(vm.PUSH1), 0x00, byte(vm.JUMP)}
+
+func TestTransferPaysFeeToProposer(t *testing.T) {
+	d := newTestEngine(t, Config{ExecutionWorkers: 1}, "alice")
+	tx := signedTx(testKey("alice"), &Transaction{
+		Type:     TxTransfer,
+		To:       testAddress("bob"),
+		Value:    vnc(1),
+		GasPrice: big.NewInt(1e9),
+	})
+	requireStatus(t, runBlock(t, d, tx)[0], ReceiptStatusSuccess)
+
+	fee := new(big.Int).Mul(big.NewInt(int64(vm.GasTxCall)), big.NewInt(1e9))
+	want := new(big.Int).Sub(vnc(999), fee)
+	if balance := d.stateDB.GetBalance(testAddress("alice")); balance.Cmp(want) != 0 {
+		t.Fatalf("alice has %s, want %s", balance, want)
+	}
+	if balance := d.stateDB.GetBalance(testAddress("validator")); balance.Cmp(fee) != 0 {
+		t.Fatalf("proposer received %s, want %s", balance, fee)
+	}
+}
+
+func TestRunawayContractBoundedByBlockGas(t *testing.T) {
+	d := newTestEngine(t, Config{ExecutionWorkers: 1}, "alice")
+	deploy := func(nonce uint64) *Transaction {
+		return signedTx(testKey("alice"), &Transaction{
+			Type:     TxDeploy,
+			Nonce:    nonce,
+			GasLimit: BlockGasLimit,
+			GasPrice: big.NewInt(1),
+			Data:     infiniteLoop,
+		})
+	}
+
+	if err := d.SubmitTransaction(signedTx(testKey("alice"), &Transaction{
+		Type: TxDeploy, GasLimit: 100 * BlockGasLimit, Data: infiniteLoop,
+	})); err == nil {
+		t.Fatal("transaction above the block gas limit admitted")
+	}
+
+	start := time.Now()
+	receipts := runBlock(t, d, deploy(0), deploy(1))
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("block took %v", elapsed)
+	}
+
+	requireStatus(t, receipts[0], ReceiptStatusFailed)
+	if receipts[0].GasUsed != BlockGasLimit {
+		t.Fatalf("runaway deploy used %d gas, want %d", receipts[0].GasUsed, BlockGasLimit)
+	}
+	requireStatus(t, receipts[1], ReceiptStatusFailed)
+	if receipts[1].GasUsed != 0 || receipts[1].CumulativeGasUsed > BlockGasLimit {
+		t.Fatalf("block used %d gas, limit %d", receipts[1].CumulativeGasUsed, BlockGasLimit)
+	}
+
+	// The sender paid for the gas burned; the rejected deploy kept its nonce
+	want := new(big.Int).Sub(vnc(1000), new(big.Int).SetUint64(BlockGasLimit))
+	if balance := d.stateDB.GetBalance(testAddress("alice")); balance.Cmp(want) != 0 {
+		t.Fatalf("alice has %s, want %s", balance, want)
+	}
+	if nonce := d.stateDB.GetNonce(testAddress("alice")); nonce != 1 {
+		t.Fatalf("alice's nonce is %d, want 1", nonce)
+	}
+}
+
+func TestSenderMustAffordGas(t *testing.T) {
+	d := newTestEngine(t, Config{ExecutionWorkers: 1})
+	tx := signedTx(testKey("alice"), &Transaction{Type: TxTransfer, To: testAddress("bob")})
+	if err := d.SubmitTransaction(tx); err == nil {
+		t.Fatal("transaction from an account without funds for gas admitted")
+	}
+}
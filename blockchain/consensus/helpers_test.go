@@ -0,0 +1,158 @@
+package consensus
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// testKey derives a deterministic key for a named test account
+func testKey(name string) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte("test-account-" + name))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+// testAddress is the address of a named test account
+func testAddress(name string) string {
+	return AddressOf(testKey(name))
+}
+
+// vnc returns n whole VNC in wei
+func vnc(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18))
+}
+
+// newTestEngine creates an engine with the named accounts funded
+func newTestEngine(t *testing.T, config Config, funded ...string) *DPoSBFT {
+	t.Helper()
+	d := NewDPoSBFT(config)
+	for _, name := range funded {
+		if err := d.stateDB.Mint(testAddress(name), vnc(1000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d
+}
+
+// signedTx fills in the sender of tx from key and signs it
+func signedTx(key ed25519.PrivateKey, tx *Transaction) *Transaction {
+	tx.From = AddressOf(key)
+	if tx.Value == nil {
+		tx.Value = big.NewInt(0)
+	}
+	if tx.GasPrice == nil {
+		tx.GasPrice = big.NewInt(1)
+	}
+	SignTransaction(tx, key)
+	return tx
+}
+
+// transfer builds a signed native transfer
+func transfer(from, to string, value int64, nonce uint64) *Transaction {
+	return signedTx(testKey(from), &Transaction{
+		Type:  TxTransfer,
+		To:    testAddress(to),
+		Value: big.NewInt(value),
+		Nonce: nonce,
+	})
+}
+
+// runBlock executes txs as the next block and returns their receipts
+func runBlock(t *testing.T, d *DPoSBFT, txs ...*Transaction) []*Receipt {
+	t.Helper()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	block := &Block{
+		Number:       d.currentBlock + 1,
+		PreviousHash: d.getPreviousBlockHash(),
+		Timestamp:    d.lastBlockTime + 1,
+		Transactions: txs,
+		Validator:    testAddress("validator"),
+		GasLimit:     30_000_000,
+	}
+	d.executeTransactions(block)
+	d.currentBlock = block.Number
+	d.lastBlockTime = block.Timestamp
+	return d.blockReceipts[block.Number]
+}
+
+// requireStatus fails the test unless the receipt has the given status
+func requireStatus(t *testing.T, receipt *Receipt, status uint64) {
+	t.Helper()
+	if receipt.Status != status {
+		t.Fatalf("tx %s: status %d, want %d (error %q)", receipt.TxHash, receipt.Status, status, receipt.Error)
+	}
+}
+
+// testStore is an in-memory BlockStore
+type testStore struct {
+	blocks   map[uint64]*Block
+	receipts map[string]*Receipt
+	supply   map[uint64]*SupplyInfo
+}
+
+func newTestStore() *testStore {
+	return &testStore{
+		blocks:   make(map[uint64]*Block),
+		receipts: make(map[string]*Receipt),
+		supply:   make(map[uint64]*SupplyInfo),
+	}
+}
+
+func (s *testStore) CommitBlock(block *Block, receipts []*Receipt, accounts []*Account, supply *SupplyInfo) error {
+	s.blocks[block.Number] = block
+	for _, receipt := range receipts {
+		s.receipts[receipt.TxHash] = receipt
+	}
+	if supply != nil {
+		s.supply[block.Number] = supply
+	}
+	return nil
+}
+
+func (s *testStore) GetBlock(number uint64) (*Block, error) {
+	block, exists := s.blocks[number]
+	if !exists {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return block, nil
+}
+
+func (s *testStore) GetLatestBlockNumber() (uint64, error) {
+	var latest uint64
+	for number := range s.blocks {
+		if number > latest {
+			latest = number
+		}
+	}
+	return latest, nil
+}
+
+func (s *testStore) GetHeader(number uint64) (*Block, error) {
+	block, err := s.GetBlock(number)
+	if err != nil {
+		return nil, err
+	}
+	header := *block
+	header.Transactions = nil
+	return &header, nil
+}
+
+func (s *testStore) GetReceipt(txHash string) (*Receipt, error) {
+	receipt, exists := s.receipts[txHash]
+	if !exists {
+		return nil, fmt.Errorf("receipt %s not found", txHash)
+	}
+	return receipt, nil
+}
+
+func (s *testStore) GetSupply(height uint64) (*SupplyInfo, error) {
+	info, exists := s.supply[height]
+	if !exists {
+		return nil, fmt.Errorf("supply at block #%d not found", height)
+	}
+	return info, nil
+}
@@ -0,0 +1,54 @@
+package consensus
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Accounts are controlled by ed25519 keys. An address is "0x" and the first
+// 20 bytes of the SHA-256 of the public key, and a signature is the hex of
+// the signer's public key followed by the ed25519 signature, so it can be
+// checked against an address without a key registry.
+
+// signatureLength is the decoded length of a signature string
+const signatureLength = ed25519.PublicKeySize + ed25519.SignatureSize
+
+// GenerateKey creates a new account key
+func GenerateKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	return key, err
+}
+
+// AddressFromPublicKey derives the address controlled by a public key
+func AddressFromPublicKey(pub ed25519.PublicKey) string {
+	hash := sha256.Sum256(pub)
+	return "0x" + hex.EncodeToString(hash[:20])
+}
+
+// AddressOf returns the address controlled by a private key
+func AddressOf(key ed25519.PrivateKey) string {
+	return AddressFromPublicKey(key.Public().(ed25519.PublicKey))
+}
+
+// signHash signs a hex hash with a key
+func signHash(key ed25519.PrivateKey, hash string) string {
+	signature := append([]byte(nil), key.Public().(ed25519.PublicKey)...)
+	signature = append(signature, ed25519.Sign(key, []byte(hash))...)
+	return hex.EncodeToString(signature)
+}
+
+// verifyHashSignature reports whether signature signs hash with the key
+// that controls address
+func verifyHashSignature(address, hash, signature string) bool {
+	raw, err := hex.DecodeString(signature)
+	if err != nil || len(raw) != signatureLength {
+		return false
+	}
+	pub := ed25519.PublicKey(raw[:ed25519.PublicKeySize])
+	if AddressFromPublicKey(pub) != address {
+		return false
+	}
+	return ed25519.Verify(pub, []byte(hash), raw[ed25519.PublicKeySize:])
+}
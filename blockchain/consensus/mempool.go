@@ -0,0 +1,354 @@
+package consensus
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+	"vnc-blockchain/vm"
+)
+
+// DefaultPriceBump is the minimum gas price increase, in percent, needed
+// to replace a pending transaction with the same sender and nonce
+const DefaultPriceBump = 10
+
+// maxMempoolEvents bounds the in-memory history of mempool events
+const maxMempoolEvents = 1000
+
+// MempoolEventType identifies why a transaction left the pool
+type MempoolEventType string
+
+const (
+	// MempoolTxReplaced is emitted when a higher-fee transaction takes the slot
+	MempoolTxReplaced MempoolEventType = "replaced"
+	// MempoolTxDropped is emitted when a transaction can no longer execute
+	MempoolTxDropped MempoolEventType = "dropped"
+)
+
+// MempoolEvent reports a transaction leaving the pool without being included
+type MempoolEvent struct {
+	Type       MempoolEventType `json:"type"`
+	TxHash     string           `json:"tx_hash"`
+	From       string           `json:"from"`
+	Nonce      uint64           `json:"nonce"`
+	ReplacedBy string           `json:"replaced_by,omitempty"`
+	Reason     string           `json:"reason"`
+	Timestamp  int64            `json:"timestamp"`
+}
+
+// Mempool holds pending transactions. Each (sender, nonce) pair has at most
+// one pending transaction; a second one only replaces it if it pays a gas
+// price at least priceBump percent higher.
+type Mempool struct {
+	transactions map[string]*Transaction      // hash -> tx
+	bySender     map[string]map[uint64]string // sender -> nonce -> hash
+	priceBump    uint64
+	events       []MempoolEvent
+	subscribers  []chan MempoolEvent
+	journal      MempoolJournal
+	mu           sync.RWMutex
+}
+
+// NewMempool creates a new mempool. A priceBump of 0 uses DefaultPriceBump.
+func NewMempool(priceBump uint64) *Mempool {
+	if priceBump == 0 {
+		priceBump = DefaultPriceBump
+	}
+	return &Mempool{
+		transactions: make(map[string]*Transaction),
+		bySender:     make(map[string]map[uint64]string),
+		priceBump:    priceBump,
+	}
+}
+
+// AddTransaction adds a transaction to the mempool, replacing a pending
+// transaction with the same sender and nonce if the fee bump is sufficient
+func (m *Mempool) AddTransaction(tx *Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.transactions[tx.Hash]; exists {
+		return fmt.Errorf("transaction %s already pending", tx.Hash)
+	}
+
+	nonces, exists := m.bySender[tx.From]
+	if !exists {
+		nonces = make(map[uint64]string)
+		m.bySender[tx.From] = nonces
+	}
+
+	if hash, exists := nonces[tx.Nonce]; exists {
+		pending := m.transactions[hash]
+		minPrice := m.replacementPrice(pending)
+		if bigOrZero(tx.GasPrice).Cmp(minPrice) < 0 {
+			return fmt.Errorf("replacement transaction underpriced: gas price %s, need at least %s",
+				bigString(tx.GasPrice), minPrice.String())
+		}
+		delete(m.transactions, hash)
+		m.unjournal(hash)
+		m.emit(MempoolEvent{
+			Type:       MempoolTxReplaced,
+			TxHash:     hash,
+			From:       pending.From,
+			Nonce:      pending.Nonce,
+			ReplacedBy: tx.Hash,
+			Reason:     "replaced by higher gas price",
+		})
+		fmt.Printf("♻️  Transaction %s replaced by %s\n", hash, tx.Hash)
+	}
+
+	m.transactions[tx.Hash] = tx
+	nonces[tx.Nonce] = tx.Hash
+	m.writeJournal(tx)
+	return nil
+}
+
+// GetPendingTransactions returns up to limit executable transactions whose
+// gas limits fit in gasLimit, ordered by sender and nonce. Each sender
+// contributes the run of consecutive nonces starting at its account nonce;
+// transactions behind a nonce gap stay pending until the gap is filled.
+func (m *Mempool) GetPendingTransactions(limit int, gasLimit uint64, nonceOf func(address string) uint64) []*Transaction {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	senders := make([]string, 0, len(m.bySender))
+	for from := range m.bySender {
+		senders = append(senders, from)
+	}
+	sort.Strings(senders)
+
+	txs := make([]*Transaction, 0, len(m.transactions))
+	for _, from := range senders {
+		nonces := m.bySender[from]
+		for nonce := nonceOf(from); len(txs) < limit; nonce++ {
+			hash, exists := nonces[nonce]
+			if !exists {
+				break
+			}
+			tx := m.transactions[hash]
+			if txGasLimit(tx) > gasLimit {
+				break
+			}
+			gasLimit -= txGasLimit(tx)
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+// GetPending returns the pending transaction for a sender and nonce
+func (m *Mempool) GetPending(from string, nonce uint64) (*Transaction, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hash, exists := m.bySender[from][nonce]
+	if !exists {
+		return nil, false
+	}
+	return m.transactions[hash], true
+}
+
+// MinReplacementPrice returns the gas price a transaction must pay to
+// replace the pending one for the same sender and nonce
+func (m *Mempool) MinReplacementPrice(from string, nonce uint64) (*big.Int, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hash, exists := m.bySender[from][nonce]
+	if !exists {
+		return nil, false
+	}
+	return m.replacementPrice(m.transactions[hash]), true
+}
+
+// RemoveTransactions removes transactions that were included in a block
+func (m *Mempool) RemoveTransactions(txs []*Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tx := range txs {
+		m.remove(tx)
+	}
+}
+
+// DropStale drops pending transactions whose nonce has already been used
+func (m *Mempool) DropStale(nonceOf func(address string) uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for from, nonces := range m.bySender {
+		current := nonceOf(from)
+		for nonce, hash := range nonces {
+			if nonce >= current {
+				continue
+			}
+			m.drop(m.transactions[hash], fmt.Sprintf("nonce too low: account nonce is %d", current))
+		}
+	}
+}
+
+// Drop removes a pending transaction and emits a dropped event
+func (m *Mempool) Drop(txHash string, reason string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, exists := m.transactions[txHash]
+	if !exists {
+		return false
+	}
+	m.drop(tx, reason)
+	return true
+}
+
+// Size returns the number of pending transactions
+func (m *Mempool) Size() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.transactions)
+}
+
+// Subscribe returns a channel receiving replaced and dropped events.
+// Events are not delivered to subscribers whose buffer is full.
+func (m *Mempool) Subscribe(buffer int) <-chan MempoolEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch := make(chan MempoolEvent, buffer)
+	m.subscribers = append(m.subscribers, ch)
+	return ch
+}
+
+// GetEvents returns the most recent replaced and dropped events
+func (m *Mempool) GetEvents() []MempoolEvent {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	events := make([]MempoolEvent, len(m.events))
+	copy(events, m.events)
+	return events
+}
+
+// replacementPrice is the pending gas price raised by priceBump percent.
+// Caller must hold m.mu.
+func (m *Mempool) replacementPrice(pending *Transaction) *big.Int {
+	current := bigOrZero(pending.GasPrice)
+	price := new(big.Int).Mul(current, new(big.Int).SetUint64(100+m.priceBump))
+	price.Div(price, big.NewInt(100))
+
+	// Tiny prices still need a strictly higher bid
+	if price.Cmp(current) <= 0 {
+		price.Add(current, big.NewInt(1))
+	}
+	return price
+}
+
+// drop removes a transaction and emits a dropped event. Caller must hold m.mu.
+func (m *Mempool) drop(tx *Transaction, reason string) {
+	m.remove(tx)
+	m.emit(MempoolEvent{
+		Type:   MempoolTxDropped,
+		TxHash: tx.Hash,
+		From:   tx.From,
+		Nonce:  tx.Nonce,
+		Reason: reason,
+	})
+	fmt.Printf("🗑️  Transaction %s dropped: %s\n", tx.Hash, reason)
+}
+
+// remove deletes a transaction from both indexes. Caller must hold m.mu.
+func (m *Mempool) remove(tx *Transaction) {
+	if _, exists := m.transactions[tx.Hash]; !exists {
+		return
+	}
+	delete(m.transactions, tx.Hash)
+	m.unjournal(tx.Hash)
+
+	nonces := m.bySender[tx.From]
+	if nonces[tx.Nonce] == tx.Hash {
+		delete(nonces, tx.Nonce)
+	}
+	if len(nonces) == 0 {
+		delete(m.bySender, tx.From)
+	}
+}
+
+// emit records an event and notifies subscribers. Caller must hold m.mu.
+func (m *Mempool) emit(event MempoolEvent) {
+	event.Timestamp = time.Now().Unix()
+
+	m.events = append(m.events, event)
+	if len(m.events) > maxMempoolEvents {
+		m.events = m.events[len(m.events)-maxMempoolEvents:]
+	}
+
+	for _, ch := range m.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// checkNonce rejects transactions whose nonce has already been used.
+// Future nonces are admitted and wait in the pool.
+func checkNonce(tx *Transaction, current uint64) error {
+	if tx.Nonce < current {
+		return fmt.Errorf("nonce too low: got %d, account nonce is %d", tx.Nonce, current)
+	}
+	return nil
+}
+
+// requireNonce rejects transactions that are not the sender's next one.
+// Only the next nonce can execute.
+func requireNonce(tx *Transaction, current uint64) error {
+	if err := checkNonce(tx, current); err != nil {
+		return err
+	}
+	if tx.Nonce > current {
+		return fmt.Errorf("nonce too high: got %d, account nonce is %d", tx.Nonce, current)
+	}
+	return nil
+}
+
+// NewCancelTransaction builds a zero-value self-transfer that replaces the
+// pending transaction at from/nonce. The caller signs and submits it.
+func (d *DPoSBFT) NewCancelTransaction(from string, nonce uint64) (*Transaction, error) {
+	gasPrice, exists := d.mempool.MinReplacementPrice(from, nonce)
+	if !exists {
+		return nil, fmt.Errorf("no pending transaction from %s with nonce %d", from, nonce)
+	}
+
+	return &Transaction{
+		Type:     TxTransfer,
+		From:     from,
+		To:       from,
+		Value:    big.NewInt(0),
+		Nonce:    nonce,
+		GasPrice: gasPrice,
+		GasLimit: vm.GasTxCall,
+	}, nil
+}
+
+// GetPendingTransaction returns the pending transaction for a sender and nonce
+func (d *DPoSBFT) GetPendingTransaction(from string, nonce uint64) (*Transaction, bool) {
+	return d.mempool.GetPending(from, nonce)
+}
+
+// SubscribeMempoolEvents returns a channel of replaced and dropped events
+func (d *DPoSBFT) SubscribeMempoolEvents(buffer int) <-chan MempoolEvent {
+	return d.mempool.Subscribe(buffer)
+}
+
+// GetMempoolEvents returns the most recent replaced and dropped events
+func (d *DPoSBFT) GetMempoolEvents() []MempoolEvent {
+	return d.mempool.GetEvents()
+}
+
+// bigOrZero returns v, or zero if v is nil
+func bigOrZero(v *big.Int) *big.Int {
+	if v == nil {
+		return big.NewInt(0)
+	}
+	return v
+}
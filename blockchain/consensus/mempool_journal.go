@@ -0,0 +1,120 @@
+package consensus
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MempoolJournal persists pending transactions so they survive a restart.
+// It is satisfied by *storage.BlockchainDB.
+type MempoolJournal interface {
+	SaveMempoolTx(txHash string, txData interface{}) error
+	DeleteMempoolTx(txHash string) error
+	GetMempoolTransactions() ([]json.RawMessage, error)
+}
+
+// SetJournal attaches a journal; every later change to the pool is written to it
+func (m *Mempool) SetJournal(journal MempoolJournal) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.journal = journal
+}
+
+// Restore reloads journaled transactions into the pool. Each one is run
+// through validate first; failures are dropped and removed from the journal.
+func (m *Mempool) Restore(validate func(tx *Transaction) error) (restored int, dropped int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.journal == nil {
+		return 0, 0, nil
+	}
+	entries, err := m.journal.GetMempoolTransactions()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read mempool journal: %w", err)
+	}
+
+	for _, entry := range entries {
+		var tx Transaction
+		if err := json.Unmarshal(entry, &tx); err != nil {
+			fmt.Printf("⚠️  Skipping unreadable mempool journal entry: %v\n", err)
+			continue
+		}
+		if err := m.restoreOne(&tx, validate); err != nil {
+			m.unjournal(tx.Hash)
+			m.emit(MempoolEvent{
+				Type:   MempoolTxDropped,
+				TxHash: tx.Hash,
+				From:   tx.From,
+				Nonce:  tx.Nonce,
+				Reason: err.Error(),
+			})
+			fmt.Printf("🗑️  Transaction %s dropped on reload: %v\n", tx.Hash, err)
+			dropped++
+			continue
+		}
+		restored++
+	}
+	return restored, dropped, nil
+}
+
+// restoreOne validates and inserts a reloaded transaction. When two journaled
+// transactions share a sender and nonce the higher gas price wins.
+// Caller must hold m.mu.
+func (m *Mempool) restoreOne(tx *Transaction, validate func(tx *Transaction) error) error {
+	if err := validate(tx); err != nil {
+		return err
+	}
+
+	nonces, exists := m.bySender[tx.From]
+	if !exists {
+		nonces = make(map[uint64]string)
+		m.bySender[tx.From] = nonces
+	}
+	if hash, exists := nonces[tx.Nonce]; exists {
+		pending := m.transactions[hash]
+		if bigOrZero(tx.GasPrice).Cmp(bigOrZero(pending.GasPrice)) <= 0 {
+			return fmt.Errorf("superseded by %s", hash)
+		}
+		delete(m.transactions, hash)
+		m.unjournal(hash)
+	}
+
+	m.transactions[tx.Hash] = tx
+	nonces[tx.Nonce] = tx.Hash
+	return nil
+}
+
+// writeJournal persists a pending transaction. Caller must hold m.mu.
+func (m *Mempool) writeJournal(tx *Transaction) {
+	if m.journal == nil {
+		return
+	}
+	if err := m.journal.SaveMempoolTx(tx.Hash, tx); err != nil {
+		fmt.Printf("⚠️  Failed to journal transaction %s: %v\n", tx.Hash, err)
+	}
+}
+
+// unjournal removes a transaction from the journal. Caller must hold m.mu.
+func (m *Mempool) unjournal(txHash string) {
+	if m.journal == nil {
+		return
+	}
+	if err := m.journal.DeleteMempoolTx(txHash); err != nil {
+		fmt.Printf("⚠️  Failed to remove transaction %s from journal: %v\n", txHash, err)
+	}
+}
+
+// LoadMempool attaches the journal and reloads pending transactions from it.
+// Reloaded transactions go through the same admission checks as submitted
+// ones, so it must be called once the state is rebuilt to the stored head.
+func (d *DPoSBFT) LoadMempool(journal MempoolJournal) error {
+	d.mempool.SetJournal(journal)
+
+	restored, dropped, err := d.mempool.Restore(d.admitTransaction)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📥 Mempool reloaded: %d pending, %d dropped\n", restored, dropped)
+	return nil
+}
@@ -0,0 +1,75 @@
+package consensus
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// testJournal is an in-memory MempoolJournal
+type testJournal map[string]json.RawMessage
+
+func (j testJournal) SaveMempoolTx(txHash string, txData interface{}) error {
+	data, err := json.Marshal(txData)
+	if err != nil {
+		return err
+	}
+	j[txHash] = data
+	return nil
+}
+
+func (j testJournal) DeleteMempoolTx(txHash string) error {
+	delete(j, txHash)
+	return nil
+}
+
+func (j testJournal) GetMempoolTransactions() ([]json.RawMessage, error) {
+	entries := make([]json.RawMessage, 0, len(j))
+	for _, entry := range j {
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func TestReloadRunsAdmissionChecks(t *testing.T) {
+	journal := testJournal{}
+	valid := transfer("alice", "bob", 1, 0)
+	frozen := transfer("mallory", "bob", 1, 0)
+	unfunded := transfer("nobody", "bob", 0, 0)
+	for _, tx := range []*Transaction{valid, frozen, unfunded} {
+		journal.SaveMempoolTx(tx.Hash, tx)
+	}
+
+	d := newTestEngine(t, Config{}, "alice", "mallory")
+	d.stateDB.frozen[testAddress("mallory")] = &FreezeRecord{Address: testAddress("mallory"), Height: 1}
+	if err := d.LoadMempool(journal); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.mempool.Size() != 1 {
+		t.Fatalf("%d transactions reloaded, want 1", d.mempool.Size())
+	}
+	if _, pending := d.GetPendingTransaction(testAddress("alice"), 0); !pending {
+		t.Fatal("valid transaction not reloaded")
+	}
+	if _, journaled := journal[frozen.Hash]; journaled {
+		t.Fatal("transaction from a frozen account left in the journal")
+	}
+	if _, journaled := journal[unfunded.Hash]; journaled {
+		t.Fatal("transaction that cannot pay for gas left in the journal")
+	}
+}
+
+func TestReloadWhilePausedDropsTransfers(t *testing.T) {
+	journal := testJournal{}
+	tx := transfer("alice", "bob", 1, 0)
+	journal.SaveMempoolTx(tx.Hash, tx)
+
+	d := newTestEngine(t, Config{}, "alice")
+	d.stateDB.pause = PauseState{Paused: true}
+	if err := d.LoadMempool(journal); err != nil {
+		t.Fatal(err)
+	}
+	if d.mempool.Size() != 0 {
+		t.Fatal("transfer reloaded while the chain is paused")
+	}
+}
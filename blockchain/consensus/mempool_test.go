@@ -0,0 +1,101 @@
+package consensus
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestReplayedNonceExecutesOnce(t *testing.T) {
+	// One worker executes serially; four run the copies as a parallel transfer run
+	for _, workers := range []int{1, 4} {
+		d := newTestEngine(t, Config{ExecutionWorkers: workers}, "alice")
+		for nonce := uint64(0); nonce < 5; nonce++ {
+			requireStatus(t, runBlock(t, d, transfer("alice", "carol", 1, nonce))[0], ReceiptStatusSuccess)
+		}
+
+		tx := transfer("alice", "bob", 100, 5)
+		copies := make([]*Transaction, 2*minParallelRun)
+		for i := range copies {
+			copies[i] = tx
+		}
+		receipts := runBlock(t, d, copies...)
+		requireStatus(t, receipts[0], ReceiptStatusSuccess)
+		for _, receipt := range receipts[1:] {
+			requireStatus(t, receipt, ReceiptStatusFailed)
+		}
+		requireStatus(t, runBlock(t, d, tx)[0], ReceiptStatusFailed)
+
+		if balance := d.stateDB.GetBalance(testAddress("bob")); balance.Cmp(big.NewInt(100)) != 0 {
+			t.Fatalf("%d workers: bob received %s, want 100", workers, balance)
+		}
+	}
+}
+
+func TestFutureNonceNotExecuted(t *testing.T) {
+	d := newTestEngine(t, Config{ExecutionWorkers: 1}, "alice")
+	receipts := runBlock(t, d, transfer("alice", "bob", 1, 1))
+	requireStatus(t, receipts[0], ReceiptStatusFailed)
+	if nonce := d.stateDB.GetNonce(testAddress("alice")); nonce != 0 {
+		t.Fatalf("a future nonce moved the account nonce to %d", nonce)
+	}
+}
+
+func TestPendingTransactionsStopAtNonceGap(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice", "bob")
+	for _, tx := range []*Transaction{
+		transfer("alice", "carol", 1, 0),
+		transfer("alice", "carol", 1, 1),
+		transfer("alice", "carol", 1, 3), // behind a gap
+		transfer("bob", "carol", 1, 1),   // bob's nonce 0 is missing
+	} {
+		if err := d.SubmitTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending := d.mempool.GetPendingTransactions(maxBlockTransactions, BlockGasLimit, d.stateDB.GetNonce)
+	if len(pending) != 2 || pending[0].Nonce != 0 || pending[1].Nonce != 1 {
+		t.Fatalf("got %d executable transactions, want alice's nonces 0 and 1", len(pending))
+	}
+
+	// Filling the gap releases the waiting transactions
+	runBlock(t, d, pending...)
+	d.mempool.RemoveTransactions(pending)
+	if err := d.SubmitTransaction(transfer("alice", "carol", 1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if pending := d.mempool.GetPendingTransactions(maxBlockTransactions, BlockGasLimit, d.stateDB.GetNonce); len(pending) != 2 {
+		t.Fatalf("got %d executable transactions after filling the gap, want 2", len(pending))
+	}
+}
+
+func TestFailedTransactionUsesNonce(t *testing.T) {
+	d := newTestEngine(t, Config{ExecutionWorkers: 1}, "alice")
+	receipts := runBlock(t, d,
+		// More than alice holds
+		signedTx(testKey("alice"), &Transaction{Type: TxTransfer, To: testAddress("bob"), Value: vnc(5000)}),
+		transfer("alice", "bob", 1, 1),
+	)
+	requireStatus(t, receipts[0], ReceiptStatusFailed)
+	requireStatus(t, receipts[1], ReceiptStatusSuccess)
+}
+
+func TestAdminTransactionsUseOneNonce(t *testing.T) {
+	d := newTestEngine(t, adminConfig(), "admin")
+	mint := func(nonce uint64) *Transaction {
+		return signedTx(testKey("admin"), &Transaction{Type: TxMint, To: testAddress("alice"), Value: vnc(1), Nonce: nonce})
+	}
+	receipts := runBlock(t, d,
+		mint(0),
+		vestingGrant("admin", "bob", vnc(10), 1),
+		// Bob is not frozen, so this fails but still uses nonce 2
+		signedTx(testKey("admin"), &Transaction{Type: TxUnfreeze, To: testAddress("bob"), Nonce: 2}),
+		mint(3),
+	)
+	for i, status := range []uint64{ReceiptStatusSuccess, ReceiptStatusSuccess, ReceiptStatusFailed, ReceiptStatusSuccess} {
+		requireStatus(t, receipts[i], status)
+	}
+	if nonce := d.stateDB.GetNonce(testAddress("admin")); nonce != 4 {
+		t.Fatalf("admin nonce %d after four transactions, want 4", nonce)
+	}
+}
@@ -0,0 +1,212 @@
+package consensus
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// latencyBucketsMs are the upper bounds of the latency histogram buckets
+var latencyBucketsMs = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 1500, 2000, 2500, 5000, 10000}
+
+// LatencyHistogram counts durations into fixed millisecond buckets
+type LatencyHistogram struct {
+	counts []uint64 // one per bucket plus overflow
+	count  uint64
+	sum    time.Duration
+	max    time.Duration
+	mu     sync.Mutex
+}
+
+// HistogramBucket is the cumulative count of observations at or below Le milliseconds
+type HistogramBucket struct {
+	Le    string `json:"le"`
+	Count uint64 `json:"count"`
+}
+
+// HistogramSnapshot is a point-in-time copy of a histogram.
+// Percentiles are estimated from bucket upper bounds.
+type HistogramSnapshot struct {
+	Count   uint64            `json:"count"`
+	SumMs   float64           `json:"sum_ms"`
+	MeanMs  float64           `json:"mean_ms"`
+	MaxMs   float64           `json:"max_ms"`
+	P50Ms   float64           `json:"p50_ms"`
+	P95Ms   float64           `json:"p95_ms"`
+	P99Ms   float64           `json:"p99_ms"`
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+// NewLatencyHistogram creates an empty histogram
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{counts: make([]uint64, len(latencyBucketsMs)+1)}
+}
+
+// Observe records one duration
+func (h *LatencyHistogram) Observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ms := durationMs(d)
+	i := 0
+	for i < len(latencyBucketsMs) && ms > latencyBucketsMs[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// Snapshot returns the current state of the histogram
+func (h *LatencyHistogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := HistogramSnapshot{
+		Count:   h.count,
+		SumMs:   durationMs(h.sum),
+		MaxMs:   durationMs(h.max),
+		Buckets: make([]HistogramBucket, 0, len(h.counts)),
+	}
+	if h.count > 0 {
+		snapshot.MeanMs = snapshot.SumMs / float64(h.count)
+	}
+
+	var cumulative uint64
+	for i, count := range h.counts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(latencyBucketsMs) {
+			le = strconv.FormatFloat(latencyBucketsMs[i], 'f', -1, 64)
+		}
+		snapshot.Buckets = append(snapshot.Buckets, HistogramBucket{Le: le, Count: cumulative})
+	}
+
+	snapshot.P50Ms = h.quantile(0.50)
+	snapshot.P95Ms = h.quantile(0.95)
+	snapshot.P99Ms = h.quantile(0.99)
+	return snapshot
+}
+
+// quantile estimates the q-th quantile as the upper bound of the bucket it
+// falls in, capped at the largest observation. Caller must hold h.mu.
+func (h *LatencyHistogram) quantile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	max := durationMs(h.max)
+
+	var cumulative uint64
+	for i, count := range h.counts {
+		cumulative += count
+		if cumulative >= rank {
+			if i < len(latencyBucketsMs) && latencyBucketsMs[i] < max {
+				return latencyBucketsMs[i]
+			}
+			return max
+		}
+	}
+	return max
+}
+
+// durationMs converts a duration to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// BlockMetrics tracks latency through the block pipeline
+type BlockMetrics struct {
+	Build    *LatencyHistogram // preparing a candidate while the previous block is voted on
+	Produce  *LatencyHistogram // executing and proposing a block
+	Finalize *LatencyHistogram // from proposal to finality
+	Interval *LatencyHistogram // between consecutive finalized blocks
+
+	mu                sync.Mutex
+	candidateHits     uint64
+	candidateMisses   uint64
+	speculativeReused uint64
+	speculativeStale  uint64
+	intervalsMissed   uint64
+	lastFinalized     time.Time
+}
+
+// BlockMetricsSnapshot is the JSON view of the block pipeline metrics
+type BlockMetricsSnapshot struct {
+	TargetMs          float64           `json:"target_ms"`
+	Build             HistogramSnapshot `json:"build"`
+	Produce           HistogramSnapshot `json:"produce"`
+	Finalize          HistogramSnapshot `json:"finalize"`
+	Interval          HistogramSnapshot `json:"interval"`
+	IntervalsMissed   uint64            `json:"intervals_missed"` // block intervals over 125% of the target
+	CandidateHits     uint64            `json:"candidate_hits"`
+	CandidateMisses   uint64            `json:"candidate_misses"`
+	SpeculativeReused uint64            `json:"speculative_reused"`
+	SpeculativeStale  uint64            `json:"speculative_stale"`
+}
+
+// NewBlockMetrics creates empty block pipeline metrics
+func NewBlockMetrics() *BlockMetrics {
+	return &BlockMetrics{
+		Build:    NewLatencyHistogram(),
+		Produce:  NewLatencyHistogram(),
+		Finalize: NewLatencyHistogram(),
+		Interval: NewLatencyHistogram(),
+	}
+}
+
+// recordCandidate counts whether a block was produced from a prepared candidate
+func (m *BlockMetrics) recordCandidate(hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if hit {
+		m.candidateHits++
+	} else {
+		m.candidateMisses++
+	}
+}
+
+// recordSpeculation counts prepared executions that were reused or re-executed
+func (m *BlockMetrics) recordSpeculation(reused, stale int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.speculativeReused += uint64(reused)
+	m.speculativeStale += uint64(stale)
+}
+
+// recordFinalized observes the interval since the previous finalized block.
+// Intervals more than a quarter over the target count as missed.
+func (m *BlockMetrics) recordFinalized(at time.Time, target time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.lastFinalized.IsZero() {
+		interval := at.Sub(m.lastFinalized)
+		m.Interval.Observe(interval)
+		if interval > target+target/4 {
+			m.intervalsMissed++
+		}
+	}
+	m.lastFinalized = at
+}
+
+// Snapshot returns the current metrics
+func (m *BlockMetrics) Snapshot(target time.Duration) BlockMetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return BlockMetricsSnapshot{
+		TargetMs:          durationMs(target),
+		Build:             m.Build.Snapshot(),
+		Produce:           m.Produce.Snapshot(),
+		Finalize:          m.Finalize.Snapshot(),
+		Interval:          m.Interval.Snapshot(),
+		IntervalsMissed:   m.intervalsMissed,
+		CandidateHits:     m.candidateHits,
+		CandidateMisses:   m.candidateMisses,
+		SpeculativeReused: m.speculativeReused,
+		SpeculativeStale:  m.speculativeStale,
+	}
+}
@@ -0,0 +1,438 @@
+package consensus
+
+import (
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+// Parallel execution of native transfers in the Block-STM style.
+//
+// A run of consecutive transfers is executed speculatively in parallel
+// against the pre-run state. Each execution records its read set and
+// write set. Results are then validated and committed in block order:
+// if a transaction read a key written by an earlier transaction in the
+// run, it is re-executed against the committed state before its writes
+// are applied. Committing strictly in order makes the outcome identical
+// to serial execution.
+
+// minParallelRun is the smallest run of transfers worth scheduling in parallel
+const minParallelRun = 8
+
+// stateKeyKind identifies which part of account state a key refers to
+type stateKeyKind uint8
+
+const (
+	keyBalance stateKeyKind = iota
+	keyNonce
+	keyVestingReleased
+)
+
+// stateKey identifies one piece of mutable account state
+type stateKey struct {
+	kind    stateKeyKind
+	address string
+}
+
+// transferState is the state access needed to execute a native transfer
+type transferState interface {
+	getBalance(address string) *big.Int
+	setBalance(address string, amount *big.Int)
+	getNonce(address string) uint64
+	setNonce(address string, nonce uint64)
+	getVestingReleased(address string) *big.Int
+	setVestingReleased(address string, amount *big.Int)
+}
+
+// executeTransfer runs a native transfer against any transferState, taking
+// fee from the sender along with the value if it is not nil. Both serial and
+// parallel execution use this so their semantics match. Once the nonce
+// checks out it is used, whether or not the transfer succeeds.
+func (s *StateDB) executeTransfer(st transferState, tx *Transaction, timestamp int64, fee *big.Int) error {
+	if err := requireNonce(tx, st.getNonce(tx.From)); err != nil {
+		return err
+	}
+	st.setNonce(tx.From, tx.Nonce+1)
+
+	if s.IsFrozen(tx.From) {
+		return fmt.Errorf("account %s is frozen", tx.From)
+	}
+
+	// Check balance, excluding tokens still locked by vesting
+	balance := st.getBalance(tx.From)
+	spendable := new(big.Int).Set(balance)
+	account := s.GetVestingAccount(tx.From)
+	if account != nil {
+		spendable.Sub(spendable, account.Schedule.LockedAmount(timestamp))
+		if spendable.Sign() < 0 {
+			spendable.SetInt64(0)
+		}
+	}
+	cost := new(big.Int).Add(tx.Value, bigOrZero(fee))
+	if cost.Cmp(spendable) > 0 {
+		return fmt.Errorf("insufficient spendable balance: have %s, need %s",
+			spendable.String(), cost.String())
+	}
+
+	// Account for vested tokens leaving a vesting account
+	if account != nil {
+		released := st.getVestingReleased(tx.From)
+		unreleased := new(big.Int).Sub(account.Schedule.VestedAmount(timestamp), released)
+		if unreleased.Sign() > 0 {
+			if tx.Value.Cmp(unreleased) < 0 {
+				unreleased.Set(tx.Value)
+			}
+			st.setVestingReleased(tx.From, new(big.Int).Add(released, unreleased))
+		}
+	}
+
+	st.setBalance(tx.From, new(big.Int).Sub(balance, cost))
+	st.setBalance(tx.To, new(big.Int).Add(st.getBalance(tx.To), tx.Value))
+	return nil
+}
+
+// directState applies transfers straight to the StateDB (serial execution)
+type directState struct {
+	s *StateDB
+}
+
+func (ds directState) getBalance(address string) *big.Int {
+	return new(big.Int).Set(ds.s.GetBalance(address))
+}
+
+func (ds directState) setBalance(address string, amount *big.Int) {
+	ds.s.mu.Lock()
+	defer ds.s.mu.Unlock()
+	ds.s.balances[address] = amount
+	ds.s.touch(address)
+}
+
+func (ds directState) getNonce(address string) uint64 {
+	return ds.s.GetNonce(address)
+}
+
+func (ds directState) setNonce(address string, nonce uint64) {
+	ds.s.mu.Lock()
+	defer ds.s.mu.Unlock()
+	ds.s.nonces[address] = nonce
+	ds.s.touch(address)
+}
+
+func (ds directState) getVestingReleased(address string) *big.Int {
+	ds.s.mu.RLock()
+	defer ds.s.mu.RUnlock()
+	if account, exists := ds.s.vesting[address]; exists {
+		return new(big.Int).Set(account.Released)
+	}
+	return big.NewInt(0)
+}
+
+func (ds directState) setVestingReleased(address string, amount *big.Int) {
+	ds.s.mu.Lock()
+	defer ds.s.mu.Unlock()
+	if account, exists := ds.s.vesting[address]; exists {
+		account.Released = amount
+	}
+}
+
+// overlayState buffers committed writes of a parallel run on top of the StateDB
+type overlayState struct {
+	base     *StateDB
+	balances map[string]*big.Int
+	nonces   map[string]uint64
+	released map[string]*big.Int
+	order    []stateKey // first-write order, for deterministic flushing
+}
+
+func newOverlayState(base *StateDB) *overlayState {
+	return &overlayState{
+		base:     base,
+		balances: make(map[string]*big.Int),
+		nonces:   make(map[string]uint64),
+		released: make(map[string]*big.Int),
+	}
+}
+
+func (o *overlayState) read(key stateKey) (interface{}, bool) {
+	switch key.kind {
+	case keyBalance:
+		v, ok := o.balances[key.address]
+		return v, ok
+	case keyNonce:
+		v, ok := o.nonces[key.address]
+		return v, ok
+	default:
+		v, ok := o.released[key.address]
+		return v, ok
+	}
+}
+
+// apply commits a transaction's write set to the overlay
+func (o *overlayState) apply(writes *txView) {
+	for _, key := range writes.order {
+		if _, exists := o.read(key); !exists {
+			o.order = append(o.order, key)
+		}
+		switch key.kind {
+		case keyBalance:
+			o.balances[key.address] = writes.balances[key.address]
+		case keyNonce:
+			o.nonces[key.address] = writes.nonces[key.address]
+		case keyVestingReleased:
+			o.released[key.address] = writes.released[key.address]
+		}
+	}
+}
+
+// flush writes the overlay into the StateDB
+func (o *overlayState) flush() {
+	s := o.base
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range o.order {
+		switch key.kind {
+		case keyBalance:
+			s.balances[key.address] = o.balances[key.address]
+			s.touch(key.address)
+		case keyNonce:
+			s.nonces[key.address] = o.nonces[key.address]
+			s.touch(key.address)
+		case keyVestingReleased:
+			if account, exists := s.vesting[key.address]; exists {
+				account.Released = o.released[key.address]
+			}
+		}
+	}
+}
+
+// current returns the value of a key as seen through the overlay
+func (o *overlayState) current(key stateKey) interface{} {
+	if v, ok := o.read(key); ok {
+		return v
+	}
+	switch key.kind {
+	case keyBalance:
+		return o.base.GetBalance(key.address)
+	case keyNonce:
+		return o.base.GetNonce(key.address)
+	default:
+		return directState{o.base}.getVestingReleased(key.address)
+	}
+}
+
+// txView is one transaction's view of state during parallel execution.
+// Reads fall through to the overlay and base state and are recorded with
+// the value observed; writes stay local until the transaction is committed.
+type txView struct {
+	parent   *overlayState
+	reads    map[stateKey]interface{}
+	balances map[string]*big.Int
+	nonces   map[string]uint64
+	released map[string]*big.Int
+	order    []stateKey
+}
+
+func newTxView(parent *overlayState) *txView {
+	return &txView{
+		parent:   parent,
+		reads:    make(map[stateKey]interface{}),
+		balances: make(map[string]*big.Int),
+		nonces:   make(map[string]uint64),
+		released: make(map[string]*big.Int),
+	}
+}
+
+func (v *txView) recordWrite(key stateKey, exists bool) {
+	if !exists {
+		v.order = append(v.order, key)
+	}
+}
+
+func (v *txView) getBalance(address string) *big.Int {
+	if amount, ok := v.balances[address]; ok {
+		return new(big.Int).Set(amount)
+	}
+	key := stateKey{keyBalance, address}
+	amount := new(big.Int).Set(v.parent.current(key).(*big.Int))
+	v.reads[key] = new(big.Int).Set(amount)
+	return amount
+}
+
+func (v *txView) setBalance(address string, amount *big.Int) {
+	_, exists := v.balances[address]
+	v.recordWrite(stateKey{keyBalance, address}, exists)
+	v.balances[address] = amount
+}
+
+func (v *txView) getNonce(address string) uint64 {
+	if nonce, ok := v.nonces[address]; ok {
+		return nonce
+	}
+	key := stateKey{keyNonce, address}
+	nonce := v.parent.current(key).(uint64)
+	v.reads[key] = nonce
+	return nonce
+}
+
+func (v *txView) setNonce(address string, nonce uint64) {
+	_, exists := v.nonces[address]
+	v.recordWrite(stateKey{keyNonce, address}, exists)
+	v.nonces[address] = nonce
+}
+
+func (v *txView) getVestingReleased(address string) *big.Int {
+	if amount, ok := v.released[address]; ok {
+		return new(big.Int).Set(amount)
+	}
+	key := stateKey{keyVestingReleased, address}
+	amount := new(big.Int).Set(v.parent.current(key).(*big.Int))
+	v.reads[key] = new(big.Int).Set(amount)
+	return amount
+}
+
+func (v *txView) setVestingReleased(address string, amount *big.Int) {
+	_, exists := v.released[address]
+	v.recordWrite(stateKey{keyVestingReleased, address}, exists)
+	v.released[address] = amount
+}
+
+// conflicts reports whether the view read any key in the written set
+func (v *txView) conflicts(written map[stateKey]struct{}) bool {
+	for key := range v.reads {
+		if _, ok := written[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// stale reports whether any value the view read differs from the state now
+// visible through overlay. Views executed ahead of time by the block builder
+// are validated this way since they ran against an earlier state.
+func (v *txView) stale(overlay *overlayState) bool {
+	for key, observed := range v.reads {
+		switch current := overlay.current(key).(type) {
+		case *big.Int:
+			if current.Cmp(observed.(*big.Int)) != 0 {
+				return true
+			}
+		case uint64:
+			if current != observed.(uint64) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// speculativeResult is the outcome of one speculative transfer execution
+type speculativeResult struct {
+	tx       *Transaction
+	view     *txView
+	err      error
+	sigValid bool
+	prepared bool // executed by the block builder ahead of the block
+	frozen   bool // sender frozen when prepared
+	vesting  bool // sender had a vesting schedule when prepared
+}
+
+// parallelFor calls fn for 0..n-1 on up to workers goroutines
+func parallelFor(n, workers int, fn func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	next := make(chan int, n)
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// executionWorkers returns the number of parallel execution workers
+func (d *DPoSBFT) executionWorkers() int {
+	if d.config.ExecutionWorkers > 0 {
+		return d.config.ExecutionWorkers
+	}
+	return runtime.NumCPU()
+}
+
+// executeTransfersParallel executes a run of transfers optimistically in
+// parallel and returns the per-transaction errors in block order
+func (d *DPoSBFT) executeTransfersParallel(txs []*Transaction, block *Block) []error {
+	overlay := newOverlayState(d.stateDB)
+	results := make([]speculativeResult, len(txs))
+
+	// Phase 1: speculative execution against the pre-run state, reusing
+	// executions prepared by the block builder where they still apply
+	prepared := d.prepared
+	parallelFor(len(txs), d.executionWorkers(), func(i int) {
+		tx := txs[i]
+		if result, ok := prepared.speculation(tx, block.Timestamp, d.stateDB); ok {
+			results[i] = result
+			return
+		}
+		result := speculativeResult{tx: tx, sigValid: prepared.signatureVerified(tx) || VerifyTransactionSignature(tx)}
+		if result.sigValid {
+			result.view = newTxView(overlay)
+			result.err = d.stateDB.executeTransfer(result.view, tx, block.Timestamp, transferFee(tx))
+		}
+		results[i] = result
+	})
+
+	// Phase 2: validate and commit in block order, re-executing on conflict.
+	// Prepared executions ran against an earlier state, so they are checked
+	// against the values they read rather than the keys written in this run.
+	errs := make([]error, len(txs))
+	written := make(map[stateKey]struct{})
+	reused, stale := 0, 0
+	for i, tx := range txs {
+		result := results[i]
+		if !result.sigValid {
+			errs[i] = fmt.Errorf("invalid transaction signature")
+			continue
+		}
+
+		conflict := false
+		if result.prepared {
+			conflict = result.view.stale(overlay)
+			if conflict {
+				stale++
+			} else {
+				reused++
+			}
+		} else {
+			conflict = result.view.conflicts(written)
+		}
+		if conflict {
+			result.view = newTxView(overlay)
+			result.err = d.stateDB.executeTransfer(result.view, tx, block.Timestamp, transferFee(tx))
+		}
+
+		// Failed transfers still commit their nonce
+		errs[i] = result.err
+		overlay.apply(result.view)
+		for _, key := range result.view.order {
+			written[key] = struct{}{}
+		}
+	}
+
+	overlay.flush()
+	if prepared != nil {
+		d.metrics.recordSpeculation(reused, stale)
+	}
+	return errs
+}
@@ -0,0 +1,169 @@
+package consensus
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// MinPauseThreshold is the fewest admin signatures a pause or resume can
+// require, so that no single admin key can halt the chain
+const MinPauseThreshold = 2
+
+// PauseState is the chain-wide pause flag held in consensus state
+type PauseState struct {
+	Paused       bool     `json:"paused"`
+	Reason       string   `json:"reason,omitempty"`
+	PausedAt     uint64   `json:"paused_at,omitempty"`
+	ResumeHeight uint64   `json:"resume_height,omitempty"` // 0 = until an explicit resume
+	Signers      []string `json:"signers,omitempty"`
+	TxHash       string   `json:"tx_hash,omitempty"`
+}
+
+// PausePayload is the JSON body carried in Data by pause and resume transactions
+type PausePayload struct {
+	Reason       string `json:"reason,omitempty"`
+	ResumeHeight uint64 `json:"resume_height,omitempty"`
+}
+
+// TxApproval is an additional admin signature on a multi-sig transaction
+type TxApproval struct {
+	Signer    string
+	Signature string
+}
+
+// ApproveTransaction adds a co-signer's approval to a signed transaction
+func ApproveTransaction(tx *Transaction, key ed25519.PrivateKey) {
+	tx.Approvals = append(tx.Approvals, TxApproval{
+		Signer:    AddressOf(key),
+		Signature: signHash(key, tx.Hash),
+	})
+}
+
+// IsPaused reports whether user transactions are currently refused
+func (s *StateDB) IsPaused() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pause.Paused
+}
+
+// GetPauseState returns a copy of the pause state
+func (s *StateDB) GetPauseState() PauseState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	state := s.pause
+	state.Signers = append([]string(nil), s.pause.Signers...)
+	return state
+}
+
+// pauseDigest hashes the pause state. Caller must hold s.mu.
+func (s *StateDB) pauseDigest() string {
+	p := s.pause
+	h := sha256.Sum256([]byte(fmt.Sprintf("%t:%q:%d:%d:%v:%s", p.Paused, p.Reason, p.PausedAt,
+		p.ResumeHeight, p.Signers, p.TxHash)))
+	return hex.EncodeToString(h[:])
+}
+
+// checkAutoResume lifts the pause once the scheduled resume height is reached
+func (s *StateDB) checkAutoResume(height uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pause.Paused && s.pause.ResumeHeight != 0 && height >= s.pause.ResumeHeight {
+		s.pause = PauseState{}
+		fmt.Printf("▶️  Chain auto-resumed at block #%d\n", height)
+	}
+}
+
+// pauseSigners returns the distinct admins with a valid signature on a pause
+// or resume transaction, counting the sender
+func (d *DPoSBFT) pauseSigners(tx *Transaction) []string {
+	signers := []string{tx.From}
+	seen := map[string]bool{tx.From: true}
+
+	for _, approval := range tx.Approvals {
+		if seen[approval.Signer] {
+			continue
+		}
+		if !verifyHashSignature(approval.Signer, tx.Hash, approval.Signature) {
+			continue
+		}
+		role, exists := d.config.AdminRoles[approval.Signer]
+		if !exists {
+			continue
+		}
+		for _, allowed := range txPermissions[tx.Type] {
+			if role == allowed {
+				signers = append(signers, approval.Signer)
+				seen[approval.Signer] = true
+				break
+			}
+		}
+	}
+
+	return signers
+}
+
+// applyPauseTransaction executes a multi-sig pause or resume transaction
+func (d *DPoSBFT) applyPauseTransaction(tx *Transaction, block *Block) error {
+	var payload PausePayload
+	if len(tx.Data) > 0 {
+		if err := json.Unmarshal(tx.Data, &payload); err != nil {
+			return fmt.Errorf("invalid pause payload: %w", err)
+		}
+	}
+
+	threshold := d.config.PauseThreshold
+	if threshold < MinPauseThreshold {
+		return fmt.Errorf("pause threshold %d is below the minimum of %d", threshold, MinPauseThreshold)
+	}
+	signers := d.pauseSigners(tx)
+	if len(signers) < threshold {
+		return fmt.Errorf("pause requires %d admin signatures, got %d", threshold, len(signers))
+	}
+
+	if payload.ResumeHeight != 0 && payload.ResumeHeight <= block.Number {
+		return fmt.Errorf("resume height %d is not in the future", payload.ResumeHeight)
+	}
+
+	s := d.stateDB
+	s.mu.Lock()
+	switch tx.Type {
+	case TxPause:
+		if s.pause.Paused {
+			s.mu.Unlock()
+			return fmt.Errorf("chain is already paused")
+		}
+		s.pause = PauseState{
+			Paused:       true,
+			Reason:       payload.Reason,
+			PausedAt:     block.Number,
+			ResumeHeight: payload.ResumeHeight,
+			Signers:      signers,
+			TxHash:       tx.Hash,
+		}
+		fmt.Printf("⏸️  Chain paused at block #%d: %s\n", block.Number, payload.Reason)
+	case TxResume:
+		if !s.pause.Paused {
+			s.mu.Unlock()
+			return fmt.Errorf("chain is not paused")
+		}
+		if payload.ResumeHeight != 0 {
+			// Keep the pause but schedule an automatic resume
+			s.pause.ResumeHeight = payload.ResumeHeight
+			fmt.Printf("⏯️  Chain resume scheduled for block #%d\n", payload.ResumeHeight)
+		} else {
+			s.pause = PauseState{}
+			fmt.Printf("▶️  Chain resumed at block #%d\n", block.Number)
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// GetPauseState returns the chain-wide pause state
+func (d *DPoSBFT) GetPauseState() PauseState {
+	return d.stateDB.GetPauseState()
+}
@@ -0,0 +1,57 @@
+package consensus
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigValidatePauseThreshold(t *testing.T) {
+	cfg := adminConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+
+	for _, threshold := range []int{0, 1, 3} {
+		cfg.PauseThreshold = threshold
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("pause threshold %d accepted with two super admins", threshold)
+		}
+	}
+
+	cfg = adminConfig()
+	cfg.AdminRoles[testAddress("mallory")] = "owner"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "unknown admin role") {
+		t.Fatalf("unknown role accepted: %v", err)
+	}
+
+	if err := (Config{}).Validate(); err != nil {
+		t.Fatalf("config without admins rejected: %v", err)
+	}
+}
+
+func TestSingleAdminCannotPause(t *testing.T) {
+	cfg := adminConfig()
+	cfg.PauseThreshold = 1
+	d := newTestEngine(t, cfg, "admin")
+
+	pause := signedTx(testKey("admin"), &Transaction{Type: TxPause})
+	requireStatus(t, runBlock(t, d, pause)[0], ReceiptStatusFailed)
+	if d.stateDB.IsPaused() {
+		t.Fatal("chain paused by a single admin")
+	}
+}
+
+func TestPauseChangesStateRoot(t *testing.T) {
+	d := newTestEngine(t, adminConfig(), "admin")
+	before := d.stateDB.GetRoot()
+	d.stateDB.pause = PauseState{Paused: true, Reason: "incident", PausedAt: 1}
+	paused := d.stateDB.GetRoot()
+	if paused == before {
+		t.Fatal("pausing the chain left the state root unchanged")
+	}
+
+	d.stateDB.pause.Reason = "other"
+	if d.stateDB.GetRoot() == paused {
+		t.Fatal("pause reason not committed to the state root")
+	}
+}
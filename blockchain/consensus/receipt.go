@@ -0,0 +1,286 @@
+package consensus
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"vnc-blockchain/vm"
+)
+
+// Receipt status values
+const (
+	ReceiptStatusFailed  uint64 = 0
+	ReceiptStatusSuccess uint64 = 1
+)
+
+// Log is an event emitted during contract execution
+type Log struct {
+	Address     string   `json:"address"`
+	Topics      []string `json:"topics"` // 0x-prefixed 32-byte hex
+	Data        []byte   `json:"data"`
+	BlockNumber uint64   `json:"block_number"`
+	TxHash      string   `json:"tx_hash"`
+	TxIndex     uint     `json:"tx_index"`
+	LogIndex    uint     `json:"log_index"` // position within the block
+}
+
+// Receipt records the outcome of an executed transaction
+type Receipt struct {
+	Type              TxType `json:"type"`
+	TxHash            string `json:"tx_hash"`
+	TxIndex           uint   `json:"tx_index"`
+	BlockNumber       uint64 `json:"block_number"`
+	Status            uint64 `json:"status"`
+	GasUsed           uint64 `json:"gas_used"`
+	CumulativeGasUsed uint64 `json:"cumulative_gas_used"`
+	Logs              []*Log `json:"logs"`
+	Bloom             Bloom  `json:"logs_bloom"`
+	ContractAddress   string `json:"contract_address,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+// LogFilter selects logs by block range, emitting address and topics.
+// Topics are matched by position; each position lists alternatives and an
+// empty position matches any topic.
+type LogFilter struct {
+	FromBlock uint64
+	ToBlock   uint64
+	Addresses []string
+	Topics    [][]string
+}
+
+// TxResult is the outcome of executing one transaction
+type TxResult struct {
+	GasUsed         uint64
+	Logs            []*Log
+	ContractAddress string
+	Err             error
+}
+
+// newTxResult builds the result of a native (non-contract) transaction
+func newTxResult(err error) *TxResult {
+	if err != nil {
+		return &TxResult{Err: err}
+	}
+	return &TxResult{GasUsed: vm.GasTxCall}
+}
+
+// convertLogs converts VM logs into receipt logs
+func convertLogs(logs []vm.Log) []*Log {
+	out := make([]*Log, 0, len(logs))
+	for _, l := range logs {
+		log := &Log{
+			Address: l.Address,
+			Topics:  make([]string, len(l.Topics)),
+			Data:    l.Data,
+		}
+		for i, topic := range l.Topics {
+			log.Topics[i] = "0x" + hex.EncodeToString(topic[:])
+		}
+		out = append(out, log)
+	}
+	return out
+}
+
+// newReceipt builds the receipt for the next transaction in a block
+func newReceipt(tx *Transaction, block *Block, previous []*Receipt, result *TxResult) *Receipt {
+	receipt := &Receipt{
+		Type:              tx.Type,
+		TxHash:            tx.Hash,
+		TxIndex:           uint(len(previous)),
+		BlockNumber:       block.Number,
+		Status:            ReceiptStatusSuccess,
+		GasUsed:           result.GasUsed,
+		CumulativeGasUsed: result.GasUsed,
+		Logs:              result.Logs,
+		ContractAddress:   result.ContractAddress,
+	}
+	if result.Err != nil {
+		receipt.Status = ReceiptStatusFailed
+		receipt.Error = result.Err.Error()
+	}
+	if receipt.Logs == nil {
+		receipt.Logs = []*Log{}
+	}
+
+	var logIndex uint
+	if n := len(previous); n > 0 {
+		last := previous[n-1]
+		receipt.CumulativeGasUsed += last.CumulativeGasUsed
+		for _, r := range previous {
+			logIndex += uint(len(r.Logs))
+		}
+	}
+	for _, log := range receipt.Logs {
+		log.BlockNumber = block.Number
+		log.TxHash = tx.Hash
+		log.TxIndex = receipt.TxIndex
+		log.LogIndex = logIndex
+		logIndex++
+	}
+	receipt.Bloom = CreateBloom([]*Receipt{receipt})
+	return receipt
+}
+
+// GetReceipt returns the receipt of an executed transaction. Receipts of
+// recent blocks are served from memory and older ones from the store.
+func (d *DPoSBFT) GetReceipt(txHash string) (*Receipt, error) {
+	d.mu.RLock()
+	receipt, exists := d.receipts[txHash]
+	store := d.store
+	d.mu.RUnlock()
+
+	if exists {
+		return receipt, nil
+	}
+	if store == nil {
+		return nil, fmt.Errorf("receipt not found: %s", txHash)
+	}
+	receipt, err := store.GetReceipt(txHash)
+	if err != nil {
+		return nil, fmt.Errorf("receipt %s: %w", txHash, err)
+	}
+	return receipt, nil
+}
+
+// GetBlockReceipts returns the receipts of a block in transaction order
+func (d *DPoSBFT) GetBlockReceipts(blockNumber uint64) []*Receipt {
+	d.mu.RLock()
+	receipts, exists := d.blockReceipts[blockNumber]
+	store := d.store
+	d.mu.RUnlock()
+
+	if exists || store == nil {
+		return receipts
+	}
+	receipts, _ = storedBlockReceipts(store, blockNumber)
+	return receipts
+}
+
+// cachedBlockLogs returns the bloom and receipts of a finalized block held in memory
+func (d *DPoSBFT) cachedBlockLogs(number uint64) (Bloom, []*Receipt, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	bloom, exists := d.blockBlooms[number]
+	return bloom, d.blockReceipts[number], exists
+}
+
+// FilterLogs returns logs matching the filter. Blocks whose header bloom
+// cannot contain the requested addresses and topics are skipped without
+// reading their receipts. Blocks no longer cached are read from the store
+// without holding the engine lock.
+func (d *DPoSBFT) FilterLogs(filter LogFilter) ([]*Log, error) {
+	d.mu.RLock()
+	head, store := d.currentBlock, d.store
+	d.mu.RUnlock()
+
+	if filter.ToBlock == 0 || filter.ToBlock > head {
+		filter.ToBlock = head
+	}
+	if filter.FromBlock > filter.ToBlock {
+		return nil, fmt.Errorf("invalid block range %d-%d", filter.FromBlock, filter.ToBlock)
+	}
+
+	logs := make([]*Log, 0)
+	for number := filter.FromBlock; number <= filter.ToBlock; number++ {
+		bloom, receipts, cached := d.cachedBlockLogs(number)
+		if !cached {
+			// Genesis has no logs
+			if store == nil || number == 0 {
+				continue
+			}
+			header, err := store.GetHeader(number)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read block #%d: %w", number, err)
+			}
+			bloom = header.LogsBloom
+		}
+		if !bloomMatches(&bloom, filter) {
+			continue
+		}
+		if !cached {
+			var err error
+			if receipts, err = storedBlockReceipts(store, number); err != nil {
+				return nil, fmt.Errorf("failed to read receipts of block #%d: %w", number, err)
+			}
+		}
+		for _, receipt := range receipts {
+			for _, log := range receipt.Logs {
+				if logMatches(log, filter) {
+					logs = append(logs, log)
+				}
+			}
+		}
+	}
+	return logs, nil
+}
+
+// bloomMatches reports whether a bloom may contain logs matching the filter
+func bloomMatches(bloom *Bloom, filter LogFilter) bool {
+	if len(filter.Addresses) > 0 {
+		found := false
+		for _, address := range filter.Addresses {
+			if bloom.Test(bloomValue(address)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, alternatives := range filter.Topics {
+		if len(alternatives) == 0 {
+			continue
+		}
+		found := false
+		for _, topic := range alternatives {
+			if bloom.Test(bloomValue(topic)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// logMatches checks a log exactly against the filter
+func logMatches(log *Log, filter LogFilter) bool {
+	if len(filter.Addresses) > 0 {
+		found := false
+		for _, address := range filter.Addresses {
+			if strings.EqualFold(address, log.Address) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(filter.Topics) > len(log.Topics) {
+		return false
+	}
+	for i, alternatives := range filter.Topics {
+		if len(alternatives) == 0 {
+			continue
+		}
+		found := false
+		for _, topic := range alternatives {
+			if strings.EqualFold(topic, log.Topics[i]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
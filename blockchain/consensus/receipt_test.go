@@ -0,0 +1,80 @@
+package consensus
+
+import (
+	"testing"
+	"vnc-blockchain/vm"
+)
+
+// emitLog is init code that emits one log with topic 0x2a and no data
+var emitLog = []byte{byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.LOG0 + 1), byte(vm.STOP)}
+
+// finalizeTestBlock executes txs as the next block and finalizes it
+func finalizeTestBlock(t *testing.T, d *DPoSBFT, txs ...*Transaction) *Block {
+	t.Helper()
+	runBlock(t, d, txs...)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	block := &Block{
+		Number:       d.currentBlock,
+		Timestamp:    d.lastBlockTime,
+		Transactions: txs,
+		Validator:    testAddress("validator"),
+		LogsBloom:    CreateBloom(d.blockReceipts[d.currentBlock]),
+	}
+	block.Hash = d.calculateBlockHash(block)
+	d.finalizeBlock(block)
+	return block
+}
+
+func TestReceiptsServedFromStoreAfterEviction(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice")
+	d.SetStore(newTestStore())
+
+	deploy := signedTx(testKey("alice"), &Transaction{Type: TxDeploy, GasLimit: 100_000, Data: emitLog})
+	finalizeTestBlock(t, d, deploy)
+	for i := 0; i < receiptCacheBlocks; i++ {
+		finalizeTestBlock(t, d)
+	}
+
+	d.mu.RLock()
+	_, cached := d.receipts[deploy.Hash]
+	blooms := len(d.blockBlooms)
+	d.mu.RUnlock()
+	if cached {
+		t.Fatal("receipt of an evicted block still cached")
+	}
+	if blooms > receiptCacheBlocks {
+		t.Fatalf("%d blooms cached, want at most %d", blooms, receiptCacheBlocks)
+	}
+
+	receipt, err := d.GetReceipt(deploy.Hash)
+	if err != nil {
+		t.Fatalf("receipt not read from the store: %v", err)
+	}
+	requireStatus(t, receipt, ReceiptStatusSuccess)
+	if receipts := d.GetBlockReceipts(1); len(receipts) != 1 || receipts[0].TxHash != deploy.Hash {
+		t.Fatalf("block #1 receipts %v", receipts)
+	}
+
+	logs, err := d.FilterLogs(LogFilter{Addresses: []string{receipt.ContractAddress}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].TxHash != deploy.Hash {
+		t.Fatalf("got logs %v, want the deploy's log", logs)
+	}
+}
+
+func TestReceiptsKeptInMemoryWithoutStore(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice")
+	deploy := signedTx(testKey("alice"), &Transaction{Type: TxDeploy, GasLimit: 100_000, Data: emitLog})
+	finalizeTestBlock(t, d, deploy)
+	for i := 0; i < receiptCacheBlocks; i++ {
+		finalizeTestBlock(t, d)
+	}
+
+	if _, err := d.GetReceipt(deploy.Hash); err != nil {
+		t.Fatalf("receipt dropped without a store: %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+package consensus
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ChainSource reads stored blocks for replay. It is satisfied by
+// *storage.BlockchainDB.
+type ChainSource interface {
+	GetLatestBlockNumber() (uint64, error)
+	GetBlock(blockNumber uint64) (*Block, error)
+}
+
+// Divergence describes the first stored block that does not match its replay
+type Divergence struct {
+	Height   uint64 `json:"height"`
+	Field    string `json:"field"` // missing, number, tx_root, state_root
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+func (dv *Divergence) String() string {
+	if dv.Field == "missing" {
+		return fmt.Sprintf("block #%d: missing (%s)", dv.Height, dv.Actual)
+	}
+	return fmt.Sprintf("block #%d: %s mismatch (header %s, replayed %s)", dv.Height, dv.Field, dv.Expected, dv.Actual)
+}
+
+// ReplayCheckpoint is the replayed state after a verified height, from
+// which a later verification can resume
+type ReplayCheckpoint struct {
+	Height        uint64          `json:"height"`
+	BlockHash     string          `json:"block_hash"`
+	StateRoot     string          `json:"state_root"`
+	LastBlockTime int64           `json:"last_block_time"`
+	State         json.RawMessage `json:"state"`
+	Validators    []*Validator    `json:"validators,omitempty"`
+}
+
+// VerifyResult summarizes a chain verification
+type VerifyResult struct {
+	From       uint64        `json:"from"` // first height replayed
+	To         uint64        `json:"to"`   // last height replayed
+	Verified   uint64        `json:"verified"`
+	StateRoot  string        `json:"state_root"`
+	Divergence *Divergence   `json:"divergence,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// VerifyOptions controls a chain verification
+type VerifyOptions struct {
+	To                 uint64            // last height to verify (0 = latest stored)
+	Resume             *ReplayCheckpoint // start after this checkpoint instead of genesis
+	CheckpointInterval uint64            // heights between checkpoints (0 = none)
+	OnCheckpoint       func(*ReplayCheckpoint) error
+	OnProgress         func(height uint64)
+}
+
+// VerifyChain replays stored blocks through the state machine, starting from
+// genesis or a checkpoint, and stops at the first block whose recomputed
+// TxRoot or StateRoot differs from its header. The engine must be freshly
+// created with the chain's genesis configuration and must not be running.
+func (d *DPoSBFT) VerifyChain(source ChainSource, opts VerifyOptions) (*VerifyResult, error) {
+	start := time.Now()
+
+	if opts.Resume != nil {
+		if err := d.restoreCheckpoint(source, opts.Resume); err != nil {
+			return nil, err
+		}
+	}
+
+	to := opts.To
+	if to == 0 {
+		latest, err := source.GetLatestBlockNumber()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read latest block: %w", err)
+		}
+		to = latest
+	}
+
+	result := &VerifyResult{From: d.currentBlock + 1, To: to}
+	for height := d.currentBlock + 1; height <= to; height++ {
+		block, divergence := loadStoredBlock(source, height)
+		if divergence == nil {
+			divergence = d.ReplayBlock(block)
+		}
+		if divergence != nil {
+			result.Divergence = divergence
+			break
+		}
+		result.Verified++
+
+		if opts.OnProgress != nil {
+			opts.OnProgress(height)
+		}
+		if opts.OnCheckpoint != nil && opts.CheckpointInterval > 0 && height%opts.CheckpointInterval == 0 {
+			checkpoint, err := d.checkpoint(block.Hash)
+			if err != nil {
+				return nil, err
+			}
+			if err := opts.OnCheckpoint(checkpoint); err != nil {
+				return nil, fmt.Errorf("failed to write checkpoint at #%d: %w", height, err)
+			}
+		}
+	}
+
+	d.mu.RLock()
+	result.StateRoot = d.stateRoot()
+	d.mu.RUnlock()
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// loadStoredBlock reads one stored block; unreadable blocks count as missing
+func loadStoredBlock(source ChainSource, height uint64) (*Block, *Divergence) {
+	block, err := source.GetBlock(height)
+	if err != nil {
+		return nil, &Divergence{Height: height, Field: "missing", Actual: err.Error()}
+	}
+	return block, nil
+}
+
+// ReplayBlock re-executes a stored block on top of the current state and
+// compares the recomputed roots with its header. It returns nil if the block
+// replays to the same roots.
+func (d *DPoSBFT) ReplayBlock(block *Block) *Divergence {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, divergence := d.executeBlock(block)
+
+	// Replay keeps no history; drop the receipts the execution recorded
+	d.dropReceipts(block.Number)
+	return divergence
+}
+
+// executeBlock executes a block on top of the current state, advances to
+// it and checks its TxRoot and StateRoot. It returns the gas used. The
+// state is not rolled back on a divergence. Caller must hold d.mu.
+func (d *DPoSBFT) executeBlock(block *Block) (uint64, *Divergence) {
+	if block.Number != d.currentBlock+1 {
+		return 0, &Divergence{
+			Height:   d.currentBlock + 1,
+			Field:    "number",
+			Expected: fmt.Sprintf("%d", d.currentBlock+1),
+			Actual:   fmt.Sprintf("%d", block.Number),
+		}
+	}
+	if root := d.calculateTxRoot(block.Transactions); root != block.TxRoot {
+		return 0, &Divergence{Height: block.Number, Field: "tx_root", Expected: block.TxRoot, Actual: root}
+	}
+
+	replayed := &Block{
+		Number:       block.Number,
+		PreviousHash: block.PreviousHash,
+		Timestamp:    block.Timestamp,
+		Transactions: block.Transactions,
+		Validator:    block.Validator,
+		GasLimit:     block.GasLimit,
+	}
+	gasUsed := d.executeTransactions(replayed)
+	d.currentBlock = block.Number
+	d.lastBlockTime = block.Timestamp
+	d.lastBlockHash = block.Hash
+
+	if root := d.stateRoot(); root != block.StateRoot {
+		return gasUsed, &Divergence{Height: block.Number, Field: "state_root", Expected: block.StateRoot, Actual: root}
+	}
+	return gasUsed, nil
+}
+
+// dropReceipts forgets the receipts recorded for a height. Caller must
+// hold d.mu.
+func (d *DPoSBFT) dropReceipts(height uint64) {
+	for _, receipt := range d.blockReceipts[height] {
+		delete(d.receipts, receipt.TxHash)
+	}
+	delete(d.blockReceipts, height)
+}
+
+// checkpoint captures the replayed state after the current height
+func (d *DPoSBFT) checkpoint(blockHash string) (*ReplayCheckpoint, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.captureCheckpoint(blockHash)
+}
+
+// captureCheckpoint is checkpoint for callers that hold d.mu
+func (d *DPoSBFT) captureCheckpoint(blockHash string) (*ReplayCheckpoint, error) {
+	state, err := d.stateDB.ExportState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export state: %w", err)
+	}
+	return &ReplayCheckpoint{
+		Height:        d.currentBlock,
+		BlockHash:     blockHash,
+		StateRoot:     d.stateRoot(),
+		LastBlockTime: d.lastBlockTime,
+		State:         state,
+		Validators:    d.sortedValidators(),
+	}, nil
+}
+
+// sortedValidators returns copies of the validators in address order.
+// Caller must hold d.mu.
+func (d *DPoSBFT) sortedValidators() []*Validator {
+	validators := make([]*Validator, 0, len(d.validators))
+	for _, validator := range d.validators {
+		copied := *validator
+		validators = append(validators, &copied)
+	}
+	sort.Slice(validators, func(i, j int) bool { return validators[i].Address < validators[j].Address })
+	return validators
+}
+
+// restoreCheckpoint adopts a checkpoint after checking it belongs to the
+// stored chain and that its state hashes to the recorded root
+func (d *DPoSBFT) restoreCheckpoint(source ChainSource, checkpoint *ReplayCheckpoint) error {
+	block, divergence := loadStoredBlock(source, checkpoint.Height)
+	if divergence != nil {
+		return fmt.Errorf("checkpoint height #%d: %s", checkpoint.Height, divergence)
+	}
+	if block.Hash != checkpoint.BlockHash {
+		return fmt.Errorf("checkpoint is for block %s but the stored block #%d is %s",
+			checkpoint.BlockHash, checkpoint.Height, block.Hash)
+	}
+	if block.StateRoot != checkpoint.StateRoot {
+		return fmt.Errorf("checkpoint state root %s does not match block #%d state root %s",
+			checkpoint.StateRoot, checkpoint.Height, block.StateRoot)
+	}
+	if block.Timestamp != checkpoint.LastBlockTime {
+		return fmt.Errorf("checkpoint block time %d does not match block #%d time %d",
+			checkpoint.LastBlockTime, checkpoint.Height, block.Timestamp)
+	}
+
+	return d.adoptCheckpoint(checkpoint)
+}
+
+// adoptCheckpoint replaces the engine's state, height and validators with a
+// checkpoint's, after checking that they hash to the recorded root. On error
+// the engine is left with the checkpoint's state and must be discarded.
+func (d *DPoSBFT) adoptCheckpoint(checkpoint *ReplayCheckpoint) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.stateDB.ImportState(checkpoint.State); err != nil {
+		return err
+	}
+	if checkpoint.Validators != nil {
+		d.validators = make(map[string]*Validator, len(checkpoint.Validators))
+		for _, validator := range checkpoint.Validators {
+			copied := *validator
+			d.validators[validator.Address] = &copied
+		}
+	}
+	if root := d.stateRoot(); root != checkpoint.StateRoot {
+		return fmt.Errorf("checkpoint state hashes to %s, expected %s", root, checkpoint.StateRoot)
+	}
+	d.currentBlock = checkpoint.Height
+	d.lastBlockTime = checkpoint.LastBlockTime
+	d.lastBlockHash = checkpoint.BlockHash
+	return nil
+}
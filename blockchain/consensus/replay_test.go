@@ -0,0 +1,88 @@
+package consensus
+
+import "testing"
+
+// storedChain produces blocks 1..blocks, each holding a transfer from alice,
+// and stores them with their roots
+func storedChain(t *testing.T, blocks uint64) *testStore {
+	t.Helper()
+	d := newTestEngine(t, Config{}, "alice")
+	store := newTestStore()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for number := uint64(1); number <= blocks; number++ {
+		block := &Block{
+			Number:       number,
+			PreviousHash: d.getPreviousBlockHash(),
+			Timestamp:    int64(number),
+			Transactions: []*Transaction{transfer("alice", "bob", 1, number-1)},
+			Validator:    testAddress("validator"),
+			GasLimit:     30_000_000,
+		}
+		block.TxRoot = d.calculateTxRoot(block.Transactions)
+		d.executeBlock(block)
+		block.StateRoot = d.stateRoot()
+		block.Hash = d.calculateBlockHash(block)
+		d.lastBlockHash = block.Hash
+		store.blocks[number] = block
+	}
+	return store
+}
+
+func TestVerifyChainDetectsTamperedStateRoot(t *testing.T) {
+	store := storedChain(t, 4)
+	result, err := newTestEngine(t, Config{}, "alice").VerifyChain(store, VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Divergence != nil || result.Verified != 4 || result.StateRoot != store.blocks[4].StateRoot {
+		t.Fatalf("untampered chain: verified %d to root %s, divergence %v", result.Verified, result.StateRoot, result.Divergence)
+	}
+
+	replayed := store.blocks[3].StateRoot
+	store.blocks[3].StateRoot = "0xtampered"
+	result, err = newTestEngine(t, Config{}, "alice").VerifyChain(store, VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Divergence{Height: 3, Field: "state_root", Expected: "0xtampered", Actual: replayed}
+	if result.Divergence == nil || *result.Divergence != want {
+		t.Fatalf("divergence %v, want %s", result.Divergence, &want)
+	}
+	if result.Verified != 2 {
+		t.Fatalf("verified %d blocks before the tampered one, want 2", result.Verified)
+	}
+}
+
+func TestVerifyChainResumesFromCheckpoint(t *testing.T) {
+	store := storedChain(t, 4)
+	var checkpoints []*ReplayCheckpoint
+	_, err := newTestEngine(t, Config{}, "alice").VerifyChain(store, VerifyOptions{
+		To:                 2,
+		CheckpointInterval: 2,
+		OnCheckpoint: func(checkpoint *ReplayCheckpoint) error {
+			checkpoints = append(checkpoints, checkpoint)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoints) != 1 || checkpoints[0].Height != 2 {
+		t.Fatalf("got %d checkpoints, want the one of block #2", len(checkpoints))
+	}
+
+	result, err := NewDPoSBFT(Config{}).VerifyChain(store, VerifyOptions{Resume: checkpoints[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Divergence != nil || result.From != 3 || result.To != 4 || result.StateRoot != store.blocks[4].StateRoot {
+		t.Fatalf("resumed #%d-#%d to root %s, divergence %v", result.From, result.To, result.StateRoot, result.Divergence)
+	}
+
+	// A checkpoint of another block is refused
+	checkpoints[0].BlockHash = store.blocks[1].Hash
+	if _, err := NewDPoSBFT(Config{}).VerifyChain(store, VerifyOptions{Resume: checkpoints[0]}); err == nil {
+		t.Fatal("resumed from a checkpoint that does not match the stored block")
+	}
+}
@@ -0,0 +1,372 @@
+package consensus
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Snapshot stream format.
+//
+// A snapshot is the magic "VNCSNAP" and a version byte followed by frames,
+// each a kind byte, a uvarint payload length and the payload. The first
+// frame is the JSON manifest; then come the recent headers, the validator
+// set, the state in chunks and an empty end frame. The manifest records the
+// SHA-256 of every later payload, so a reader verifies each frame as it
+// arrives. The manifest itself is trusted through the newest header, whose
+// hash must match a block hash the importer already trusts.
+
+// SnapshotVersion is the version of the snapshot format written
+const SnapshotVersion byte = 1
+
+const (
+	SnapshotChunkSize = 1 << 20 // bytes of state per chunk
+	SnapshotHeaders   = 128     // recent headers included
+	maxSnapshotFrame  = 64 << 20
+)
+
+var snapshotMagic = []byte("VNCSNAP")
+
+// Snapshot frame kinds
+const (
+	frameEnd byte = iota
+	frameManifest
+	frameHeaders
+	frameValidators
+	frameState
+)
+
+// SnapshotManifest describes a snapshot and the hashes of its frames
+type SnapshotManifest struct {
+	Version        byte     `json:"version"`
+	ChainID        uint64   `json:"chain_id"`
+	Height         uint64   `json:"height"`
+	BlockHash      string   `json:"block_hash"`
+	StateRoot      string   `json:"state_root"`
+	LastBlockTime  int64    `json:"last_block_time"`
+	Headers        int      `json:"headers"`
+	HeadersHash    string   `json:"headers_hash"`
+	Validators     int      `json:"validators"`
+	ValidatorsHash string   `json:"validators_hash"`
+	StateSize      int      `json:"state_size"`
+	ChunkSize      int      `json:"chunk_size"`
+	StateChunks    []string `json:"state_chunks"`
+}
+
+// SnapshotStore persists an imported snapshot. It is satisfied by
+// *storage.BlockchainDB.
+type SnapshotStore interface {
+	// AdoptSnapshot makes the snapshot the base of an empty database:
+	// later blocks are replayed on top of base
+	AdoptSnapshot(base *ReplayCheckpoint, headers []*Block, accounts []*Account) error
+}
+
+// WriteSnapshot streams the engine's current state, its validators and the
+// given recent headers, which must end at the current height
+func (d *DPoSBFT) WriteSnapshot(w io.Writer, headers []*Block) (*SnapshotManifest, error) {
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("snapshot needs at least the header at the snapshot height")
+	}
+	head := headers[len(headers)-1]
+	checkpoint, err := d.checkpoint(head.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if head.Number != checkpoint.Height {
+		return nil, fmt.Errorf("headers end at #%d but the state is at #%d", head.Number, checkpoint.Height)
+	}
+	if head.StateRoot != checkpoint.StateRoot {
+		return nil, fmt.Errorf("header #%d state root %s does not match state %s", head.Number, head.StateRoot, checkpoint.StateRoot)
+	}
+
+	headerData := encodeSnapshotHeaders(headers)
+	validatorData := marshalRecord(func(e *encoder) {
+		e.uint(uint64(len(checkpoint.Validators)))
+		for _, validator := range checkpoint.Validators {
+			validator.encode(e)
+		}
+	})
+
+	manifest := &SnapshotManifest{
+		Version:        SnapshotVersion,
+		ChainID:        d.config.ChainID,
+		Height:         checkpoint.Height,
+		BlockHash:      checkpoint.BlockHash,
+		StateRoot:      checkpoint.StateRoot,
+		LastBlockTime:  checkpoint.LastBlockTime,
+		Headers:        len(headers),
+		HeadersHash:    sha256Hex(headerData),
+		Validators:     len(checkpoint.Validators),
+		ValidatorsHash: sha256Hex(validatorData),
+		StateSize:      len(checkpoint.State),
+		ChunkSize:      SnapshotChunkSize,
+	}
+	chunks := chunkBytes(checkpoint.State, SnapshotChunkSize)
+	for _, chunk := range chunks {
+		manifest.StateChunks = append(manifest.StateChunks, sha256Hex(chunk))
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(append(append([]byte(nil), snapshotMagic...), SnapshotVersion)); err != nil {
+		return nil, err
+	}
+	if err := writeFrame(w, frameManifest, manifestData); err != nil {
+		return nil, err
+	}
+	if err := writeFrame(w, frameHeaders, headerData); err != nil {
+		return nil, err
+	}
+	if err := writeFrame(w, frameValidators, validatorData); err != nil {
+		return nil, err
+	}
+	for _, chunk := range chunks {
+		if err := writeFrame(w, frameState, chunk); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeFrame(w, frameEnd, nil); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ImportSnapshot reads a snapshot, verifies it against a trusted hash of the
+// block at its height, and adopts it: the engine takes over its state and
+// validators, and store records it as the base of the chain. The engine must
+// be freshly created with the chain's configuration and must not be running.
+func (d *DPoSBFT) ImportSnapshot(r io.Reader, trustedHash string, store SnapshotStore) (*SnapshotManifest, error) {
+	reader := bufio.NewReader(r)
+
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+	if !bytes.Equal(header[:len(snapshotMagic)], snapshotMagic) {
+		return nil, fmt.Errorf("not a snapshot file")
+	}
+	if version := header[len(snapshotMagic)]; version != SnapshotVersion {
+		return nil, fmt.Errorf("snapshot version %d is not supported (want %d)", version, SnapshotVersion)
+	}
+
+	manifestData, err := readFrame(reader, frameManifest, maxSnapshotFrame)
+	if err != nil {
+		return nil, err
+	}
+	var manifest SnapshotManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid snapshot manifest: %w", err)
+	}
+	if err := d.checkManifest(&manifest, trustedHash); err != nil {
+		return nil, err
+	}
+
+	headerData, err := readVerifiedFrame(reader, frameHeaders, maxSnapshotFrame, manifest.HeadersHash)
+	if err != nil {
+		return nil, fmt.Errorf("headers: %w", err)
+	}
+	headers, err := decodeSnapshotHeaders(headerData)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.checkSnapshotHeaders(&manifest, headers); err != nil {
+		return nil, err
+	}
+
+	validatorData, err := readVerifiedFrame(reader, frameValidators, maxSnapshotFrame, manifest.ValidatorsHash)
+	if err != nil {
+		return nil, fmt.Errorf("validators: %w", err)
+	}
+	var validators []*Validator
+	if err := unmarshalRecord(validatorData, "validator set", func(dec *decoder) {
+		validators = make([]*Validator, dec.count())
+		for i := range validators {
+			validators[i] = new(Validator)
+			validators[i].decode(dec)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	if len(validators) != manifest.Validators {
+		return nil, fmt.Errorf("snapshot holds %d validators, manifest lists %d", len(validators), manifest.Validators)
+	}
+
+	state := make([]byte, 0, manifest.StateSize)
+	for i, hash := range manifest.StateChunks {
+		chunk, err := readVerifiedFrame(reader, frameState, manifest.ChunkSize, hash)
+		if err != nil {
+			return nil, fmt.Errorf("state chunk %d: %w", i, err)
+		}
+		state = append(state, chunk...)
+	}
+	if len(state) != manifest.StateSize {
+		return nil, fmt.Errorf("state is %d bytes, manifest lists %d", len(state), manifest.StateSize)
+	}
+	if _, err := readFrame(reader, frameEnd, 0); err != nil {
+		return nil, err
+	}
+
+	base := &ReplayCheckpoint{
+		Height:        manifest.Height,
+		BlockHash:     manifest.BlockHash,
+		StateRoot:     manifest.StateRoot,
+		LastBlockTime: manifest.LastBlockTime,
+		State:         state,
+		Validators:    validators,
+	}
+	if err := d.adoptCheckpoint(base); err != nil {
+		return nil, err
+	}
+	if err := store.AdoptSnapshot(base, headers, d.stateDB.takeDirtyAccounts()); err != nil {
+		return nil, fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	return &manifest, nil
+}
+
+// checkManifest rejects snapshots of another chain or block before any
+// payload is read
+func (d *DPoSBFT) checkManifest(manifest *SnapshotManifest, trustedHash string) error {
+	if manifest.Version != SnapshotVersion {
+		return fmt.Errorf("manifest version %d does not match the stream version", manifest.Version)
+	}
+	if manifest.ChainID != d.config.ChainID {
+		return fmt.Errorf("snapshot is for chain %d, not %d", manifest.ChainID, d.config.ChainID)
+	}
+	if manifest.BlockHash != trustedHash {
+		return fmt.Errorf("snapshot is of block %s, not the trusted block %s", manifest.BlockHash, trustedHash)
+	}
+	if manifest.ChunkSize <= 0 || manifest.ChunkSize > maxSnapshotFrame {
+		return fmt.Errorf("invalid chunk size %d", manifest.ChunkSize)
+	}
+	if manifest.StateSize > len(manifest.StateChunks)*manifest.ChunkSize {
+		return fmt.Errorf("state size %d exceeds its %d chunks", manifest.StateSize, len(manifest.StateChunks))
+	}
+	if manifest.Headers < 1 {
+		return fmt.Errorf("snapshot has no headers")
+	}
+	return nil
+}
+
+// checkSnapshotHeaders checks that the headers hash correctly, link to each
+// other and end at the trusted block with the manifest's state root and time
+func (d *DPoSBFT) checkSnapshotHeaders(manifest *SnapshotManifest, headers []*Block) error {
+	if len(headers) != manifest.Headers {
+		return fmt.Errorf("snapshot holds %d headers, manifest lists %d", len(headers), manifest.Headers)
+	}
+	for i, block := range headers {
+		if hash := d.calculateBlockHash(block); hash != block.Hash {
+			return fmt.Errorf("header #%d hashes to %s, not %s", block.Number, hash, block.Hash)
+		}
+		if i == 0 {
+			continue
+		}
+		parent := headers[i-1]
+		if !LinksTo(parent, block) {
+			return fmt.Errorf("header #%d does not follow header #%d", block.Number, parent.Number)
+		}
+	}
+	head := headers[len(headers)-1]
+	if head.Number != manifest.Height || head.Hash != manifest.BlockHash {
+		return fmt.Errorf("headers end at #%d %s, not the snapshot block #%d %s",
+			head.Number, head.Hash, manifest.Height, manifest.BlockHash)
+	}
+	if head.StateRoot != manifest.StateRoot {
+		return fmt.Errorf("snapshot block state root %s does not match manifest state root %s", head.StateRoot, manifest.StateRoot)
+	}
+	if head.Timestamp != manifest.LastBlockTime {
+		return fmt.Errorf("snapshot block time %d does not match manifest block time %d", head.Timestamp, manifest.LastBlockTime)
+	}
+	return nil
+}
+
+// encodeSnapshotHeaders encodes blocks without their transactions
+func encodeSnapshotHeaders(headers []*Block) []byte {
+	return marshalRecord(func(e *encoder) {
+		e.uint(uint64(len(headers)))
+		for _, block := range headers {
+			stripped := *block
+			stripped.Transactions = nil
+			stripped.encode(e)
+		}
+	})
+}
+
+// decodeSnapshotHeaders decodes headers written by encodeSnapshotHeaders
+func decodeSnapshotHeaders(data []byte) ([]*Block, error) {
+	var headers []*Block
+	err := unmarshalRecord(data, "header list", func(dec *decoder) {
+		headers = make([]*Block, dec.count())
+		for i := range headers {
+			headers[i] = new(Block)
+			headers[i].decode(dec)
+		}
+	})
+	return headers, err
+}
+
+// writeFrame writes one frame
+func writeFrame(w io.Writer, kind byte, payload []byte) error {
+	frame := binary.AppendUvarint([]byte{kind}, uint64(len(payload)))
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readFrame reads one frame of the expected kind, refusing payloads over max
+func readFrame(r *bufio.Reader, kind byte, max int) ([]byte, error) {
+	got, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("truncated snapshot: %w", err)
+	}
+	if got != kind {
+		return nil, fmt.Errorf("unexpected frame kind %d, want %d", got, kind)
+	}
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("truncated snapshot: %w", err)
+	}
+	if length > uint64(max) {
+		return nil, fmt.Errorf("frame of %d bytes exceeds limit %d", length, max)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("truncated snapshot: %w", err)
+	}
+	return payload, nil
+}
+
+// readVerifiedFrame reads a frame and checks its payload hash
+func readVerifiedFrame(r *bufio.Reader, kind byte, max int, hash string) ([]byte, error) {
+	payload, err := readFrame(r, kind, max)
+	if err != nil {
+		return nil, err
+	}
+	if got := sha256Hex(payload); got != hash {
+		return nil, fmt.Errorf("payload hashes to %s, manifest lists %s", got, hash)
+	}
+	return payload, nil
+}
+
+// chunkBytes splits data into chunks of at most size bytes
+func chunkBytes(data []byte, size int) [][]byte {
+	var chunks [][]byte
+	for len(data) > size {
+		chunks = append(chunks, data[:size])
+		data = data[size:]
+	}
+	return append(chunks, data)
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,96 @@
+package consensus
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testSnapshotStore records an adopted snapshot
+type testSnapshotStore struct {
+	base *ReplayCheckpoint
+}
+
+func (s *testSnapshotStore) AdoptSnapshot(base *ReplayCheckpoint, headers []*Block, accounts []*Account) error {
+	s.base = base
+	return nil
+}
+
+// newSnapshotEngine creates an engine at block #1 with a validator and
+// some state, and the header of block #1
+func newSnapshotEngine(t *testing.T) (*DPoSBFT, *Block) {
+	t.Helper()
+	d := newTestEngine(t, Config{ChainID: 7, MaxValidators: 1}, "alice")
+	if err := d.RegisterValidator(testAddress("validator"), vnc(10), 0.1); err != nil {
+		t.Fatal(err)
+	}
+	runBlock(t, d, transfer("alice", "bob", 5, 0))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	head := &Block{
+		Number:       d.currentBlock,
+		PreviousHash: GenesisHeader().Hash,
+		Timestamp:    d.lastBlockTime,
+		StateRoot:    d.stateRoot(),
+	}
+	head.Hash = d.calculateBlockHash(head)
+	d.lastBlockHash = head.Hash
+	return d, head
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	d, head := newSnapshotEngine(t)
+	var buf bytes.Buffer
+	if _, err := d.WriteSnapshot(&buf, []*Block{head}); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := NewDPoSBFT(Config{ChainID: 7})
+	store := &testSnapshotStore{}
+	if _, err := imported.ImportSnapshot(&buf, head.Hash, store); err != nil {
+		t.Fatal(err)
+	}
+	if imported.stateDB.GetNonce(testAddress("alice")) != 1 {
+		t.Fatal("nonce not imported")
+	}
+	if len(imported.validators) != 1 || store.base == nil {
+		t.Fatal("validators or base not adopted")
+	}
+}
+
+func TestCheckpointTamperingRejected(t *testing.T) {
+	cases := map[string]func(d *DPoSBFT){
+		"nonce":      func(d *DPoSBFT) { d.stateDB.nonces[testAddress("alice")] = 0 },
+		"burned":     func(d *DPoSBFT) { d.stateDB.burned.SetInt64(1) },
+		"pause":      func(d *DPoSBFT) { d.stateDB.pause = PauseState{Paused: true} },
+		"freeze log": func(d *DPoSBFT) { d.stateDB.freezeLog = append(d.stateDB.freezeLog, &FreezeEvent{Action: "unfreeze"}) },
+		"validator":  func(d *DPoSBFT) { d.validators[testAddress("validator")].Stake = vnc(1_000_000) },
+	}
+	for name, tamper := range cases {
+		d, head := newSnapshotEngine(t)
+		tamper(d)
+		checkpoint, err := d.checkpoint(head.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkpoint.StateRoot = head.StateRoot
+
+		if err := NewDPoSBFT(Config{ChainID: 7}).adoptCheckpoint(checkpoint); err == nil {
+			t.Errorf("checkpoint with a tampered %s adopted", name)
+		}
+	}
+}
+
+func TestSnapshotBlockTimeChecked(t *testing.T) {
+	d, head := newSnapshotEngine(t)
+	manifest := &SnapshotManifest{
+		Height:        head.Number,
+		BlockHash:     head.Hash,
+		StateRoot:     head.StateRoot,
+		LastBlockTime: head.Timestamp + 3600,
+		Headers:       1,
+	}
+	if err := d.checkSnapshotHeaders(manifest, []*Block{head}); err == nil {
+		t.Fatal("manifest block time not checked against the header")
+	}
+}
@@ -0,0 +1,180 @@
+package consensus
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// SponsorPolicy controls which meta-transactions a fee payer will pay for.
+// Empty Senders or Actions accept any sender or any sponsorable action.
+type SponsorPolicy struct {
+	Sponsor   string   `json:"sponsor"`
+	Senders   []string `json:"senders,omitempty"`
+	Actions   []TxType `json:"actions,omitempty"`
+	MaxFee    *big.Int `json:"max_fee,omitempty"` // per transaction, nil = unlimited
+	UpdatedAt uint64   `json:"updated_at"`
+}
+
+// SponsorPolicyPayload is the JSON body carried in Data by a sponsor policy
+// transaction. Revoke removes the sender's policy.
+type SponsorPolicyPayload struct {
+	Senders []string `json:"senders,omitempty"`
+	Actions []TxType `json:"actions,omitempty"`
+	MaxFee  string   `json:"max_fee,omitempty"`
+	Revoke  bool     `json:"revoke,omitempty"`
+}
+
+// SignAsFeePayer adds the fee payer's signature to a signed meta-transaction.
+// The sender's signature already commits to the fee payer through the hash.
+func SignAsFeePayer(tx *Transaction, key ed25519.PrivateKey) {
+	tx.FeePayerSignature = signHash(key, tx.Hash)
+}
+
+// IsSponsored reports whether a transaction's fee is paid by a sponsor
+func (tx *Transaction) IsSponsored() bool {
+	return tx.FeePayer != ""
+}
+
+// allows reports whether the policy covers a sender and action
+func (p *SponsorPolicy) allows(sender string, action TxType) bool {
+	if len(p.Senders) > 0 && !containsString(p.Senders, sender) {
+		return false
+	}
+	if len(p.Actions) > 0 {
+		for _, allowed := range p.Actions {
+			if allowed == action {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// GetSponsorPolicy returns the policy registered by a sponsor
+func (s *StateDB) GetSponsorPolicy(sponsor string) (*SponsorPolicy, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	policy, exists := s.sponsorPolicies[sponsor]
+	if !exists {
+		return nil, false
+	}
+	copied := *policy
+	copied.Senders = append([]string(nil), policy.Senders...)
+	copied.Actions = append([]TxType(nil), policy.Actions...)
+	if policy.MaxFee != nil {
+		copied.MaxFee = new(big.Int).Set(policy.MaxFee)
+	}
+	return &copied, true
+}
+
+// sponsorDigest hashes sponsor policies in sponsor order. Caller must hold s.mu.
+func (s *StateDB) sponsorDigest() string {
+	sponsors := make([]string, 0, len(s.sponsorPolicies))
+	for sponsor := range s.sponsorPolicies {
+		sponsors = append(sponsors, sponsor)
+	}
+	sort.Strings(sponsors)
+
+	h := sha256.New()
+	for _, sponsor := range sponsors {
+		policy := s.sponsorPolicies[sponsor]
+		fmt.Fprintf(h, "%s:%v:%v:%s;", sponsor, policy.Senders, policy.Actions, bigString(policy.MaxFee))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verifySponsorship checks the fee payer's signature, policy and balance
+func (d *DPoSBFT) verifySponsorship(tx *Transaction) error {
+	if tx.FeePayer == tx.From {
+		return fmt.Errorf("fee payer must differ from sender")
+	}
+	if !verifyHashSignature(tx.FeePayer, tx.Hash, tx.FeePayerSignature) {
+		return fmt.Errorf("invalid fee payer signature")
+	}
+	if tx.Type.IsPrivileged() || tx.Type == TxSponsorPolicy {
+		return fmt.Errorf("transaction type %d cannot be sponsored", tx.Type)
+	}
+	if d.stateDB.IsFrozen(tx.FeePayer) {
+		return fmt.Errorf("fee payer %s is frozen", tx.FeePayer)
+	}
+
+	policy, exists := d.stateDB.GetSponsorPolicy(tx.FeePayer)
+	if !exists {
+		return fmt.Errorf("%s does not sponsor transactions", tx.FeePayer)
+	}
+	if !policy.allows(tx.From, tx.Type) {
+		return fmt.Errorf("%s does not sponsor type %d transactions from %s", tx.FeePayer, tx.Type, tx.From)
+	}
+
+	maxFee := maxTxFee(tx)
+	if policy.MaxFee != nil && maxFee.Cmp(policy.MaxFee) > 0 {
+		return fmt.Errorf("fee %s exceeds sponsor limit %s", maxFee.String(), policy.MaxFee.String())
+	}
+	if balance := d.stateDB.GetBalance(tx.FeePayer); balance.Cmp(maxFee) < 0 {
+		return fmt.Errorf("fee payer balance %s below maximum fee %s", balance.String(), maxFee.String())
+	}
+	return nil
+}
+
+// applySponsorPolicy sets or revokes the sender's sponsor policy
+func (d *DPoSBFT) applySponsorPolicy(tx *Transaction, block *Block) error {
+	var payload SponsorPolicyPayload
+	if err := json.Unmarshal(tx.Data, &payload); err != nil {
+		return fmt.Errorf("invalid sponsor policy payload: %w", err)
+	}
+
+	policy := &SponsorPolicy{
+		Sponsor:   tx.From,
+		Senders:   payload.Senders,
+		Actions:   payload.Actions,
+		UpdatedAt: block.Number,
+	}
+	if payload.MaxFee != "" {
+		maxFee, ok := new(big.Int).SetString(payload.MaxFee, 10)
+		if !ok || maxFee.Sign() < 0 {
+			return fmt.Errorf("invalid max fee %q", payload.MaxFee)
+		}
+		policy.MaxFee = maxFee
+	}
+	for _, action := range policy.Actions {
+		if action.IsPrivileged() || action == TxSponsorPolicy {
+			return fmt.Errorf("transaction type %d cannot be sponsored", action)
+		}
+	}
+
+	s := d.stateDB
+	s.mu.Lock()
+	if payload.Revoke {
+		delete(s.sponsorPolicies, tx.From)
+	} else {
+		s.sponsorPolicies[tx.From] = policy
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// GetSponsorPolicy returns the policy registered by a sponsor
+func (d *DPoSBFT) GetSponsorPolicy(sponsor string) (*SponsorPolicy, error) {
+	policy, exists := d.stateDB.GetSponsorPolicy(sponsor)
+	if !exists {
+		return nil, fmt.Errorf("no sponsor policy for %s", sponsor)
+	}
+	return policy, nil
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
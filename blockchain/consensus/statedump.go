@@ -0,0 +1,221 @@
+package consensus
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"vnc-blockchain/vm"
+)
+
+// stateDump is the serialized form of the full state machine state.
+// Collections are written as sorted slices so the encoding is deterministic.
+type stateDump struct {
+	Balances        map[string]*big.Int          `json:"balances"`
+	Nonces          map[string]uint64            `json:"nonces"`
+	Vesting         []*VestingAccount            `json:"vesting,omitempty"`
+	Frozen          []*FreezeRecord              `json:"frozen,omitempty"`
+	FreezeLog       []*FreezeEvent               `json:"freeze_log,omitempty"`
+	Pause           PauseState                   `json:"pause"`
+	Code            map[string][]byte            `json:"code,omitempty"`
+	Storage         map[string]map[string]string `json:"storage,omitempty"` // hex slot -> hex value
+	TimeLocks       []*TimeLock                  `json:"time_locks,omitempty"`
+	Assets          []*Asset                     `json:"assets,omitempty"`
+	AssetBalances   []assetBalanceEntry          `json:"asset_balances,omitempty"`
+	Allowances      []allowanceEntry             `json:"allowances,omitempty"`
+	SponsorPolicies []*SponsorPolicy             `json:"sponsor_policies,omitempty"`
+	Escrows         []*Escrow                    `json:"escrows,omitempty"`
+	TotalSupply     *big.Int                     `json:"total_supply"`
+	Burned          *big.Int                     `json:"burned"`
+}
+
+type assetBalanceEntry struct {
+	Address string   `json:"address"`
+	Symbol  string   `json:"symbol"`
+	Balance *big.Int `json:"balance"`
+}
+
+type allowanceEntry struct {
+	Symbol  string   `json:"symbol"`
+	Owner   string   `json:"owner"`
+	Spender string   `json:"spender"`
+	Amount  *big.Int `json:"amount"`
+}
+
+// ExportState encodes the full state. The encoding is taken under the
+// state lock, so it is a consistent copy.
+func (s *StateDB) ExportState() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dump := stateDump{
+		Balances:    s.balances,
+		Nonces:      s.nonces,
+		FreezeLog:   s.freezeLog,
+		Pause:       s.pause,
+		Code:        s.code,
+		Storage:     make(map[string]map[string]string, len(s.storage)),
+		TotalSupply: s.totalSupply,
+		Burned:      s.burned,
+	}
+	for _, account := range s.vesting {
+		dump.Vesting = append(dump.Vesting, account)
+	}
+	sort.Slice(dump.Vesting, func(i, j int) bool { return dump.Vesting[i].Address < dump.Vesting[j].Address })
+	for _, record := range s.frozen {
+		dump.Frozen = append(dump.Frozen, record)
+	}
+	sort.Slice(dump.Frozen, func(i, j int) bool { return dump.Frozen[i].Address < dump.Frozen[j].Address })
+	for address, slots := range s.storage {
+		encoded := make(map[string]string, len(slots))
+		for slot, value := range slots {
+			encoded[hex.EncodeToString(slot[:])] = hex.EncodeToString(value[:])
+		}
+		dump.Storage[address] = encoded
+	}
+	for _, lock := range s.timeLocks {
+		dump.TimeLocks = append(dump.TimeLocks, lock)
+	}
+	sort.Slice(dump.TimeLocks, func(i, j int) bool { return dump.TimeLocks[i].ID < dump.TimeLocks[j].ID })
+	for _, asset := range s.assets {
+		dump.Assets = append(dump.Assets, asset)
+	}
+	sort.Slice(dump.Assets, func(i, j int) bool { return dump.Assets[i].Symbol < dump.Assets[j].Symbol })
+	for key, balance := range s.assetBalances {
+		dump.AssetBalances = append(dump.AssetBalances, assetBalanceEntry{key.Address, key.Symbol, balance})
+	}
+	sort.Slice(dump.AssetBalances, func(i, j int) bool {
+		a, b := dump.AssetBalances[i], dump.AssetBalances[j]
+		return a.Address < b.Address || (a.Address == b.Address && a.Symbol < b.Symbol)
+	})
+	for key, amount := range s.allowances {
+		dump.Allowances = append(dump.Allowances, allowanceEntry{key.Symbol, key.Owner, key.Spender, amount})
+	}
+	sort.Slice(dump.Allowances, func(i, j int) bool {
+		a, b := dump.Allowances[i], dump.Allowances[j]
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		return a.Owner < b.Owner || (a.Owner == b.Owner && a.Spender < b.Spender)
+	})
+	for _, policy := range s.sponsorPolicies {
+		dump.SponsorPolicies = append(dump.SponsorPolicies, policy)
+	}
+	sort.Slice(dump.SponsorPolicies, func(i, j int) bool {
+		return dump.SponsorPolicies[i].Sponsor < dump.SponsorPolicies[j].Sponsor
+	})
+	for _, escrow := range s.escrows {
+		dump.Escrows = append(dump.Escrows, escrow)
+	}
+	sort.Slice(dump.Escrows, func(i, j int) bool { return dump.Escrows[i].ID < dump.Escrows[j].ID })
+
+	return json.Marshal(dump)
+}
+
+// ImportState replaces the full state with one written by ExportState
+func (s *StateDB) ImportState(data []byte) error {
+	var dump stateDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return fmt.Errorf("invalid state dump: %w", err)
+	}
+
+	imported := NewStateDB()
+	for address, balance := range dump.Balances {
+		imported.balances[address] = balance
+	}
+	for address, nonce := range dump.Nonces {
+		imported.nonces[address] = nonce
+	}
+	for _, account := range dump.Vesting {
+		imported.vesting[account.Address] = account
+	}
+	for _, record := range dump.Frozen {
+		imported.frozen[record.Address] = record
+	}
+	imported.freezeLog = dump.FreezeLog
+	imported.pause = dump.Pause
+	for address, code := range dump.Code {
+		imported.code[address] = code
+	}
+	for address, slots := range dump.Storage {
+		decoded := make(map[vm.Word]vm.Word, len(slots))
+		for slot, value := range slots {
+			key, err := decodeWord(slot)
+			if err != nil {
+				return fmt.Errorf("invalid storage slot for %s: %w", address, err)
+			}
+			word, err := decodeWord(value)
+			if err != nil {
+				return fmt.Errorf("invalid storage value for %s: %w", address, err)
+			}
+			decoded[key] = word
+		}
+		imported.storage[address] = decoded
+	}
+	for _, lock := range dump.TimeLocks {
+		imported.timeLocks[lock.ID] = lock
+	}
+	for _, asset := range dump.Assets {
+		imported.assets[asset.Symbol] = asset
+	}
+	for _, entry := range dump.AssetBalances {
+		imported.assetBalances[assetKey{entry.Address, entry.Symbol}] = entry.Balance
+	}
+	for _, entry := range dump.Allowances {
+		imported.allowances[allowanceKey{entry.Symbol, entry.Owner, entry.Spender}] = entry.Amount
+	}
+	for _, policy := range dump.SponsorPolicies {
+		imported.sponsorPolicies[policy.Sponsor] = policy
+	}
+	for _, escrow := range dump.Escrows {
+		imported.escrows[escrow.ID] = escrow
+	}
+	if dump.TotalSupply != nil {
+		imported.totalSupply = dump.TotalSupply
+	}
+	if dump.Burned != nil {
+		imported.burned = dump.Burned
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balances = imported.balances
+	s.nonces = imported.nonces
+	s.vesting = imported.vesting
+	s.frozen = imported.frozen
+	s.freezeLog = imported.freezeLog
+	s.pause = imported.pause
+	s.code = imported.code
+	s.storage = imported.storage
+	s.timeLocks = imported.timeLocks
+	s.assets = imported.assets
+	s.assetBalances = imported.assetBalances
+	s.allowances = imported.allowances
+	s.sponsorPolicies = imported.sponsorPolicies
+	s.escrows = imported.escrows
+	s.dirty = make(map[string]struct{})
+	for address := range s.balances {
+		s.dirty[address] = struct{}{}
+	}
+	for address := range s.nonces {
+		s.dirty[address] = struct{}{}
+	}
+	s.totalSupply = imported.totalSupply
+	s.burned = imported.burned
+	return nil
+}
+
+// decodeWord parses a hex-encoded 32-byte word
+func decodeWord(encoded string) (vm.Word, error) {
+	var word vm.Word
+	raw, err := hex.DecodeString(encoded)
+	if err != nil {
+		return word, err
+	}
+	if len(raw) != len(word) {
+		return word, fmt.Errorf("word is %d bytes, want %d", len(raw), len(word))
+	}
+	copy(word[:], raw)
+	return word, nil
+}
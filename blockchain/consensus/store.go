@@ -0,0 +1,158 @@
+package consensus
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// BlockStore persists finalized chain data. It is satisfied by
+// *storage.BlockchainDB.
+type BlockStore interface {
+	// CommitBlock atomically writes a block with its transactions, receipts,
+	// changed accounts and supply breakdown, and advances the head to it
+	CommitBlock(block *Block, receipts []*Receipt, accounts []*Account, supply *SupplyInfo) error
+
+	// GetSupply returns the supply breakdown committed with a block
+	GetSupply(height uint64) (*SupplyInfo, error)
+
+	// GetBlock returns a committed block with its transactions
+	GetBlock(number uint64) (*Block, error)
+
+	// GetHeader returns a committed block without its transactions
+	GetHeader(number uint64) (*Block, error)
+
+	// GetReceipt returns the receipt of a committed transaction
+	GetReceipt(txHash string) (*Receipt, error)
+}
+
+// CheckpointStore is a BlockStore that keeps replay checkpoints, so that a
+// node which prunes old blocks can still rebuild its state by replaying
+// from one. It is satisfied by *storage.BlockchainDB.
+type CheckpointStore interface {
+	// CheckpointInterval is the number of heights between the checkpoints
+	// the store keeps, or 0 if it keeps none
+	CheckpointInterval() uint64
+
+	// SaveCheckpoint stores the state after a committed block
+	SaveCheckpoint(checkpoint *ReplayCheckpoint) error
+}
+
+// receiptCacheBlocks is the number of recent finalized blocks whose receipts
+// and blooms are kept in memory once a store is attached. Older ones are
+// read from the store.
+const receiptCacheBlocks = 128
+
+// SetStore attaches persistent storage for finalized blocks
+func (d *DPoSBFT) SetStore(store BlockStore) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.store = store
+}
+
+// persistBlock writes a finalized block with its transactions, receipts,
+// supply breakdown and the accounts changed since the previous commit.
+// Caller must hold d.mu.
+func (d *DPoSBFT) persistBlock(block *Block) error {
+	if d.store == nil {
+		return nil
+	}
+
+	accounts := d.stateDB.takeDirtyAccounts()
+	if err := d.store.CommitBlock(block, d.blockReceipts[block.Number], accounts, d.supplyHistory[block.Number]); err != nil {
+		// Keep the accounts dirty so the next commit writes them
+		for _, account := range accounts {
+			d.stateDB.markDirty(account.Address)
+		}
+		return err
+	}
+	return nil
+}
+
+// saveCheckpoint gives the store a checkpoint of the state after a
+// committed block, if it keeps one at that height. A failure only delays
+// pruning until the next checkpoint. Caller must hold d.mu.
+func (d *DPoSBFT) saveCheckpoint(block *Block) {
+	store, ok := d.store.(CheckpointStore)
+	if !ok {
+		return
+	}
+	if interval := store.CheckpointInterval(); interval == 0 || block.Number%interval != 0 {
+		return
+	}
+	checkpoint, err := d.captureCheckpoint(block.Hash)
+	if err == nil && checkpoint.StateRoot != block.StateRoot {
+		err = fmt.Errorf("state hashes to %s, block records %s", checkpoint.StateRoot, block.StateRoot)
+	}
+	if err == nil {
+		err = store.SaveCheckpoint(checkpoint)
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Failed to save checkpoint at block #%d: %v\n", block.Number, err)
+	}
+}
+
+// trimReceiptCache forgets the receipts and bloom of the block that falls
+// out of the cache window at height. Without a store the memory copy is the
+// only one and is kept. Caller must hold d.mu.
+func (d *DPoSBFT) trimReceiptCache(height uint64) {
+	if d.store == nil || height < receiptCacheBlocks {
+		return
+	}
+	evicted := height - receiptCacheBlocks
+	d.dropReceipts(evicted)
+	delete(d.blockBlooms, evicted)
+}
+
+// storedBlockReceipts reads the receipts of a committed block in
+// transaction order
+func storedBlockReceipts(store BlockStore, number uint64) ([]*Receipt, error) {
+	block, err := store.GetBlock(number)
+	if err != nil {
+		return nil, err
+	}
+	receipts := make([]*Receipt, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		receipt, err := store.GetReceipt(tx.Hash)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
+
+// touch records that an account changed. Caller must hold s.mu.
+func (s *StateDB) touch(address string) {
+	s.dirty[address] = struct{}{}
+}
+
+// markDirty records that an account changed
+func (s *StateDB) markDirty(address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.touch(address)
+}
+
+// takeDirtyAccounts returns the accounts changed since the last call, in
+// address order, and resets the change set
+func (s *StateDB) takeDirtyAccounts() []*Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := make([]*Account, 0, len(s.dirty))
+	for address := range s.dirty {
+		balance := new(big.Int)
+		if stored, exists := s.balances[address]; exists {
+			balance.Set(stored)
+		}
+		accounts = append(accounts, &Account{
+			Address: address,
+			Balance: balance,
+			Nonce:   s.nonces[address],
+		})
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Address < accounts[j].Address })
+	s.dirty = make(map[string]struct{})
+	return accounts
+}
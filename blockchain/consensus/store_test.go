@@ -0,0 +1,93 @@
+package consensus
+
+import (
+	"fmt"
+	"testing"
+)
+
+// failingStore is a testStore whose commits fail while fail is set
+type failingStore struct {
+	*testStore
+	fail bool
+}
+
+func (s *failingStore) CommitBlock(block *Block, receipts []*Receipt, accounts []*Account, supply *SupplyInfo) error {
+	if s.fail {
+		return fmt.Errorf("disk full")
+	}
+	return s.testStore.CommitBlock(block, receipts, accounts, supply)
+}
+
+func TestFailedCommitHoldsHead(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice")
+	store := &failingStore{testStore: newTestStore(), fail: true}
+	d.SetStore(store)
+
+	d.mu.Lock()
+	block := &Block{Number: 1, PreviousHash: d.getPreviousBlockHash(), Timestamp: 1, Validator: testAddress("validator")}
+	block.Hash = d.calculateBlockHash(block)
+	err := d.finalizeBlock(block)
+	d.mu.Unlock()
+	if err == nil {
+		t.Fatal("failed commit not reported")
+	}
+	if head := d.GetCurrentBlock(); head != 0 {
+		t.Fatalf("head advanced to #%d by a failed commit", head)
+	}
+
+	// The held block is committed before anything is built on it
+	store.fail = false
+	d.produceBlock()
+	if head := d.GetCurrentBlock(); head != 1 {
+		t.Fatalf("head #%d after the retry, want #1", head)
+	}
+	if _, err := store.GetBlock(1); err != nil {
+		t.Fatalf("held block not committed: %v", err)
+	}
+	if d.unpersisted != nil {
+		t.Fatal("block still held after it was committed")
+	}
+}
+
+// checkpointStore is a testStore that keeps a checkpoint every interval blocks
+type checkpointStore struct {
+	*testStore
+	interval    uint64
+	checkpoints []*ReplayCheckpoint
+}
+
+func (s *checkpointStore) CheckpointInterval() uint64 { return s.interval }
+
+func (s *checkpointStore) SaveCheckpoint(checkpoint *ReplayCheckpoint) error {
+	s.checkpoints = append(s.checkpoints, checkpoint)
+	return nil
+}
+
+func TestFinalizedBlocksCheckpointed(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice")
+	store := &checkpointStore{testStore: newTestStore(), interval: 2}
+	d.SetStore(store)
+
+	for nonce := uint64(0); nonce < 4; nonce++ {
+		runBlock(t, d, transfer("alice", "bob", 1, nonce))
+		d.mu.Lock()
+		block := &Block{Number: d.currentBlock, Timestamp: d.lastBlockTime, StateRoot: d.stateRoot()}
+		block.Hash = d.calculateBlockHash(block)
+		err := d.finalizeBlock(block)
+		d.mu.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(store.checkpoints) != 2 || store.checkpoints[0].Height != 2 || store.checkpoints[1].Height != 4 {
+		t.Fatalf("got %d checkpoints, want those of blocks #2 and #4", len(store.checkpoints))
+	}
+	restored := NewDPoSBFT(Config{})
+	if err := restored.adoptCheckpoint(store.checkpoints[1]); err != nil {
+		t.Fatal(err)
+	}
+	if balance := restored.stateDB.GetBalance(testAddress("bob")); balance.Int64() != 4 {
+		t.Fatalf("checkpoint restores bob's balance as %s, want 4", balance)
+	}
+}
@@ -0,0 +1,184 @@
+package consensus
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// supplyHistoryBlocks is the number of recent supply records kept in
+// memory. Older heights are read from the store.
+const supplyHistoryBlocks = 1024
+
+// MaxSupply is the hard cap from VNCToken.sol: 1 billion VNC with 18 decimals
+var MaxSupply = new(big.Int).Mul(big.NewInt(1_000_000_000), big.NewInt(1e18))
+
+// SupplyInfo is the supply breakdown at a block height
+type SupplyInfo struct {
+	Height      uint64   `json:"height"`
+	Total       *big.Int `json:"total"`
+	Circulating *big.Int `json:"circulating"`
+	Staked      *big.Int `json:"staked"`
+	Burned      *big.Int `json:"burned"`
+	Locked      *big.Int `json:"locked"`
+	MaxSupply   *big.Int `json:"max_supply"`
+}
+
+// Mint creates new supply and credits it to an address.
+// Fails if the mint would push total supply past MaxSupply.
+func (s *StateDB) Mint(address string, amount *big.Int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mint(address, amount)
+}
+
+// mint credits new supply; caller must hold s.mu
+func (s *StateDB) mint(address string, amount *big.Int) error {
+	if amount == nil || amount.Sign() <= 0 {
+		return fmt.Errorf("invalid mint amount")
+	}
+
+	newSupply := new(big.Int).Add(s.totalSupply, amount)
+	if newSupply.Cmp(MaxSupply) > 0 {
+		return fmt.Errorf("max supply exceeded: %s + %s > %s",
+			s.totalSupply.String(), amount.String(), MaxSupply.String())
+	}
+
+	if _, exists := s.balances[address]; !exists {
+		s.balances[address] = big.NewInt(0)
+	}
+	s.balances[address].Add(s.balances[address], amount)
+	s.touch(address)
+	s.totalSupply = newSupply
+	return nil
+}
+
+// Burn destroys supply held by an address
+func (s *StateDB) Burn(address string, amount *big.Int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if amount == nil || amount.Sign() <= 0 {
+		return fmt.Errorf("invalid burn amount")
+	}
+
+	balance, exists := s.balances[address]
+	if !exists || balance.Cmp(amount) < 0 {
+		return fmt.Errorf("insufficient balance to burn")
+	}
+
+	balance.Sub(balance, amount)
+	s.touch(address)
+	s.totalSupply.Sub(s.totalSupply, amount)
+	s.burned.Add(s.burned, amount)
+	return nil
+}
+
+// GetTotalSupply returns the current total supply
+func (s *StateDB) GetTotalSupply() *big.Int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return new(big.Int).Set(s.totalSupply)
+}
+
+// GetBurnedSupply returns the total amount burned
+func (s *StateDB) GetBurnedSupply() *big.Int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return new(big.Int).Set(s.burned)
+}
+
+// GetLockedSupply returns the supply locked by vesting, time-locks and escrows at the given time
+func (s *StateDB) GetLockedSupply(timestamp int64) *big.Int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	locked := big.NewInt(0)
+	for _, account := range s.vesting {
+		locked.Add(locked, account.Schedule.LockedAmount(timestamp))
+	}
+	locked.Add(locked, s.timeLockedSupply())
+	return locked.Add(locked, s.escrowedSupply())
+}
+
+// applySupplyTransaction executes a privileged mint or burn
+func (d *DPoSBFT) applySupplyTransaction(tx *Transaction) error {
+	switch tx.Type {
+	case TxMint:
+		if err := d.stateDB.Mint(tx.To, tx.Value); err != nil {
+			return err
+		}
+	case TxBurn:
+		if err := d.stateDB.Burn(tx.From, tx.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// computeSupply builds the supply breakdown from current state; caller must hold d.mu.
+// Validator stake is bonded outside account balances and so is not part of
+// the total; it is reported but not subtracted from circulating supply.
+func (d *DPoSBFT) computeSupply(height uint64, timestamp int64) *SupplyInfo {
+	staked := big.NewInt(0)
+	for _, v := range d.validators {
+		staked.Add(staked, v.Stake)
+		staked.Add(staked, v.DelegatedStake)
+	}
+
+	total := d.stateDB.GetTotalSupply()
+	locked := d.stateDB.GetLockedSupply(timestamp)
+
+	circulating := new(big.Int).Sub(total, locked)
+	if circulating.Sign() < 0 {
+		circulating.SetInt64(0)
+	}
+
+	return &SupplyInfo{
+		Height:      height,
+		Total:       total,
+		Circulating: circulating,
+		Staked:      staked,
+		Burned:      d.stateDB.GetBurnedSupply(),
+		Locked:      locked,
+		MaxSupply:   new(big.Int).Set(MaxSupply),
+	}
+}
+
+// recordSupply records the supply breakdown at a height, forgetting the
+// in-memory record that falls out of the retained window. Caller must hold d.mu.
+func (d *DPoSBFT) recordSupply(height uint64, timestamp int64) *SupplyInfo {
+	info := d.computeSupply(height, timestamp)
+	d.supplyHistory[height] = info
+	if height >= supplyHistoryBlocks {
+		delete(d.supplyHistory, height-supplyHistoryBlocks)
+	}
+	return info
+}
+
+// GetSupply returns the supply breakdown at a block height
+func (d *DPoSBFT) GetSupply(height uint64) (*SupplyInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if height > d.currentBlock {
+		return nil, fmt.Errorf("height %d is beyond current block %d", height, d.currentBlock)
+	}
+
+	if info, exists := d.supplyHistory[height]; exists {
+		return info, nil
+	}
+	if d.store != nil {
+		info, err := d.store.GetSupply(height)
+		if err == nil {
+			return info, nil
+		}
+		return nil, fmt.Errorf("no supply record at height %d: %w", height, err)
+	}
+	return nil, fmt.Errorf("no supply record at height %d", height)
+}
+
+// GetLatestSupply returns the supply breakdown at the current block
+func (d *DPoSBFT) GetLatestSupply() (*SupplyInfo, error) {
+	return d.GetSupply(d.GetCurrentBlock())
+}
@@ -0,0 +1,68 @@
+package consensus
+
+import (
+	"testing"
+)
+
+func TestCirculatingSupplyIncludesStake(t *testing.T) {
+	d := newTestEngine(t, Config{MaxValidators: 1}, "alice")
+	if err := d.RegisterValidator(testAddress("validator"), vnc(400), 0.1); err != nil {
+		t.Fatal(err)
+	}
+
+	info := d.computeSupply(0, 0)
+	if info.Circulating.Cmp(vnc(1000)) != 0 {
+		t.Fatalf("circulating supply %s, want the whole total %s", info.Circulating, vnc(1000))
+	}
+	if info.Staked.Cmp(vnc(400)) != 0 {
+		t.Fatalf("staked %s, want %s", info.Staked, vnc(400))
+	}
+}
+
+func TestSupplyHistoryReadFromStore(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice")
+	store := newTestStore()
+	d.SetStore(store)
+
+	head := uint64(supplyHistoryBlocks + 10)
+	d.mu.Lock()
+	for height := uint64(1); height <= head; height++ {
+		block := &Block{Number: height, Timestamp: int64(height)}
+		d.recordSupply(height, block.Timestamp)
+		d.persistBlock(block)
+		d.currentBlock = height
+	}
+	kept := len(d.supplyHistory)
+	d.mu.Unlock()
+
+	if kept > supplyHistoryBlocks {
+		t.Fatalf("%d supply records kept in memory, want at most %d", kept, supplyHistoryBlocks)
+	}
+	info, err := d.GetSupply(1)
+	if err != nil {
+		t.Fatalf("evicted supply record not read from the store: %v", err)
+	}
+	if info.Height != 1 || info.Total.Cmp(vnc(1000)) != 0 {
+		t.Fatalf("supply at block #1 is %+v", info)
+	}
+	if _, err := d.GetSupply(head + 1); err == nil {
+		t.Fatal("supply beyond the head returned")
+	}
+}
+
+func TestSupplyInfoRoundTrip(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice")
+	info := d.computeSupply(7, 0)
+
+	data, err := info.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(SupplyInfo)
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Height != 7 || decoded.Total.Cmp(info.Total) != 0 || decoded.MaxSupply.Cmp(MaxSupply) != 0 {
+		t.Fatalf("decoded %+v, want %+v", decoded, info)
+	}
+}
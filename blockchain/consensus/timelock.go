@@ -0,0 +1,214 @@
+package consensus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// TimeLockEscrowAddress is the module account holding time-locked funds.
+// Locked value stays in the balances map, and so in the state root, while
+// it waits to be released.
+const TimeLockEscrowAddress = "0x0000000000000000000000000000000000001001"
+
+// TimeLock is value escrowed until a target height and/or timestamp
+type TimeLock struct {
+	ID            string   `json:"id"` // hash of the creating transaction
+	From          string   `json:"from"`
+	To            string   `json:"to"`
+	Amount        *big.Int `json:"amount"`
+	UnlockHeight  uint64   `json:"unlock_height,omitempty"`
+	UnlockTime    int64    `json:"unlock_time,omitempty"`
+	CreatedHeight uint64   `json:"created_height"`
+}
+
+// TimeLockPayload is the JSON body carried in Data by a time-lock transaction.
+// At least one of the targets must be set; when both are, both must be reached.
+type TimeLockPayload struct {
+	UnlockHeight uint64 `json:"unlock_height,omitempty"`
+	UnlockTime   int64  `json:"unlock_time,omitempty"`
+}
+
+// Matured reports whether the lock can be released at the given height and time
+func (l *TimeLock) Matured(height uint64, timestamp int64) bool {
+	return (l.UnlockHeight == 0 || height >= l.UnlockHeight) &&
+		(l.UnlockTime == 0 || timestamp >= l.UnlockTime)
+}
+
+// GetTimeLocks returns the pending time-locks sent from or to an address
+func (s *StateDB) GetTimeLocks(address string) []*TimeLock {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	locks := make([]*TimeLock, 0)
+	for _, lock := range s.timeLocks {
+		if lock.From == address || lock.To == address {
+			copied := *lock
+			copied.Amount = new(big.Int).Set(lock.Amount)
+			locks = append(locks, &copied)
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		if locks[i].CreatedHeight != locks[j].CreatedHeight {
+			return locks[i].CreatedHeight < locks[j].CreatedHeight
+		}
+		return locks[i].ID < locks[j].ID
+	})
+	return locks
+}
+
+// releaseTimeLocks pays out every matured lock, in ID order
+func (s *StateDB) releaseTimeLocks(height uint64, timestamp int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matured := make([]string, 0)
+	for id, lock := range s.timeLocks {
+		if lock.Matured(height, timestamp) {
+			matured = append(matured, id)
+		}
+	}
+	sort.Strings(matured)
+
+	for _, id := range matured {
+		lock := s.timeLocks[id]
+		s.payFromModule(TimeLockEscrowAddress, lock.To, lock.Amount)
+		delete(s.timeLocks, id)
+		fmt.Printf("🔓 Time-lock %s released %s to %s at block #%d\n", id[:10], lock.Amount.String(), lock.To, height)
+	}
+}
+
+// payFromModule pays amount out of a module account. Caller must hold s.mu.
+func (s *StateDB) payFromModule(module, to string, amount *big.Int) {
+	s.balances[module] = new(big.Int).Sub(s.balances[module], amount)
+	if s.balances[module].Sign() == 0 {
+		delete(s.balances, module)
+	}
+
+	balance, exists := s.balances[to]
+	if !exists {
+		balance = big.NewInt(0)
+	}
+	s.balances[to] = new(big.Int).Add(balance, amount)
+	s.touch(module)
+	s.touch(to)
+}
+
+// timeLockedSupply sums value held in pending time-locks. Caller must hold s.mu.
+func (s *StateDB) timeLockedSupply() *big.Int {
+	total := big.NewInt(0)
+	for _, lock := range s.timeLocks {
+		total.Add(total, lock.Amount)
+	}
+	return total
+}
+
+// timeLockDigest hashes the pending time-locks in ID order. Caller must hold s.mu.
+func (s *StateDB) timeLockDigest() string {
+	ids := make([]string, 0, len(s.timeLocks))
+	for id := range s.timeLocks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	h := sha256.New()
+	for _, id := range ids {
+		lock := s.timeLocks[id]
+		fmt.Fprintf(h, "%s:%s:%s:%s:%d:%d;", id, lock.From, lock.To, lock.Amount.String(),
+			lock.UnlockHeight, lock.UnlockTime)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// applyTimeLockTransaction creates or cancels a time-lock.
+// Creating escrows tx.Value for tx.To with the targets in tx.Data;
+// cancelling carries the lock ID in tx.Data and refunds the sender.
+func (d *DPoSBFT) applyTimeLockTransaction(tx *Transaction, block *Block) error {
+	switch tx.Type {
+	case TxTimeLock:
+		return d.createTimeLock(tx, block)
+	case TxTimeLockCancel:
+		return d.cancelTimeLock(tx, block)
+	}
+	return fmt.Errorf("unknown time-lock transaction type: %d", tx.Type)
+}
+
+// createTimeLock escrows value until the lock matures
+func (d *DPoSBFT) createTimeLock(tx *Transaction, block *Block) error {
+	var payload TimeLockPayload
+	if err := json.Unmarshal(tx.Data, &payload); err != nil {
+		return fmt.Errorf("invalid time-lock payload: %w", err)
+	}
+	if payload.UnlockHeight == 0 && payload.UnlockTime == 0 {
+		return fmt.Errorf("time-lock needs an unlock height or time")
+	}
+	if tx.To == "" || tx.To == TimeLockEscrowAddress {
+		return fmt.Errorf("invalid time-lock recipient %q", tx.To)
+	}
+	if tx.Value.Sign() <= 0 {
+		return fmt.Errorf("time-lock amount must be positive")
+	}
+
+	lock := &TimeLock{
+		ID:            tx.Hash,
+		From:          tx.From,
+		To:            tx.To,
+		Amount:        new(big.Int).Set(tx.Value),
+		UnlockHeight:  payload.UnlockHeight,
+		UnlockTime:    payload.UnlockTime,
+		CreatedHeight: block.Number,
+	}
+	if lock.Matured(block.Number, block.Timestamp) {
+		return fmt.Errorf("time-lock target already reached")
+	}
+
+	// Escrow with transfer semantics: frozen, vesting and nonce checks apply
+	escrow := *tx
+	escrow.To = TimeLockEscrowAddress
+	if err := d.applyTransfer(&escrow, block.Timestamp); err != nil {
+		return err
+	}
+
+	s := d.stateDB
+	s.mu.Lock()
+	s.timeLocks[lock.ID] = lock
+	s.mu.Unlock()
+
+	fmt.Printf("🔒 Time-lock %s: %s from %s to %s\n", lock.ID[:10], lock.Amount.String(), lock.From, lock.To)
+	return nil
+}
+
+// cancelTimeLock refunds an immature lock to its sender
+func (d *DPoSBFT) cancelTimeLock(tx *Transaction, block *Block) error {
+	id := string(tx.Data)
+
+	s := d.stateDB
+	s.mu.Lock()
+	lock, exists := s.timeLocks[id]
+	if !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("time-lock %s not found", id)
+	}
+	if lock.From != tx.From {
+		s.mu.Unlock()
+		return fmt.Errorf("only the sender can cancel time-lock %s", id)
+	}
+	if lock.Matured(block.Number, block.Timestamp) {
+		s.mu.Unlock()
+		return fmt.Errorf("time-lock %s has matured", id)
+	}
+	s.payFromModule(TimeLockEscrowAddress, lock.From, lock.Amount)
+	delete(s.timeLocks, id)
+	s.mu.Unlock()
+
+	fmt.Printf("↩️  Time-lock %s cancelled by %s\n", id[:10], tx.From)
+	return nil
+}
+
+// GetTimeLocks returns the pending time-locks sent from or to an address
+func (d *DPoSBFT) GetTimeLocks(address string) []*TimeLock {
+	return d.stateDB.GetTimeLocks(address)
+}
@@ -0,0 +1,83 @@
+package consensus
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// timeLock builds a signed time-lock of amount from one account to another
+func timeLock(from, to string, amount *big.Int, unlockHeight, nonce uint64) *Transaction {
+	return signedTx(testKey(from), &Transaction{
+		Type:  TxTimeLock,
+		To:    testAddress(to),
+		Value: amount,
+		Nonce: nonce,
+		Data:  []byte(fmt.Sprintf(`{"unlock_height":%d}`, unlockHeight)),
+	})
+}
+
+func TestTimeLockReleasedAtMaturity(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice")
+	requireStatus(t, runBlock(t, d, timeLock("alice", "bob", vnc(10), 3, 0))[0], ReceiptStatusSuccess)
+	if escrowed := d.stateDB.GetBalance(TimeLockEscrowAddress); escrowed.Cmp(vnc(10)) != 0 {
+		t.Fatalf("escrow holds %s, want 10 VNC", escrowed)
+	}
+
+	runBlock(t, d)
+	if balance := d.stateDB.GetBalance(testAddress("bob")); balance.Sign() != 0 {
+		t.Fatalf("bob received %s before block #3", balance)
+	}
+
+	// The lock pays out at the start of its unlock height
+	runBlock(t, d)
+	if balance := d.stateDB.GetBalance(testAddress("bob")); balance.Cmp(vnc(10)) != 0 {
+		t.Fatalf("bob holds %s at maturity, want 10 VNC", balance)
+	}
+	if escrowed := d.stateDB.GetBalance(TimeLockEscrowAddress); escrowed.Sign() != 0 {
+		t.Fatalf("escrow still holds %s after the release", escrowed)
+	}
+	if locks := d.GetTimeLocks(testAddress("alice")); len(locks) != 0 {
+		t.Fatalf("%d locks pending after the release", len(locks))
+	}
+}
+
+func TestTimeLockCancel(t *testing.T) {
+	d := newTestEngine(t, Config{}, "alice", "bob")
+	lock := timeLock("alice", "bob", vnc(10), 5, 0)
+	requireStatus(t, runBlock(t, d, lock)[0], ReceiptStatusSuccess)
+
+	// Only the sender can cancel
+	receipts := runBlock(t, d,
+		signedTx(testKey("bob"), &Transaction{Type: TxTimeLockCancel, Data: []byte(lock.Hash)}),
+	)
+	requireStatus(t, receipts[0], ReceiptStatusFailed)
+
+	before := new(big.Int).Set(d.stateDB.GetBalance(testAddress("alice")))
+	receipts = runBlock(t, d,
+		signedTx(testKey("alice"), &Transaction{Type: TxTimeLockCancel, Nonce: 1, Data: []byte(lock.Hash)}),
+	)
+	requireStatus(t, receipts[0], ReceiptStatusSuccess)
+	refund := new(big.Int).Sub(d.stateDB.GetBalance(testAddress("alice")), before)
+	refund.Add(refund, new(big.Int).SetUint64(receipts[0].GasUsed))
+	if refund.Cmp(vnc(10)) != 0 {
+		t.Fatalf("cancel refunded %s, want 10 VNC", refund)
+	}
+
+	// A cancelled lock is not released at its target
+	bob := new(big.Int).Set(d.stateDB.GetBalance(testAddress("bob")))
+	runBlock(t, d)
+	runBlock(t, d)
+	if balance := d.stateDB.GetBalance(testAddress("bob")); balance.Cmp(bob) != 0 {
+		t.Fatalf("bob's balance moved from %s to %s after the cancel", bob, balance)
+	}
+	if locks := d.GetTimeLocks(testAddress("bob")); len(locks) != 0 {
+		t.Fatalf("%d locks pending after the cancel", len(locks))
+	}
+
+	// Cancelling again finds nothing
+	receipts = runBlock(t, d,
+		signedTx(testKey("alice"), &Transaction{Type: TxTimeLockCancel, Nonce: 2, Data: []byte(lock.Hash)}),
+	)
+	requireStatus(t, receipts[0], ReceiptStatusFailed)
+}
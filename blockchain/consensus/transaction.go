@@ -0,0 +1,108 @@
+package consensus
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// CalculateTxHash computes the canonical hash of a transaction's contents
+func CalculateTxHash(tx *Transaction) string {
+	data := fmt.Sprintf("%d|%s|%s|%s|%d|%s|%d|%x",
+		tx.Type,
+		tx.From,
+		tx.To,
+		bigString(tx.Value),
+		tx.Nonce,
+		bigString(tx.GasPrice),
+		tx.GasLimit,
+		tx.Data,
+	)
+	// Meta-transactions commit to their fee payer; plain hashes are unchanged
+	if tx.FeePayer != "" {
+		data += "|" + tx.FeePayer
+	}
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+// SignTransaction sets the transaction hash and signs it with the sender's key
+func SignTransaction(tx *Transaction, key ed25519.PrivateKey) {
+	tx.Hash = CalculateTxHash(tx)
+	tx.Signature = signHash(key, tx.Hash)
+}
+
+// VerifyTransactionSignature checks the hash and sender signature of a transaction
+func VerifyTransactionSignature(tx *Transaction) bool {
+	if tx.Hash != CalculateTxHash(tx) {
+		return false
+	}
+	return verifyHashSignature(tx.From, tx.Hash, tx.Signature)
+}
+
+// SubmitTransaction runs admission checks and adds a transaction to the mempool
+func (d *DPoSBFT) SubmitTransaction(tx *Transaction) error {
+	if err := d.admitTransaction(tx); err != nil {
+		return err
+	}
+	return d.mempool.AddTransaction(tx)
+}
+
+// admitTransaction runs the checks a transaction must pass against current
+// state to enter the mempool
+func (d *DPoSBFT) admitTransaction(tx *Transaction) error {
+	if tx.Value == nil {
+		tx.Value = big.NewInt(0)
+	}
+
+	if !VerifyTransactionSignature(tx) {
+		return fmt.Errorf("invalid transaction signature")
+	}
+
+	if d.stateDB.IsPaused() && !tx.Type.IsPrivileged() {
+		return fmt.Errorf("chain is paused")
+	}
+
+	if err := checkAmounts(tx); err != nil {
+		return err
+	}
+	if err := checkGasLimit(tx); err != nil {
+		return err
+	}
+
+	if tx.IsSponsored() {
+		if err := d.verifySponsorship(tx); err != nil {
+			return err
+		}
+	} else if balance, maxFee := d.stateDB.GetBalance(tx.From), maxTxFee(tx); balance.Cmp(maxFee) < 0 {
+		return fmt.Errorf("insufficient balance for gas: have %s, need %s", balance.String(), maxFee.String())
+	}
+
+	if err := d.stateDB.checkFrozen(tx); err != nil {
+		return err
+	}
+
+	return checkNonce(tx, d.stateDB.GetNonce(tx.From))
+}
+
+// checkAmounts rejects negative values and gas prices, which would move
+// funds from the recipient or the proposer to the sender
+func checkAmounts(tx *Transaction) error {
+	if tx.Value != nil && tx.Value.Sign() < 0 {
+		return fmt.Errorf("negative value %s", tx.Value.String())
+	}
+	if tx.GasPrice != nil && tx.GasPrice.Sign() < 0 {
+		return fmt.Errorf("negative gas price %s", tx.GasPrice.String())
+	}
+	return nil
+}
+
+// bigString formats a possibly nil big.Int
+func bigString(v *big.Int) string {
+	if v == nil {
+		return "0"
+	}
+	return v.String()
+}
@@ -0,0 +1,152 @@
+package consensus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// keylessSignature is the signature scheme once used, derivable by anyone
+func keylessSignature(hash, signer string) string {
+	sum := sha256.Sum256([]byte(hash + signer))
+	return hex.EncodeToString(sum[:])
+}
+
+func adminConfig() Config {
+	return Config{
+		AdminRoles: map[string]AdminRole{
+			testAddress("admin"):  RoleSuperAdmin,
+			testAddress("admin2"): RoleSuperAdmin,
+			testAddress("ops"):    RoleAdminOps,
+		},
+		PauseThreshold: 2,
+	}
+}
+
+func TestSignatureRoundTrip(t *testing.T) {
+	tx := transfer("alice", "bob", 1, 0)
+	if !VerifyTransactionSignature(tx) {
+		t.Fatal("valid signature rejected")
+	}
+
+	tampered := *tx
+	tampered.Value = big.NewInt(2)
+	if VerifyTransactionSignature(&tampered) {
+		t.Fatal("signature accepted after the value changed")
+	}
+}
+
+func TestForgedAdminTransactionRejected(t *testing.T) {
+	d := newTestEngine(t, adminConfig(), "admin")
+	mint := &Transaction{
+		Type:     TxMint,
+		From:     testAddress("admin"),
+		To:       testAddress("mallory"),
+		Value:    vnc(1000000),
+		GasPrice: big.NewInt(1),
+	}
+
+	// Keyless signature computed from public data
+	forged := *mint
+	forged.Hash = CalculateTxHash(&forged)
+	forged.Signature = keylessSignature(forged.Hash, forged.From)
+	if err := d.SubmitTransaction(&forged); err == nil {
+		t.Fatal("keyless signature admitted")
+	}
+
+	// Real signature by a key that does not control the admin address
+	impersonated := *mint
+	SignTransaction(&impersonated, testKey("mallory"))
+	if err := d.SubmitTransaction(&impersonated); err == nil {
+		t.Fatal("signature by another key admitted")
+	}
+
+	receipts := runBlock(t, d, &forged, &impersonated)
+	for _, receipt := range receipts {
+		requireStatus(t, receipt, ReceiptStatusFailed)
+	}
+	if balance := d.stateDB.GetBalance(testAddress("mallory")); balance.Sign() != 0 {
+		t.Fatalf("forged mint credited %s", balance)
+	}
+
+	genuine := signedTx(testKey("admin"), &Transaction{Type: TxMint, To: testAddress("alice"), Value: vnc(5)})
+	requireStatus(t, runBlock(t, d, genuine)[0], ReceiptStatusSuccess)
+}
+
+func TestForgedApprovalNotCounted(t *testing.T) {
+	d := newTestEngine(t, adminConfig(), "admin")
+	pause := signedTx(testKey("admin"), &Transaction{Type: TxPause})
+	pause.Approvals = append(pause.Approvals, TxApproval{
+		Signer:    testAddress("admin2"),
+		Signature: keylessSignature(pause.Hash, testAddress("admin2")),
+	})
+
+	requireStatus(t, runBlock(t, d, pause)[0], ReceiptStatusFailed)
+	if d.stateDB.IsPaused() {
+		t.Fatal("chain paused with a forged approval")
+	}
+
+	pause = signedTx(testKey("admin"), &Transaction{Type: TxPause, Nonce: d.stateDB.GetNonce(testAddress("admin"))})
+	ApproveTransaction(pause, testKey("admin2"))
+	requireStatus(t, runBlock(t, d, pause)[0], ReceiptStatusSuccess)
+}
+
+func TestForgedFeePayerSignatureRejected(t *testing.T) {
+	d := newTestEngine(t, Config{}, "sponsor")
+	policy := signedTx(testKey("sponsor"), &Transaction{Type: TxSponsorPolicy, Data: []byte(`{}`)})
+	requireStatus(t, runBlock(t, d, policy)[0], ReceiptStatusSuccess)
+
+	tx := &Transaction{
+		Type:     TxTransfer,
+		To:       testAddress("bob"),
+		Value:    big.NewInt(0),
+		GasPrice: big.NewInt(1),
+		FeePayer: testAddress("sponsor"),
+	}
+	signedTx(testKey("alice"), tx)
+	tx.FeePayerSignature = keylessSignature(tx.Hash, tx.FeePayer)
+	if err := d.SubmitTransaction(tx); err == nil {
+		t.Fatal("keyless fee payer signature admitted")
+	}
+
+	SignAsFeePayer(tx, testKey("sponsor"))
+	if err := d.SubmitTransaction(tx); err != nil {
+		t.Fatalf("sponsored transaction rejected: %v", err)
+	}
+}
+
+func TestNegativeAmountsRejected(t *testing.T) {
+	negative := func(nonce uint64) []*Transaction {
+		return []*Transaction{
+			signedTx(testKey("alice"), &Transaction{Type: TxTransfer, To: testAddress("bob"), Value: vnc(-500), Nonce: nonce}),
+			signedTx(testKey("alice"), &Transaction{Type: TxTransfer, To: testAddress("bob"), Value: big.NewInt(1), GasPrice: big.NewInt(-1e12), Nonce: nonce + 1}),
+		}
+	}
+
+	d := newTestEngine(t, Config{}, "alice", "bob")
+	for _, tx := range negative(0) {
+		if err := d.SubmitTransaction(tx); err == nil || !strings.Contains(err.Error(), "negative") {
+			t.Fatalf("negative amount admitted: %v", err)
+		}
+	}
+
+	// Blocks from elsewhere are checked too, on the serial and parallel paths
+	for _, workers := range []int{1, 4} {
+		d := newTestEngine(t, Config{ExecutionWorkers: workers}, "alice", "bob", "carol")
+		txs := negative(0)
+		for nonce := uint64(0); nonce < 8; nonce++ {
+			txs = append(txs, transfer("carol", "dave", 1, nonce))
+		}
+		receipts := runBlock(t, d, append(txs, negative(2)...)...)
+		for _, i := range []int{0, 1, 10, 11} {
+			requireStatus(t, receipts[i], ReceiptStatusFailed)
+		}
+		for _, name := range []string{"alice", "bob"} {
+			if balance := d.stateDB.GetBalance(testAddress(name)); balance.Cmp(vnc(1000)) != 0 {
+				t.Fatalf("%d workers: %s has %s, want 1000 VNC", workers, name, balance)
+			}
+		}
+	}
+}
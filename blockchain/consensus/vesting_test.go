@@ -0,0 +1,57 @@
+package consensus
+
+import (
+	"math/big"
+	"testing"
+)
+
+func vestingGrant(from, to string, amount *big.Int, nonce uint64) *Transaction {
+	return signedTx(testKey(from), &Transaction{
+		Type:  TxVestingGrant,
+		To:    testAddress(to),
+		Value: amount,
+		Nonce: nonce,
+		Data:  []byte(`{"start_time":1,"cliff":100,"duration":1000,"tge_percent":10}`),
+	})
+}
+
+func TestVestingGrant(t *testing.T) {
+	d := newTestEngine(t, adminConfig(), "admin", "mallory")
+	before := d.stateDB.GetRoot()
+
+	receipts := runBlock(t, d,
+		vestingGrant("mallory", "mallory", vnc(100), 0),
+		vestingGrant("admin", "alice", vnc(100), 0),
+	)
+	requireStatus(t, receipts[0], ReceiptStatusFailed)
+	requireStatus(t, receipts[1], ReceiptStatusSuccess)
+
+	info, err := d.stateDB.GetVestingInfo(testAddress("alice"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Total.Cmp(vnc(100)) != 0 || info.Vested.Cmp(vnc(10)) != 0 {
+		t.Fatalf("total %s vested %s, want 100 VNC with 10 VNC at TGE", info.Total, info.Vested)
+	}
+	if d.stateDB.GetRoot() == before {
+		t.Fatal("vesting grant left the state root unchanged")
+	}
+
+	// Only the TGE release is spendable before the cliff
+	d.stateDB.Mint(testAddress("alice"), vnc(1))
+	receipts = runBlock(t, d, signedTx(testKey("alice"), &Transaction{Type: TxTransfer, To: testAddress("bob"), Value: vnc(20)}))
+	requireStatus(t, receipts[0], ReceiptStatusFailed)
+}
+
+func TestVestingReleaseInStateRoot(t *testing.T) {
+	d := newTestEngine(t, Config{})
+	schedule := &VestingSchedule{TotalAmount: vnc(100), Duration: 10, TGEPercent: 50}
+	if err := d.stateDB.SetVestingSchedule(testAddress("alice"), schedule); err != nil {
+		t.Fatal(err)
+	}
+	before := d.stateDB.GetRoot()
+	d.stateDB.vesting[testAddress("alice")].Released = vnc(1)
+	if d.stateDB.GetRoot() == before {
+		t.Fatal("released vesting amount not covered by the state root")
+	}
+}
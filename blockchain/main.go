@@ -1,14 +1,135 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
 	"vnc-blockchain/consensus"
 	"vnc-blockchain/networking"
 	"vnc-blockchain/quantum"
+	"vnc-blockchain/rpc"
+	"vnc-blockchain/storage"
 )
 
+// nodeConfig is the chain's genesis consensus configuration. Offline tools
+// that replay the chain must use the same configuration as the node.
+// VNC_ADMIN_ROLES lists on-chain admins as comma-separated address=role
+// pairs, and VNC_PAUSE_THRESHOLD sets the admin signatures needed to pause.
+// VNC_GENESIS_VALIDATORS lists the comma-separated addresses of the
+// validators active from genesis.
+func nodeConfig() consensus.Config {
+	cfg := consensus.Config{
+		ChainID:           20250,
+		BlockTime:         2, // 2 seconds (quantum-accelerated)
+		MaxValidators:     101,
+		FinalityBlocks:    2,
+		MinValidatorStake: 100000,
+		QuantumSecured:    true, // Enable quantum protection
+	}
+	if roles := os.Getenv("VNC_ADMIN_ROLES"); roles != "" {
+		cfg.AdminRoles = make(map[string]consensus.AdminRole)
+		for _, entry := range strings.Split(roles, ",") {
+			address, role, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok {
+				log.Fatal("❌ Invalid VNC_ADMIN_ROLES entry: ", entry)
+			}
+			cfg.AdminRoles[address] = consensus.AdminRole(role)
+		}
+	}
+	if validators := os.Getenv("VNC_GENESIS_VALIDATORS"); validators != "" {
+		for _, address := range strings.Split(validators, ",") {
+			cfg.GenesisValidators = append(cfg.GenesisValidators, strings.TrimSpace(address))
+		}
+	}
+	if threshold := os.Getenv("VNC_PAUSE_THRESHOLD"); threshold != "" {
+		n, err := strconv.Atoi(threshold)
+		if err != nil {
+			log.Fatal("❌ Invalid VNC_PAUSE_THRESHOLD: ", threshold)
+		}
+		cfg.PauseThreshold = n
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("❌ Invalid consensus configuration: ", err)
+	}
+	return cfg
+}
+
+// storageConfig selects the database backend, pruning mode and cache size.
+// VNC_DB_BACKEND=memory runs the node without persisting anything;
+// VNC_PRUNE_MODE (archive, full, pruned) and VNC_RETAIN_BLOCKS bound the
+// history kept on disk; VNC_CACHE_MB sizes the record cache.
+func storageConfig() storage.Config {
+	cfg := storage.Config{
+		Backend:   os.Getenv("VNC_DB_BACKEND"),
+		DataDir:   "./data/chaindata",
+		PruneMode: storage.PruneMode(os.Getenv("VNC_PRUNE_MODE")),
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = storage.BackendLevelDB
+	}
+	if cfg.PruneMode == "" {
+		cfg.PruneMode = storage.PruneArchive
+	}
+	if retain := os.Getenv("VNC_RETAIN_BLOCKS"); retain != "" {
+		blocks, err := strconv.ParseUint(retain, 10, 64)
+		if err != nil || blocks == 0 {
+			log.Fatal("❌ Invalid VNC_RETAIN_BLOCKS: ", retain)
+		}
+		cfg.RetainBlocks = blocks
+	}
+	if cacheMB := os.Getenv("VNC_CACHE_MB"); cacheMB != "" {
+		mb, err := strconv.Atoi(cacheMB)
+		if err != nil || mb <= 0 {
+			log.Fatal("❌ Invalid VNC_CACHE_MB: ", cacheMB)
+		}
+		cfg.CacheSize = mb << 20
+	}
+	return cfg
+}
+
+// validatorKey loads the key this node proposes blocks with from
+// VNC_VALIDATOR_KEY, the hex of an ed25519 seed. Without it the node only
+// follows the chain.
+func validatorKey() ed25519.PrivateKey {
+	seed := os.Getenv("VNC_VALIDATOR_KEY")
+	if seed == "" {
+		return nil
+	}
+	raw, err := hex.DecodeString(seed)
+	if err != nil || len(raw) != ed25519.SeedSize {
+		log.Fatal("❌ Invalid VNC_VALIDATOR_KEY: expected the hex of a 32-byte ed25519 seed")
+	}
+	return ed25519.NewKeyFromSeed(raw)
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify":
+			runVerify(os.Args[2:])
+			return
+		case "rebuild-indexes":
+			runRebuildIndexes(os.Args[2:])
+			return
+		case "snapshot":
+			runSnapshot(os.Args[2:])
+			return
+		case "db":
+			runDB(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		}
+	}
+
 	fmt.Println("🚀 Starting VNC Quantum-Secured Blockchain Node...")
 	fmt.Println("🔬 Initializing Quantum Security Systems...")
 
@@ -26,16 +147,71 @@ func main() {
 	fmt.Println("   - Communication Speed:", quantumEngine.GetQuantumSpeed())
 
 	// Initialize consensus engine with quantum security
-	config := consensus.Config{
-		ChainID:          20250,
-		BlockTime:        2, // 2 seconds (quantum-accelerated)
-		MaxValidators:    101,
-		FinalityBlocks:   2,
-		MinValidatorStake: 100000,
-		QuantumSecured:   true, // Enable quantum protection
-	}
+	config := nodeConfig()
 
 	engine := consensus.NewDPoSBFT(config)
+	if key := validatorKey(); key != nil {
+		engine.SetValidatorKey(key)
+		fmt.Println("🔑 Proposing blocks as validator", consensus.AddressOf(key))
+	}
+
+	// Persist finalized blocks, transactions and receipts
+	dbConfig := storageConfig()
+	db, err := storage.OpenBlockchainDB(dbConfig)
+	if err != nil {
+		log.Fatal("❌ Failed to open database:", err)
+	}
+	defer db.Close()
+	fmt.Println("🗄️  Pruning mode:", dbConfig.PruneMode)
+
+	// Roll back writes left half-done by a crash
+	report, err := db.CheckConsistency(true)
+	if err != nil {
+		log.Fatal("❌ Database consistency check failed:", err)
+	}
+	if report.Repaired {
+		fmt.Printf("🔧 Repaired database: head #%d -> #%d, %d orphaned blocks removed\n",
+			report.Head, report.RepairedHead, len(report.OrphanBlocks))
+		for _, problem := range report.Problems {
+			fmt.Println("   -", problem)
+		}
+	}
+
+	// Databases written before indexes were maintained are indexed once
+	if indexed, err := db.HasIndexes(); err != nil {
+		log.Fatal("❌ Failed to check indexes:", err)
+	} else if !indexed {
+		if _, err := rebuildIndexes(db); err != nil {
+			log.Fatal("❌ Failed to rebuild indexes:", err)
+		}
+	}
+
+	// Rebuild the state at the stored head by replaying the stored chain,
+	// from its snapshot base if it has one: the snapshot it was imported
+	// from, or the checkpoint pruning last kept
+	base, err := db.GetSnapshotBase()
+	if err != nil {
+		log.Fatal("❌ Failed to read snapshot base:", err)
+	}
+	replay, err := engine.VerifyChain(db, consensus.VerifyOptions{Resume: base})
+	if err != nil {
+		log.Fatal("❌ Failed to replay stored chain:", err)
+	}
+	if replay.Divergence != nil {
+		log.Fatal("❌ Stored chain diverges from replay at ", replay.Divergence)
+	}
+	if base != nil {
+		fmt.Printf("📸 Resumed from the snapshot base at block #%d, replayed %d blocks\n", base.Height, replay.Verified)
+	} else if replay.Verified > 0 {
+		fmt.Printf("🔁 Replayed %d stored blocks to head #%d\n", replay.Verified, replay.To)
+	}
+
+	engine.SetStore(db)
+
+	// Reload transactions that were pending when the node last stopped
+	if err := engine.LoadMempool(db); err != nil {
+		log.Println("⚠️  Failed to reload mempool:", err)
+	}
 	
 	// Initialize P2P networking with quantum entanglement
 	bootstrapPeers := []string{
@@ -68,6 +244,16 @@ func main() {
 	// Start consensus with quantum security
 	go engine.Start()
 
+	// Serve node state to the API gateway
+	rpcServer := rpc.NewServer(engine, 8545)
+	rpcServer.SetChainIndex(db)
+	rpcServer.SetStorageMetrics(db)
+	go func() {
+		if err := rpcServer.Start(); err != nil {
+			log.Println("RPC server stopped:", err)
+		}
+	}()
+
 	// Keep P2P network running with quantum channels
 	fmt.Println("📡 P2P Network: RUNNING (Max 50 peers)")
 	
@@ -0,0 +1,733 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"vnc-blockchain/consensus"
+	"vnc-blockchain/storage"
+)
+
+// Server exposes node state over HTTP for the API gateway
+type Server struct {
+	engine  *consensus.DPoSBFT
+	chain   ChainIndex
+	storage StorageMetrics
+	mux     *http.ServeMux
+	port    int
+}
+
+// ChainIndex answers lookups over finalized blocks. It is satisfied by
+// *storage.BlockchainDB.
+type ChainIndex interface {
+	GetBlockNumberByHash(hash string) (uint64, error)
+	GetTxLocation(hash string) (*storage.TxLocation, error)
+	GetAddressTransactions(address, cursor string, limit int, newestFirst bool) (*storage.AddressTxPage, error)
+}
+
+// StorageMetrics reports the storage cache counters. It is satisfied by
+// *storage.BlockchainDB.
+type StorageMetrics interface {
+	CacheStats() map[string]storage.CacheStats
+}
+
+// APIResponse matches the response envelope used by the API gateway
+type APIResponse struct {
+	Success bool        `json:"success"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// TransactionRequest is the JSON form of a signed transaction.
+// Amounts are decimal strings and data is hex encoded.
+type TransactionRequest struct {
+	Type      uint8             `json:"type"`
+	Hash      string            `json:"hash"`
+	From      string            `json:"from"`
+	To        string            `json:"to"`
+	Value     string            `json:"value"`
+	Nonce     uint64            `json:"nonce"`
+	GasPrice  string            `json:"gas_price"`
+	GasLimit  uint64            `json:"gas_limit"`
+	Data      string            `json:"data"`
+	Signature string            `json:"signature"`
+	Approvals []ApprovalRequest `json:"approvals,omitempty"`
+
+	// Meta-transactions: the fee payer co-signs and is charged the fee
+	FeePayer          string `json:"fee_payer,omitempty"`
+	FeePayerSignature string `json:"fee_payer_signature,omitempty"`
+}
+
+// ApprovalRequest is a co-signature on a multi-sig transaction
+type ApprovalRequest struct {
+	Signer    string `json:"signer"`
+	Signature string `json:"signature"`
+}
+
+// NewServer creates a node RPC server
+func NewServer(engine *consensus.DPoSBFT, port int) *Server {
+	s := &Server{
+		engine: engine,
+		mux:    http.NewServeMux(),
+		port:   port,
+	}
+	s.setupRoutes()
+	return s
+}
+
+// SetChainIndex attaches the indexes used for history and hash lookups
+func (s *Server) SetChainIndex(chain ChainIndex) {
+	s.chain = chain
+}
+
+// SetStorageMetrics attaches the source of the storage cache metrics
+func (s *Server) SetStorageMetrics(metrics StorageMetrics) {
+	s.storage = metrics
+}
+
+func (s *Server) setupRoutes() {
+	s.mux.HandleFunc("/api/v1/blockchain/supply", s.getSupply)
+	s.mux.HandleFunc("/api/v1/admin/frozen-accounts", s.getFrozenAccounts)
+	s.mux.HandleFunc("/api/v1/admin/pause-status", s.getPauseStatus)
+	s.mux.HandleFunc("/api/v1/transaction/send", s.sendTransaction)
+	s.mux.HandleFunc("/api/v1/transaction/receipt", s.getReceipt)
+	s.mux.HandleFunc("/api/v1/logs", s.getLogs)
+	s.mux.HandleFunc("/api/v1/transaction/cancel", s.getCancelTransaction)
+	s.mux.HandleFunc("/api/v1/mempool/events", s.getMempoolEvents)
+	s.mux.HandleFunc("/api/v1/timelocks", s.getTimeLocks)
+	s.mux.HandleFunc("/api/v1/assets", s.getAssets)
+	s.mux.HandleFunc("/api/v1/assets/balances", s.getAssetBalances)
+	s.mux.HandleFunc("/api/v1/assets/allowance", s.getAllowance)
+	s.mux.HandleFunc("/api/v1/sponsor/policy", s.getSponsorPolicy)
+	s.mux.HandleFunc("/api/v1/escrows", s.getEscrows)
+	s.mux.HandleFunc("/api/v1/vesting", s.getVesting)
+	s.mux.HandleFunc("/api/v1/metrics/blocks", s.getBlockMetrics)
+	s.mux.HandleFunc("/api/v1/metrics/cache", s.getCacheMetrics)
+	s.mux.HandleFunc("/api/v1/account/transactions", s.getAccountTransactions)
+	s.mux.HandleFunc("/api/v1/search", s.search)
+}
+
+// Start serves RPC requests until the listener fails
+func (s *Server) Start() error {
+	fmt.Printf("🔌 Node RPC listening on port %d\n", s.port)
+	return http.ListenAndServe(fmt.Sprintf(":%d", s.port), s.mux)
+}
+
+// Get supply breakdown, optionally at ?height=N
+func (s *Server) getSupply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var (
+		info *consensus.SupplyInfo
+		err  error
+	)
+	if h := r.URL.Query().Get("height"); h != "" {
+		height, parseErr := strconv.ParseUint(h, 10, 64)
+		if parseErr != nil {
+			s.sendError(w, http.StatusBadRequest, "invalid height")
+			return
+		}
+		info, err = s.engine.GetSupply(height)
+	} else {
+		info, err = s.engine.GetLatestSupply()
+	}
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// Amounts are returned as decimal strings to keep full precision
+	s.sendSuccess(w, map[string]interface{}{
+		"height":      info.Height,
+		"total":       info.Total.String(),
+		"circulating": info.Circulating.String(),
+		"staked":      info.Staked.String(),
+		"burned":      info.Burned.String(),
+		"locked":      info.Locked.String(),
+		"max_supply":  info.MaxSupply.String(),
+	})
+}
+
+// Get accounts frozen on-chain with the freeze audit log
+func (s *Server) getFrozenAccounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	s.sendSuccess(w, map[string]interface{}{
+		"frozen":    s.engine.GetFrozenAccounts(),
+		"audit_log": s.engine.GetFreezeLog(),
+	})
+}
+
+// Get chain-wide pause state
+func (s *Server) getPauseStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	s.sendSuccess(w, s.engine.GetPauseState())
+}
+
+// Submit a signed transaction to the mempool
+func (s *Server) sendTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req TransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "invalid transaction data")
+		return
+	}
+
+	tx, err := req.toTransaction()
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.engine.SubmitTransaction(tx); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.sendSuccess(w, map[string]interface{}{
+		"tx_hash": tx.Hash,
+		"status":  "pending",
+	})
+}
+
+// Get the receipt of an executed transaction by ?hash=
+func (s *Server) getReceipt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	hash := r.URL.Query().Get("hash")
+	if hash == "" {
+		s.sendError(w, http.StatusBadRequest, "hash is required")
+		return
+	}
+
+	receipt, err := s.engine.GetReceipt(hash)
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	s.sendSuccess(w, receipt)
+}
+
+// Filter logs by ?from_block, ?to_block, repeated ?address and ?topic0..?topic3.
+// Each topic parameter is a comma-separated list of alternatives.
+func (s *Server) getLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := consensus.LogFilter{Addresses: query["address"]}
+
+	var err error
+	if v := query.Get("from_block"); v != "" {
+		if filter.FromBlock, err = strconv.ParseUint(v, 10, 64); err != nil {
+			s.sendError(w, http.StatusBadRequest, "invalid from_block")
+			return
+		}
+	}
+	if v := query.Get("to_block"); v != "" {
+		if filter.ToBlock, err = strconv.ParseUint(v, 10, 64); err != nil {
+			s.sendError(w, http.StatusBadRequest, "invalid to_block")
+			return
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		v := query.Get(fmt.Sprintf("topic%d", i))
+		var alternatives []string
+		if v != "" {
+			alternatives = strings.Split(v, ",")
+		}
+		filter.Topics = append(filter.Topics, alternatives)
+	}
+	// Trailing wildcards would otherwise require logs to carry that many topics
+	for len(filter.Topics) > 0 && len(filter.Topics[len(filter.Topics)-1]) == 0 {
+		filter.Topics = filter.Topics[:len(filter.Topics)-1]
+	}
+
+	logs, err := s.engine.FilterLogs(filter)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.sendSuccess(w, logs)
+}
+
+// Build the unsigned zero-value self-transfer that cancels the pending
+// transaction at ?from=&nonce=. The sender signs it and submits it via send.
+func (s *Server) getCancelTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	nonce, err := strconv.ParseUint(r.URL.Query().Get("nonce"), 10, 64)
+	if from == "" || err != nil {
+		s.sendError(w, http.StatusBadRequest, "from and nonce are required")
+		return
+	}
+
+	tx, err := s.engine.NewCancelTransaction(from, nonce)
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	s.sendSuccess(w, TransactionRequest{
+		Type:     uint8(tx.Type),
+		Hash:     consensus.CalculateTxHash(tx),
+		From:     tx.From,
+		To:       tx.To,
+		Value:    tx.Value.String(),
+		Nonce:    tx.Nonce,
+		GasPrice: tx.GasPrice.String(),
+		GasLimit: tx.GasLimit,
+	})
+}
+
+// Get recent replaced and dropped mempool transactions
+func (s *Server) getMempoolEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	s.sendSuccess(w, s.engine.GetMempoolEvents())
+}
+
+// Get block pipeline latency histograms
+func (s *Server) getBlockMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	s.sendSuccess(w, s.engine.GetBlockMetrics())
+}
+
+// Get storage cache hit and miss counters
+func (s *Server) getCacheMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.storage == nil {
+		s.sendError(w, http.StatusServiceUnavailable, "storage metrics unavailable")
+		return
+	}
+
+	s.sendSuccess(w, s.storage.CacheStats())
+}
+
+// Get pending time-locks sent from or to ?address=
+func (s *Server) getTimeLocks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		s.sendError(w, http.StatusBadRequest, "address is required")
+		return
+	}
+
+	s.sendSuccess(w, s.engine.GetTimeLocks(address))
+}
+
+// Get all native assets, or one with ?symbol=
+func (s *Server) getAssets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if symbol := r.URL.Query().Get("symbol"); symbol != "" {
+		asset, err := s.engine.GetAsset(symbol)
+		if err != nil {
+			s.sendError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		s.sendSuccess(w, assetJSON(asset))
+		return
+	}
+
+	assets := make([]map[string]interface{}, 0)
+	for _, asset := range s.engine.GetAssets() {
+		assets = append(assets, assetJSON(asset))
+	}
+	s.sendSuccess(w, assets)
+}
+
+// Get the per-asset balances of ?address=
+func (s *Server) getAssetBalances(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		s.sendError(w, http.StatusBadRequest, "address is required")
+		return
+	}
+
+	balances := make([]map[string]interface{}, 0)
+	for _, balance := range s.engine.GetAssetBalances(address) {
+		balances = append(balances, map[string]interface{}{
+			"symbol":   balance.Symbol,
+			"decimals": balance.Decimals,
+			"balance":  balance.Balance.String(),
+		})
+	}
+	s.sendSuccess(w, map[string]interface{}{
+		"address":  address,
+		"balances": balances,
+	})
+}
+
+// Get the allowance at ?symbol=&owner=&spender=
+func (s *Server) getAllowance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	symbol, owner, spender := query.Get("symbol"), query.Get("owner"), query.Get("spender")
+	if symbol == "" || owner == "" || spender == "" {
+		s.sendError(w, http.StatusBadRequest, "symbol, owner and spender are required")
+		return
+	}
+
+	s.sendSuccess(w, map[string]interface{}{
+		"symbol":    symbol,
+		"owner":     owner,
+		"spender":   spender,
+		"allowance": s.engine.GetAllowance(symbol, owner, spender).String(),
+	})
+}
+
+// Get the fee sponsorship policy of ?sponsor=
+func (s *Server) getSponsorPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	sponsor := r.URL.Query().Get("sponsor")
+	if sponsor == "" {
+		s.sendError(w, http.StatusBadRequest, "sponsor is required")
+		return
+	}
+
+	policy, err := s.engine.GetSponsorPolicy(sponsor)
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	maxFee := ""
+	if policy.MaxFee != nil {
+		maxFee = policy.MaxFee.String()
+	}
+	s.sendSuccess(w, map[string]interface{}{
+		"sponsor":    policy.Sponsor,
+		"senders":    policy.Senders,
+		"actions":    policy.Actions,
+		"max_fee":    maxFee,
+		"updated_at": policy.UpdatedAt,
+	})
+}
+
+// Get a page of finalized transactions of ?address=, oldest first, or
+// newest first with ?order=desc. ?cursor= continues after a previous page.
+func (s *Server) getAccountTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.chain == nil {
+		s.sendError(w, http.StatusServiceUnavailable, "chain index unavailable")
+		return
+	}
+
+	query := r.URL.Query()
+	address := query.Get("address")
+	if address == "" {
+		s.sendError(w, http.StatusBadRequest, "address is required")
+		return
+	}
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			s.sendError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+	newestFirst := false
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		newestFirst = true
+	default:
+		s.sendError(w, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+
+	page, err := s.chain.GetAddressTransactions(address, query.Get("cursor"), limit, newestFirst)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	transactions := make([]map[string]interface{}, 0, len(page.Transactions))
+	for _, entry := range page.Transactions {
+		transactions = append(transactions, addressTxJSON(entry))
+	}
+	s.sendSuccess(w, map[string]interface{}{
+		"address":      address,
+		"transactions": transactions,
+		"next_cursor":  page.NextCursor,
+	})
+}
+
+// Look up ?hash= as a block or transaction hash
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.chain == nil {
+		s.sendError(w, http.StatusServiceUnavailable, "chain index unavailable")
+		return
+	}
+
+	hash := r.URL.Query().Get("hash")
+	if hash == "" {
+		s.sendError(w, http.StatusBadRequest, "hash is required")
+		return
+	}
+
+	if number, err := s.chain.GetBlockNumberByHash(hash); err == nil {
+		s.sendSuccess(w, map[string]interface{}{
+			"type":         "block",
+			"hash":         hash,
+			"block_number": number,
+		})
+		return
+	}
+	if location, err := s.chain.GetTxLocation(hash); err == nil {
+		s.sendSuccess(w, map[string]interface{}{
+			"type":         "transaction",
+			"hash":         hash,
+			"block_number": location.BlockNumber,
+			"index":        location.Index,
+		})
+		return
+	}
+	s.sendError(w, http.StatusNotFound, "no block or transaction with that hash")
+}
+
+// Get one escrow by ?id= or the escrows ?address= is party to
+func (s *Server) getEscrows(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	if id := query.Get("id"); id != "" {
+		escrow, err := s.engine.GetEscrow(id)
+		if err != nil {
+			s.sendError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		s.sendSuccess(w, escrowJSON(escrow))
+		return
+	}
+
+	address := query.Get("address")
+	if address == "" {
+		s.sendError(w, http.StatusBadRequest, "id or address is required")
+		return
+	}
+
+	escrows := make([]map[string]interface{}, 0)
+	for _, escrow := range s.engine.GetEscrows(address) {
+		escrows = append(escrows, escrowJSON(escrow))
+	}
+	s.sendSuccess(w, escrows)
+}
+
+// getVesting returns the vesting schedule and status of an address
+func (s *Server) getVesting(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		s.sendError(w, http.StatusBadRequest, "address is required")
+		return
+	}
+
+	info, err := s.engine.GetVestingInfo(address)
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	s.sendSuccess(w, map[string]interface{}{
+		"address":         info.Address,
+		"total_amount":    info.Total.String(),
+		"vested_amount":   info.Vested.String(),
+		"locked_amount":   info.Locked.String(),
+		"released_amount": info.Released.String(),
+		"start_time":      info.StartTime,
+		"cliff_end":       info.CliffEnd,
+		"vesting_end":     info.VestingEnd,
+	})
+}
+
+// escrowJSON formats an escrow with its amount as a decimal string
+func escrowJSON(escrow *consensus.Escrow) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             escrow.ID,
+		"depositor":      escrow.Depositor,
+		"beneficiary":    escrow.Beneficiary,
+		"arbiter":        escrow.Arbiter,
+		"amount":         escrow.Amount.String(),
+		"expires_at":     escrow.ExpiresAt,
+		"status":         escrow.Status,
+		"created_height": escrow.CreatedHeight,
+		"settled_height": escrow.SettledHeight,
+		"settled_by":     escrow.SettledBy,
+	}
+}
+
+// addressTxJSON formats an indexed transaction with its value as a decimal string
+func addressTxJSON(entry *storage.AddressTx) map[string]interface{} {
+	tx := entry.Transaction
+	value := "0"
+	if tx.Value != nil {
+		value = tx.Value.String()
+	}
+	return map[string]interface{}{
+		"hash":         tx.Hash,
+		"type":         tx.Type,
+		"from":         tx.From,
+		"to":           tx.To,
+		"value":        value,
+		"nonce":        tx.Nonce,
+		"fee_payer":    tx.FeePayer,
+		"block_number": entry.BlockNumber,
+		"index":        entry.Index,
+	}
+}
+
+// assetJSON formats an asset with amounts as decimal strings
+func assetJSON(asset *consensus.Asset) map[string]interface{} {
+	return map[string]interface{}{
+		"symbol":         asset.Symbol,
+		"name":           asset.Name,
+		"decimals":       asset.Decimals,
+		"cap":            asset.Cap.String(),
+		"supply":         asset.Supply.String(),
+		"issuer":         asset.Issuer,
+		"created_height": asset.CreatedHeight,
+	}
+}
+
+// toTransaction converts the JSON request into a consensus transaction
+func (req *TransactionRequest) toTransaction() (*consensus.Transaction, error) {
+	value, err := parseAmount(req.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value: %w", err)
+	}
+	gasPrice, err := parseAmount(req.GasPrice)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gas price: %w", err)
+	}
+	data, err := hex.DecodeString(req.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data: %w", err)
+	}
+
+	tx := &consensus.Transaction{
+		Type:      consensus.TxType(req.Type),
+		Hash:      req.Hash,
+		From:      req.From,
+		To:        req.To,
+		Value:     value,
+		Nonce:     req.Nonce,
+		GasPrice:  gasPrice,
+		GasLimit:  req.GasLimit,
+		Data:      data,
+		Signature: req.Signature,
+
+		FeePayer:          req.FeePayer,
+		FeePayerSignature: req.FeePayerSignature,
+	}
+	for _, a := range req.Approvals {
+		tx.Approvals = append(tx.Approvals, consensus.TxApproval{
+			Signer:    a.Signer,
+			Signature: a.Signature,
+		})
+	}
+	return tx, nil
+}
+
+// parseAmount parses a decimal amount string; empty means zero
+func parseAmount(v string) (*big.Int, error) {
+	if v == "" {
+		return big.NewInt(0), nil
+	}
+	amount, ok := new(big.Int).SetString(v, 10)
+	if !ok || amount.Sign() < 0 {
+		return nil, fmt.Errorf("%q is not a valid amount", v)
+	}
+	return amount, nil
+}
+
+// Helper functions
+func (s *Server) sendJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func (s *Server) sendSuccess(w http.ResponseWriter, data interface{}) {
+	s.sendJSON(w, http.StatusOK, APIResponse{Success: true, Data: data})
+}
+
+func (s *Server) sendError(w http.ResponseWriter, status int, message string) {
+	s.sendJSON(w, status, APIResponse{Success: false, Error: message})
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"container/list"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	"vnc-blockchain/consensus"
+)
+
+// DefaultCacheSize is the default byte budget of the record cache
+const DefaultCacheSize = 64 << 20
+
+// headerCost is the approximate size of a cached header
+const headerCost = 512
+
+// CacheStats counts the lookups of one cache
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int    `json:"bytes"`
+	Capacity  int    `json:"capacity"`
+}
+
+// lru is a least-recently-used cache bounded by the total cost of its
+// entries, roughly their encoded size in bytes
+type lru[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	size     int
+	items    map[K]*list.Element
+	order    *list.List // front is most recently used
+	stats    CacheStats
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+	cost  int
+}
+
+func newLRU[K comparable, V any](capacity int) *lru[K, V] {
+	return &lru[K, V]{
+		capacity: capacity,
+		items:    make(map[K]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns the value of key and marks it recently used
+func (c *lru[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		var zero V
+		return zero, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry[K, V]).value, true
+}
+
+// add stores a value, evicting the least recently used entries to fit.
+// Values costing more than the whole capacity are not stored.
+func (c *lru[K, V]) add(key K, value V, cost int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeLocked(key)
+	if cost > c.capacity {
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, cost: cost})
+	c.size += cost
+	for c.size > c.capacity {
+		c.removeLocked(c.order.Back().Value.(*lruEntry[K, V]).key)
+		c.stats.Evictions++
+	}
+}
+
+// remove drops key if present
+func (c *lru[K, V]) remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(key)
+}
+
+func (c *lru[K, V]) removeLocked(key K) {
+	element, ok := c.items[key]
+	if !ok {
+		return
+	}
+	c.order.Remove(element)
+	delete(c.items, key)
+	c.size -= element.Value.(*lruEntry[K, V]).cost
+}
+
+// snapshot returns the cache's counters
+func (c *lru[K, V]) snapshot() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = len(c.items)
+	stats.Bytes = c.size
+	stats.Capacity = c.capacity
+	return stats
+}
+
+// recordCache holds decoded records read often: recent blocks, headers,
+// hot accounts and the validator set. Entries are filled under the
+// database read lock and invalidated under the write lock, so a read never
+// caches a value a concurrent write replaced. Cached values are shared and
+// must not be modified.
+type recordCache struct {
+	blocks     *lru[uint64, *consensus.Block]
+	headers    *lru[uint64, *consensus.Block]
+	accounts   *lru[string, *consensus.Account]
+	validators *lru[struct{}, []*consensus.Validator]
+}
+
+// newRecordCache splits a byte budget between the caches
+func newRecordCache(size int) *recordCache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &recordCache{
+		blocks:     newLRU[uint64, *consensus.Block](size / 2),
+		headers:    newLRU[uint64, *consensus.Block](size / 10),
+		accounts:   newLRU[string, *consensus.Account](size * 3 / 10),
+		validators: newLRU[struct{}, []*consensus.Validator](size / 10),
+	}
+}
+
+// invalidate drops the entries a write to key replaces. Caller must hold
+// the database write lock.
+func (c *recordCache) invalidate(key []byte) {
+	k := string(key)
+	switch {
+	case strings.HasPrefix(k, PrefixBlock):
+		if number, err := strconv.ParseUint(k[len(PrefixBlock):], 10, 64); err == nil {
+			c.blocks.remove(number)
+			c.headers.remove(number)
+		}
+	case strings.HasPrefix(k, PrefixState):
+		c.accounts.remove(k[len(PrefixState):])
+	case strings.HasPrefix(k, PrefixValidator):
+		c.validators.remove(struct{}{})
+	}
+}
+
+// invalidateBatch drops the entries every write of a batch replaces
+func (c *recordCache) invalidateBatch(batch *Batch) {
+	batch.Replay(func(key, _ []byte) { c.invalidate(key) }, c.invalidate)
+}
+
+// header returns a block without its transactions
+func header(block *consensus.Block) *consensus.Block {
+	stripped := *block
+	stripped.Transactions = nil
+	return &stripped
+}
+
+// copyAccount returns an account callers may modify
+func copyAccount(account *consensus.Account) *consensus.Account {
+	copied := *account
+	if account.Balance != nil {
+		copied.Balance = new(big.Int).Set(account.Balance)
+	}
+	return &copied
+}
+
+// CacheStats returns the hit and miss counters of the record caches
+func (db *BlockchainDB) CacheStats() map[string]CacheStats {
+	return map[string]CacheStats{
+		"blocks":     db.cache.blocks.snapshot(),
+		"headers":    db.cache.headers.snapshot(),
+		"accounts":   db.cache.accounts.snapshot(),
+		"validators": db.cache.validators.snapshot(),
+	}
+}
+
+// write applies a batch and drops the cache entries it replaces. Caller
+// must hold the write lock.
+func (db *BlockchainDB) write(batch *Batch, sync bool) error {
+	err := db.store.Write(batch, sync)
+	db.cache.invalidateBatch(batch)
+	return err
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"math/big"
+	"testing"
+
+	"vnc-blockchain/consensus"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLRU[string, int](10)
+	c.add("a", 1, 4)
+	c.add("b", 2, 4)
+	c.get("a")
+	c.add("c", 3, 4)
+	if _, ok := c.get("b"); ok {
+		t.Fatal("least recently used entry not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.get(key); !ok {
+			t.Fatalf("entry %s evicted", key)
+		}
+	}
+	c.add("huge", 4, 11)
+	if _, ok := c.get("huge"); ok {
+		t.Fatal("entry over the capacity cached")
+	}
+
+	stats := c.snapshot()
+	if stats.Entries != 2 || stats.Bytes != 8 || stats.Evictions != 1 || stats.Hits != 3 || stats.Misses != 2 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
+
+func TestCacheInvalidatedOnCommit(t *testing.T) {
+	db := openMemoryDB(t)
+	if err := commitTestChain(db, 2); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if account, err := db.GetAccount("0xsender"); err != nil || account.Nonce != 4 {
+			t.Fatalf("account: %+v (%v)", account, err)
+		}
+	}
+	if hits := db.CacheStats()["accounts"].Hits; hits != 1 {
+		t.Fatalf("%d account cache hits, want 1", hits)
+	}
+	if validators, err := db.GetAllValidators(); err != nil || len(validators) != 0 {
+		t.Fatalf("validators: %d (%v)", len(validators), err)
+	}
+
+	// Committing the next block replaces the cached account
+	block := &consensus.Block{Number: 3, Hash: "0xblock3", PreviousHash: "0xblock2"}
+	accounts := []*consensus.Account{{Address: "0xsender", Balance: big.NewInt(90), Nonce: 5}}
+	if err := db.CommitBlock(block, nil, accounts, nil); err != nil {
+		t.Fatal(err)
+	}
+	if account, err := db.GetAccount("0xsender"); err != nil || account.Nonce != 5 {
+		t.Fatalf("account after the commit: %+v (%v)", account, err)
+	}
+
+	// Direct record writes replace cached blocks, headers and validators
+	if _, err := db.GetBlock(3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetHeader(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveBlock(&consensus.Block{Number: 3, Hash: "0xrewritten", PreviousHash: "0xblock2"}); err != nil {
+		t.Fatal(err)
+	}
+	if block, err := db.GetBlock(3); err != nil || block.Hash != "0xrewritten" {
+		t.Fatalf("block after the rewrite: %+v (%v)", block, err)
+	}
+	if header, err := db.GetHeader(3); err != nil || header.Hash != "0xrewritten" {
+		t.Fatalf("header after the rewrite: %+v (%v)", header, err)
+	}
+	if err := db.SaveValidator(&consensus.Validator{Address: "0xvalidator", Stake: big.NewInt(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if validators, err := db.GetAllValidators(); err != nil || len(validators) != 1 {
+		t.Fatalf("validators after saving one: %d (%v)", len(validators), err)
+	}
+}
+
+func TestCacheInvalidatedOnPrune(t *testing.T) {
+	db, err := OpenBlockchainDB(Config{Backend: BackendMemory, PruneMode: PrunePruned, RetainBlocks: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := commitTestChain(db, 5); err != nil {
+		t.Fatal(err)
+	}
+	for number := uint64(1); number <= 5; number++ {
+		if _, err := db.GetBlock(number); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.SaveCheckpoint(&consensus.ReplayCheckpoint{Height: 4, BlockHash: "0xblock4"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Prune(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pruned blocks leave the cache with the store, so no read below the
+	// floor check can return them
+	for number := uint64(1); number < 4; number++ {
+		if _, err := db.readBlock(number); err == nil {
+			t.Fatalf("pruned block #%d still readable", number)
+		}
+	}
+	if entries := db.CacheStats()["blocks"].Entries; entries != 2 {
+		t.Fatalf("%d blocks cached after pruning, want the 2 retained", entries)
+	}
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"vnc-blockchain/consensus"
+)
+
+// maxCheckProblems bounds the problems a chain check lists; the rest are
+// only counted
+const maxCheckProblems = 1000
+
+// Chain check problem kinds
+const (
+	ProblemMissingBlock = "missing_block"
+	ProblemBlockNumber  = "block_number"
+	ProblemParentLink   = "parent_link"
+	ProblemMissingTx    = "missing_tx"
+	ProblemReceipt      = "receipt"
+	ProblemIndex        = "index"
+)
+
+// ChainProblem is one inconsistency found by CheckChain
+type ChainProblem struct {
+	Kind   string `json:"kind"`
+	Height uint64 `json:"height"`
+	Key    string `json:"key,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// ChainCheckReport is the result of CheckChain. Blocks below From were
+// pruned or precede an imported snapshot; blocks up to SnapshotBase are
+// headers without transactions.
+type ChainCheckReport struct {
+	Head            uint64         `json:"head"`
+	From            uint64         `json:"from"`
+	SnapshotBase    uint64         `json:"snapshot_base,omitempty"`
+	BlocksChecked   uint64         `json:"blocks_checked"`
+	TxsChecked      uint64         `json:"txs_checked"`
+	IndexesChecked  uint64         `json:"index_entries_checked"`
+	ProblemCount    int            `json:"problem_count"`
+	IndexProblems   int            `json:"index_problems"`
+	Problems        []ChainProblem `json:"problems"`
+	IndexesRepaired bool           `json:"indexes_repaired"`
+}
+
+// OK reports whether no problem was found
+func (r *ChainCheckReport) OK() bool {
+	return r.ProblemCount == 0
+}
+
+func (r *ChainCheckReport) add(kind string, height uint64, key, format string, args ...interface{}) {
+	r.ProblemCount++
+	if kind == ProblemIndex {
+		r.IndexProblems++
+	}
+	if len(r.Problems) < maxCheckProblems {
+		r.Problems = append(r.Problems, ChainProblem{Kind: kind, Height: height, Key: key, Detail: fmt.Sprintf(format, args...)})
+	}
+}
+
+// CheckChain verifies the stored chain up to the head: every retained height
+// has a block linking to its parent, every transaction of a block is stored
+// with a matching receipt, and the indexes agree with the blocks in both
+// directions. With repairIndexes, index problems are fixed by rebuilding the
+// indexes, and the returned report is of a second check after the rebuild.
+func (db *BlockchainDB) CheckChain(repairIndexes bool) (*ChainCheckReport, error) {
+	report, err := db.checkChain()
+	if err != nil || !repairIndexes || report.IndexProblems == 0 {
+		return report, err
+	}
+	if _, err := db.RebuildIndexes(nil); err != nil {
+		return report, fmt.Errorf("failed to rebuild indexes: %w", err)
+	}
+	report, err = db.checkChain()
+	if report != nil {
+		report.IndexesRepaired = true
+	}
+	return report, err
+}
+
+func (db *BlockchainDB) checkChain() (*ChainCheckReport, error) {
+	head, err := db.GetLatestBlockNumber()
+	if err != nil {
+		return nil, err
+	}
+	base, err := db.GetSnapshotBase()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ChainCheckReport{Head: head, From: db.blockFloor.Load(), Problems: []ChainProblem{}}
+	if report.From == 0 {
+		report.From = 1 // Genesis is not stored
+	}
+	if base != nil {
+		report.SnapshotBase = base.Height
+	}
+
+	var parent *consensus.Block
+	for height := report.From; height <= head; height++ {
+		block, err := db.scanBlock(height)
+		if err != nil {
+			report.add(ProblemMissingBlock, height, fmt.Sprintf("%s%d", PrefixBlock, height), "%v", err)
+			parent = nil
+			continue
+		}
+		report.BlocksChecked++
+		if block.Number != height {
+			report.add(ProblemBlockNumber, height, "", "stored block is numbered #%d", block.Number)
+		}
+		if parent != nil && !consensus.LinksTo(parent, block) {
+			report.add(ProblemParentLink, height, "", "previous hash %s does not link to block #%d %s",
+				block.PreviousHash, parent.Number, parent.Hash)
+		}
+		parent = block
+
+		if number, err := db.GetBlockNumberByHash(block.Hash); err != nil || number != height {
+			report.add(ProblemIndex, height, PrefixBlockHash+block.Hash, "block hash does not resolve to #%d", height)
+		}
+		if height <= report.SnapshotBase {
+			continue // Snapshot headers carry no transactions
+		}
+		db.checkBlockTxs(report, block)
+	}
+
+	if err := db.checkIndexEntries(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// checkBlockTxs checks a block's transactions, receipts and index entries
+func (db *BlockchainDB) checkBlockTxs(report *ChainCheckReport, block *consensus.Block) {
+	receipts := make([]*consensus.Receipt, 0, len(block.Transactions))
+	gasUsed := uint64(0)
+	for i, tx := range block.Transactions {
+		report.TxsChecked++
+		txKey := PrefixTransaction + tx.Hash
+		if stored, err := db.GetTransaction(tx.Hash); err != nil {
+			report.add(ProblemMissingTx, block.Number, txKey, "%v", err)
+		} else if stored.Hash != tx.Hash {
+			report.add(ProblemMissingTx, block.Number, txKey, "stored transaction has hash %s", stored.Hash)
+		}
+
+		receiptKey := PrefixReceipt + tx.Hash
+		receipt, err := db.GetReceipt(tx.Hash)
+		switch {
+		case err != nil:
+			report.add(ProblemReceipt, block.Number, receiptKey, "%v", err)
+		case receipt.TxHash != tx.Hash || receipt.BlockNumber != block.Number || receipt.TxIndex != uint(i):
+			report.add(ProblemReceipt, block.Number, receiptKey, "receipt is for %s at #%d index %d, not index %d",
+				receipt.TxHash, receipt.BlockNumber, receipt.TxIndex, i)
+		default:
+			receipts = append(receipts, receipt)
+			gasUsed += receipt.GasUsed
+		}
+
+		location, err := db.GetTxLocation(tx.Hash)
+		if err != nil || location.BlockNumber != block.Number || location.Index != uint32(i) {
+			report.add(ProblemIndex, block.Number, PrefixTxLocation+tx.Hash, "location does not resolve to #%d index %d", block.Number, i)
+		}
+		position := txPosition(block.Number, uint32(i))
+		for _, address := range txAddresses(tx) {
+			key := append(addressTxPrefix(address), position...)
+			db.mutex.RLock()
+			value, err := db.store.Get(key)
+			db.mutex.RUnlock()
+			if err != nil || string(value) != tx.Hash {
+				report.add(ProblemIndex, block.Number, string(key), "address %s does not list %s", address, tx.Hash)
+			}
+		}
+	}
+
+	// Only a complete set of receipts can be compared with the header
+	if len(receipts) != len(block.Transactions) {
+		return
+	}
+	if gasUsed != block.GasUsed {
+		report.add(ProblemReceipt, block.Number, "", "receipts use %d gas, header records %d", gasUsed, block.GasUsed)
+	}
+	if consensus.CreateBloom(receipts) != block.LogsBloom {
+		report.add(ProblemReceipt, block.Number, "", "receipt logs do not match the header bloom")
+	}
+}
+
+// checkIndexEntries checks that every index entry refers to a stored block
+// at or below the head. Entries of pruned blocks are kept by design.
+func (db *BlockchainDB) checkIndexEntries(report *ChainCheckReport) error {
+	var cached *consensus.Block
+	blockAt := func(number uint64) *consensus.Block {
+		if cached == nil || cached.Number != number {
+			cached, _ = db.scanBlock(number)
+		}
+		return cached
+	}
+	// txAt returns the transaction at a position, or nil if the chain holds
+	// none there. ok is false for pruned positions, which are not checked.
+	txAt := func(number uint64, index uint32) (tx *consensus.Transaction, ok bool) {
+		if number < report.From {
+			return nil, false
+		}
+		block := blockAt(number)
+		if number > report.Head || block == nil || int(index) >= len(block.Transactions) {
+			return nil, true
+		}
+		return block.Transactions[index], true
+	}
+
+	// The store's iterators are consistent views, so entries are checked
+	// while iterating without holding the database lock
+	scan := func(prefix string, check func(key, value []byte)) error {
+		iter := db.store.NewIterator(PrefixRange([]byte(prefix)))
+		defer iter.Release()
+		for iter.Next() {
+			report.IndexesChecked++
+			check(iter.Key(), iter.Value())
+		}
+		if err := iter.Error(); err != nil {
+			return fmt.Errorf("failed to read %s: %w", prefix, err)
+		}
+		return nil
+	}
+
+	err := scan(PrefixBlockHash, func(key, value []byte) {
+		hash := string(key[len(PrefixBlockHash):])
+		if len(value) != 8 {
+			report.add(ProblemIndex, 0, string(key), "malformed entry")
+			return
+		}
+		number := binary.BigEndian.Uint64(value)
+		if number < report.From {
+			return
+		}
+		if block := blockAt(number); number > report.Head || block == nil || block.Hash != hash {
+			report.add(ProblemIndex, number, string(key), "no block #%d with hash %s", number, hash)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	err = scan(PrefixTxLocation, func(key, value []byte) {
+		hash := string(key[len(PrefixTxLocation):])
+		if len(value) != 12 {
+			report.add(ProblemIndex, 0, string(key), "malformed entry")
+			return
+		}
+		number, index := binary.BigEndian.Uint64(value), binary.BigEndian.Uint32(value[8:])
+		if tx, ok := txAt(number, index); ok && (tx == nil || tx.Hash != hash) {
+			report.add(ProblemIndex, number, string(key), "no transaction %s at #%d index %d", hash, number, index)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return scan(PrefixAddressTx, func(key, value []byte) {
+		rest := key[len(PrefixAddressTx):]
+		if len(rest) < 13 || rest[len(rest)-13] != 0 {
+			report.add(ProblemIndex, 0, string(key), "malformed entry")
+			return
+		}
+		address := string(rest[:len(rest)-13])
+		position := rest[len(rest)-12:]
+		number, index := binary.BigEndian.Uint64(position), binary.BigEndian.Uint32(position[8:])
+		tx, ok := txAt(number, index)
+		if !ok {
+			return
+		}
+		if tx == nil || tx.Hash != string(value) || !containsAddress(txAddresses(tx), address) {
+			report.add(ProblemIndex, number, string(key), "no transaction %s of %s at #%d index %d", value, address, number, index)
+		}
+	})
+}
+
+func containsAddress(addresses []string, address string) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
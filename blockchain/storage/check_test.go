@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// problemKinds counts a report's problems by kind
+func problemKinds(report *ChainCheckReport) map[string]int {
+	kinds := make(map[string]int)
+	for _, problem := range report.Problems {
+		kinds[problem.Kind]++
+	}
+	return kinds
+}
+
+func TestCheckChainFlagsCorruptRecords(t *testing.T) {
+	db := openMemoryDB(t)
+	if err := commitTestChain(db, 3); err != nil {
+		t.Fatal(err)
+	}
+	report, err := db.CheckChain(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.BlocksChecked != 3 || report.TxsChecked != 6 {
+		t.Fatalf("intact chain: %d blocks and %d transactions checked, problems %v",
+			report.BlocksChecked, report.TxsChecked, report.Problems)
+	}
+
+	corrupt := map[string][]byte{
+		PrefixReceipt + "0xtx2_0":  {0xff, 0x01},                          // unreadable
+		PrefixBlockHash + "0xfork": binary.BigEndian.AppendUint64(nil, 2), // not block #2's hash
+	}
+	for key, value := range corrupt {
+		if err := db.store.Put([]byte(key), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.store.Delete([]byte(PrefixTransaction + "0xtx3_1")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = db.CheckChain(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := problemKinds(report)
+	if report.ProblemCount != 3 || kinds[ProblemReceipt] != 1 || kinds[ProblemMissingTx] != 1 || kinds[ProblemIndex] != 1 {
+		t.Fatalf("found %v, want one unreadable receipt, one missing transaction and one stale index entry", report.Problems)
+	}
+	for _, problem := range report.Problems {
+		if problem.Kind == ProblemReceipt && (problem.Height != 2 || problem.Key != PrefixReceipt+"0xtx2_0") {
+			t.Fatalf("receipt problem reported as %+v", problem)
+		}
+	}
+
+	// Repairing fixes the indexes only
+	report, err = db.CheckChain(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.IndexesRepaired || report.IndexProblems != 0 || report.ProblemCount != 2 {
+		t.Fatalf("after repairing: %v", report.Problems)
+	}
+}
+
+func TestCheckChainFlagsCorruptBlock(t *testing.T) {
+	db := openMemoryDB(t)
+	if err := commitTestChain(db, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.store.Put([]byte(PrefixBlock+"2"), []byte{0xff}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := db.CheckChain(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() || report.Problems[0].Kind != ProblemMissingBlock || report.Problems[0].Height != 2 {
+		t.Fatalf("corrupt block #2 reported as %v", report.Problems)
+	}
+	if report.BlocksChecked != 2 {
+		t.Fatalf("%d blocks checked, want the 2 readable ones", report.BlocksChecked)
+	}
+}
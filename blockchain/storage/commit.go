@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"vnc-blockchain/consensus"
+)
+
+// metaLatestBlock is the metadata key of the head pointer
+const metaLatestBlock = "latest_block"
+
+// ConsistencyReport describes the state of the stored chain on open
+type ConsistencyReport struct {
+	Head         uint64   `json:"head"`                    // head pointer as found
+	RepairedHead uint64   `json:"repaired_head"`           // head pointer after repair
+	Problems     []string `json:"problems,omitempty"`      // what was found inconsistent
+	OrphanBlocks []uint64 `json:"orphan_blocks,omitempty"` // blocks stored above the head
+	Repaired     bool     `json:"repaired"`
+}
+
+// Consistent reports whether no problem was found
+func (r *ConsistencyReport) Consistent() bool {
+	return len(r.Problems) == 0
+}
+
+// CommitBlock writes a finalized block, its transactions and receipts, the
+// changed accounts and their history entries, the supply breakdown, the
+// block's index entries and the head pointer in one synced batch, so a
+// crash leaves either all of it or none of it on disk. The block must be
+// the child of the stored head.
+func (db *BlockchainDB) CommitBlock(block *consensus.Block, receipts []*consensus.Receipt, accounts []*consensus.Account, supply *consensus.SupplyInfo) error {
+	batch := new(Batch)
+
+	for _, tx := range block.Transactions {
+		if err := putBatchRecord(batch, fmt.Sprintf("%s%s", PrefixTransaction, tx.Hash), tx); err != nil {
+			return err
+		}
+	}
+	for _, receipt := range receipts {
+		if err := putBatchRecord(batch, fmt.Sprintf("%s%s", PrefixReceipt, receipt.TxHash), receipt); err != nil {
+			return err
+		}
+	}
+	for _, account := range accounts {
+		if err := putBatchRecord(batch, fmt.Sprintf("%s%s", PrefixState, account.Address), account); err != nil {
+			return err
+		}
+		if err := putBatchRecord(batch, string(stateHistoryKey(account.Address, block.Number)), account); err != nil {
+			return err
+		}
+	}
+	if supply != nil {
+		if err := putBatchRecord(batch, fmt.Sprintf("%s%d", PrefixSupply, block.Number), supply); err != nil {
+			return err
+		}
+	}
+	if err := putBatchRecord(batch, fmt.Sprintf("%s%d", PrefixBlock, block.Number), block); err != nil {
+		return err
+	}
+	indexBlock(batch, block)
+	if err := putBatchMetadata(batch, metaLatestBlock, block.Number); err != nil {
+		return err
+	}
+
+	db.mutex.Lock()
+	err := db.checkParent(block)
+	if err == nil {
+		err = db.write(batch, true)
+	}
+	db.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to commit block #%d: %w", block.Number, err)
+	}
+
+	if db.pruner != nil {
+		db.pruner.notify(block.Number)
+	}
+	return nil
+}
+
+// checkParent rejects a block that is not the next block after the stored
+// head or does not link to its hash. Caller must hold db.mutex.
+func (db *BlockchainDB) checkParent(block *consensus.Block) error {
+	var head uint64
+	data, err := db.store.Get([]byte(PrefixMetadata + metaLatestBlock))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &head); err != nil {
+			return fmt.Errorf("failed to read head: %w", err)
+		}
+	case err != ErrNotFound:
+		return fmt.Errorf("failed to read head: %w", err)
+	}
+
+	if block.Number != head+1 {
+		return fmt.Errorf("block #%d does not follow head #%d", block.Number, head)
+	}
+	parent := consensus.GenesisHeader()
+	if head > 0 {
+		parent = new(consensus.Block)
+		if _, err := db.loadRecord(fmt.Sprintf("%s%d", PrefixBlock, head), parent, "block"); err != nil {
+			return fmt.Errorf("failed to read head #%d: %w", head, err)
+		}
+	}
+	if !consensus.LinksTo(parent, block) {
+		return fmt.Errorf("block #%d parent %s does not match head #%d %s",
+			block.Number, block.PreviousHash, head, parent.Hash)
+	}
+	return nil
+}
+
+// putBatchRecord adds an encoded record to a batch
+func putBatchRecord(batch *Batch, key string, value record) error {
+	data, err := value.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	batch.Put([]byte(key), data)
+	return nil
+}
+
+// CheckConsistency verifies that the head block and everything it refers to
+// is stored, and that nothing was written above the head. Databases written
+// before CommitBlock, by separate puts, can be left half-written by a crash.
+// With repair, the head is moved back to the newest complete block and
+// orphaned blocks above it are deleted along with their transactions,
+// receipts and index entries.
+func (db *BlockchainDB) CheckConsistency(repair bool) (*ConsistencyReport, error) {
+	head, err := db.GetLatestBlockNumber()
+	if err != nil {
+		return nil, err
+	}
+	report := &ConsistencyReport{Head: head, RepairedHead: head}
+
+	// Walk back from the head to the newest block stored completely. Pruned
+	// blocks are not walked into.
+	for report.RepairedHead > 0 && db.checkBlockPruned(report.RepairedHead) == nil {
+		err := db.checkBlockComplete(report.RepairedHead)
+		if err == nil {
+			break
+		}
+		report.Problems = append(report.Problems, err.Error())
+		report.RepairedHead--
+	}
+
+	// Anything above the repaired head is an interrupted commit
+	for number := report.RepairedHead + 1; ; number++ {
+		block, err := db.GetBlock(number)
+		if err != nil {
+			if number > head {
+				break
+			}
+			continue
+		}
+		if number > head {
+			report.Problems = append(report.Problems, fmt.Sprintf("block #%d stored above head #%d", number, head))
+		}
+		report.OrphanBlocks = append(report.OrphanBlocks, block.Number)
+	}
+
+	if !repair || report.Consistent() {
+		return report, nil
+	}
+	if err := db.repairHead(report); err != nil {
+		return report, err
+	}
+	report.Repaired = true
+	return report, nil
+}
+
+// checkBlockComplete checks that a block, its transactions and receipts are stored
+func (db *BlockchainDB) checkBlockComplete(number uint64) error {
+	block, err := db.GetBlock(number)
+	if err != nil {
+		return fmt.Errorf("block #%d: %v", number, err)
+	}
+	for _, tx := range block.Transactions {
+		if _, err := db.GetTransaction(tx.Hash); err != nil {
+			return fmt.Errorf("block #%d: transaction %s: %v", number, tx.Hash, err)
+		}
+		if _, err := db.GetReceipt(tx.Hash); err != nil {
+			return fmt.Errorf("block #%d: receipt %s: %v", number, tx.Hash, err)
+		}
+	}
+	return nil
+}
+
+// repairHead deletes orphaned blocks and rewinds the head in one synced batch
+func (db *BlockchainDB) repairHead(report *ConsistencyReport) error {
+	batch := new(Batch)
+	for _, number := range report.OrphanBlocks {
+		if block, err := db.GetBlock(number); err == nil {
+			unindexBlock(batch, block)
+			for _, tx := range block.Transactions {
+				batch.Delete([]byte(PrefixTransaction + tx.Hash))
+				batch.Delete([]byte(PrefixReceipt + tx.Hash))
+			}
+		}
+		batch.Delete([]byte(fmt.Sprintf("%s%d", PrefixSupply, number)))
+		batch.Delete([]byte(fmt.Sprintf("%s%d", PrefixBlock, number)))
+	}
+	if err := putBatchMetadata(batch, metaLatestBlock, report.RepairedHead); err != nil {
+		return err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.write(batch, true); err != nil {
+		return fmt.Errorf("failed to repair head: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"vnc-blockchain/consensus"
+)
+
+// openMemoryDB opens an empty database on the memory backend
+func openMemoryDB(t *testing.T) *BlockchainDB {
+	t.Helper()
+	db, err := OpenBlockchainDB(Config{Backend: BackendMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestCommitBlockRequiresParent(t *testing.T) {
+	db := openMemoryDB(t)
+	genesis := consensus.GenesisHeader()
+	first := &consensus.Block{Number: 1, Hash: "0xblock1", PreviousHash: genesis.Hash}
+
+	cases := []struct {
+		name  string
+		block *consensus.Block
+		err   string
+	}{
+		{"gap", &consensus.Block{Number: 2, Hash: "0xblock2", PreviousHash: first.Hash}, "does not follow head #0"},
+		{"wrong genesis", &consensus.Block{Number: 1, Hash: "0xother", PreviousHash: "0xunknown"}, "does not match head #0"},
+	}
+	for _, c := range cases {
+		if err := db.CommitBlock(c.block, nil, nil, nil); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("%s: got %v, want %q", c.name, err, c.err)
+		}
+	}
+
+	if err := db.CommitBlock(first, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range []*consensus.Block{
+		first,
+		{Number: 2, Hash: "0xfork", PreviousHash: "0xnotblock1"},
+		{Number: 3, Hash: "0xblock3", PreviousHash: "0xblock2"},
+	} {
+		if err := db.CommitBlock(block, nil, nil, nil); err == nil {
+			t.Fatalf("block #%d with parent %s committed on head #1", block.Number, block.PreviousHash)
+		}
+	}
+	if head, _ := db.GetLatestBlockNumber(); head != 1 {
+		t.Fatalf("head moved to #%d", head)
+	}
+
+	second := &consensus.Block{Number: 2, Hash: "0xblock2", PreviousHash: first.Hash}
+	if err := db.CommitBlock(second, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,519 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"vnc-blockchain/consensus"
+)
+
+// conformanceChecks is the KVStore contract. Each check runs on a fresh,
+// empty store.
+var conformanceChecks = []struct {
+	name string
+	run  func(KVStore) error
+}{
+	{"get-put-delete", checkGetPutDelete},
+	{"value-ownership", checkValueOwnership},
+	{"batch", checkBatch},
+	{"iterator-order", checkIteratorOrder},
+	{"iterator-range", checkIteratorRange},
+	{"iterator-isolation", checkIteratorIsolation},
+	{"snapshot", checkSnapshot},
+	{"blockchain-db", checkBlockchainDB},
+	{"blockchain-db-pruning", checkPruning},
+	{"close", checkClose},
+}
+
+// TestConformance verifies that every backend meets the KVStore contract
+// the blockchain database relies on, and that the BlockchainDB API works
+// on it
+func TestConformance(t *testing.T) {
+	for _, backend := range []string{BackendLevelDB, BackendMemory} {
+		t.Run(backend, func(t *testing.T) {
+			for _, check := range conformanceChecks {
+				t.Run(check.name, func(t *testing.T) {
+					store, err := OpenKVStore(Config{Backend: backend, DataDir: t.TempDir()})
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer store.Close()
+					if err := check.run(store); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func checkGetPutDelete(store KVStore) error {
+	if _, err := store.Get([]byte("missing")); !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("get of missing key returned %v, want ErrNotFound", err)
+	}
+	if err := store.Put([]byte("k"), []byte("v1")); err != nil {
+		return err
+	}
+	if err := store.Put([]byte("k"), []byte("v2")); err != nil {
+		return err
+	}
+	if err := expectValue(store, "k", "v2"); err != nil {
+		return err
+	}
+	if err := store.Put([]byte("empty"), []byte{}); err != nil {
+		return err
+	}
+	if err := expectValue(store, "empty", ""); err != nil {
+		return err
+	}
+	if err := store.Delete([]byte("k")); err != nil {
+		return err
+	}
+	if _, err := store.Get([]byte("k")); !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("get after delete returned %v, want ErrNotFound", err)
+	}
+	if err := store.Delete([]byte("k")); err != nil {
+		return fmt.Errorf("delete of missing key: %w", err)
+	}
+	return nil
+}
+
+func checkValueOwnership(store KVStore) error {
+	value := []byte("original")
+	if err := store.Put([]byte("k"), value); err != nil {
+		return err
+	}
+	copy(value, "mutated!")
+	if err := expectValue(store, "k", "original"); err != nil {
+		return fmt.Errorf("store kept the caller's slice: %w", err)
+	}
+	got, _ := store.Get([]byte("k"))
+	copy(got, "mutated!")
+	if err := expectValue(store, "k", "original"); err != nil {
+		return fmt.Errorf("get returned the stored slice: %w", err)
+	}
+	return nil
+}
+
+func checkBatch(store KVStore) error {
+	if err := store.Put([]byte("doomed"), []byte("x")); err != nil {
+		return err
+	}
+
+	batch := new(Batch)
+	batch.Put([]byte("a"), []byte("1"))
+	batch.Put([]byte("b"), []byte("1"))
+	batch.Put([]byte("a"), []byte("2"))
+	batch.Delete([]byte("doomed"))
+	batch.Delete([]byte("b"))
+	if batch.Len() != 5 {
+		return fmt.Errorf("batch has %d operations, want 5", batch.Len())
+	}
+	if _, err := store.Get([]byte("a")); !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("batch applied before Write")
+	}
+	if err := store.Write(batch, true); err != nil {
+		return err
+	}
+	if err := expectValue(store, "a", "2"); err != nil {
+		return fmt.Errorf("later batch operations must win: %w", err)
+	}
+	for _, key := range []string{"b", "doomed"} {
+		if _, err := store.Get([]byte(key)); !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("batch delete of %q not applied", key)
+		}
+	}
+
+	batch.Reset()
+	if batch.Len() != 0 {
+		return fmt.Errorf("reset batch has %d operations", batch.Len())
+	}
+	return store.Write(batch, false)
+}
+
+func checkIteratorOrder(store KVStore) error {
+	keys := []string{"b", "a\xff", "\x00", "a", "ab", "\xff\xff", "b\x00"}
+	for _, key := range keys {
+		if err := store.Put([]byte(key), []byte("v:"+key)); err != nil {
+			return err
+		}
+	}
+	want := []string{"\x00", "a", "ab", "a\xff", "b", "b\x00", "\xff\xff"}
+
+	iter := store.NewIterator(nil)
+	defer iter.Release()
+	if iter.Prev() {
+		return fmt.Errorf("prev on a new iterator moved to %q", iter.Key())
+	}
+	var got []string
+	for iter.Next() {
+		if string(iter.Value()) != "v:"+string(iter.Key()) {
+			return fmt.Errorf("value of %q is %q", iter.Key(), iter.Value())
+		}
+		got = append(got, string(iter.Key()))
+	}
+	if err := expectKeys("forward", got, want); err != nil {
+		return err
+	}
+
+	got = nil
+	for ok := iter.Last(); ok; ok = iter.Prev() {
+		got = append(got, string(iter.Key()))
+	}
+	reversed := make([]string, len(want))
+	for i, key := range want {
+		reversed[len(want)-1-i] = key
+	}
+	if err := expectKeys("backward", got, reversed); err != nil {
+		return err
+	}
+	if !iter.First() || string(iter.Key()) != want[0] {
+		return fmt.Errorf("first did not return to %q", want[0])
+	}
+	return iter.Error()
+}
+
+func checkIteratorRange(store KVStore) error {
+	for _, key := range []string{"p", "pa", "pb", "p\xff", "q", "o\xff"} {
+		if err := store.Put([]byte(key), []byte{1}); err != nil {
+			return err
+		}
+	}
+	ranges := []struct {
+		name string
+		r    *Range
+		want []string
+	}{
+		{"prefix", PrefixRange([]byte("p")), []string{"p", "pa", "pb", "p\xff"}},
+		{"bounded", &Range{Start: []byte("pa"), Limit: []byte("p\xff")}, []string{"pa", "pb"}},
+		{"open start", &Range{Limit: []byte("p")}, []string{"o\xff"}},
+		{"open limit", &Range{Start: []byte("p\xff")}, []string{"p\xff", "q"}},
+		{"empty", &Range{Start: []byte("x"), Limit: []byte("y")}, nil},
+	}
+	for _, tc := range ranges {
+		var got []string
+		iter := store.NewIterator(tc.r)
+		for iter.Next() {
+			got = append(got, string(iter.Key()))
+		}
+		err := iter.Error()
+		iter.Release()
+		if err != nil {
+			return err
+		}
+		if err := expectKeys(tc.name, got, tc.want); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkIteratorIsolation(store KVStore) error {
+	for _, key := range []string{"a", "b", "c"} {
+		if err := store.Put([]byte(key), []byte("old")); err != nil {
+			return err
+		}
+	}
+	iter := store.NewIterator(nil)
+	defer iter.Release()
+
+	if err := store.Put([]byte("b"), []byte("new")); err != nil {
+		return err
+	}
+	if err := store.Put([]byte("bb"), []byte("new")); err != nil {
+		return err
+	}
+	if err := store.Delete([]byte("c")); err != nil {
+		return err
+	}
+
+	var got []string
+	for iter.Next() {
+		if string(iter.Value()) != "old" {
+			return fmt.Errorf("iterator saw a later write to %q", iter.Key())
+		}
+		got = append(got, string(iter.Key()))
+	}
+	return expectKeys("iterator", got, []string{"a", "b", "c"})
+}
+
+func checkSnapshot(store KVStore) error {
+	if err := store.Put([]byte("a"), []byte("old")); err != nil {
+		return err
+	}
+	if err := store.Put([]byte("b"), []byte("old")); err != nil {
+		return err
+	}
+	snapshot, err := store.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	batch := new(Batch)
+	batch.Put([]byte("a"), []byte("new"))
+	batch.Put([]byte("c"), []byte("new"))
+	batch.Delete([]byte("b"))
+	if err := store.Write(batch, false); err != nil {
+		return err
+	}
+
+	if err := expectValue(snapshot, "a", "old"); err != nil {
+		return err
+	}
+	if err := expectValue(snapshot, "b", "old"); err != nil {
+		return err
+	}
+	if _, err := snapshot.Get([]byte("c")); !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("snapshot saw a later write to \"c\"")
+	}
+	var got []string
+	iter := snapshot.NewIterator(nil)
+	for iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	iter.Release()
+	if err := expectKeys("snapshot iterator", got, []string{"a", "b"}); err != nil {
+		return err
+	}
+	return expectValue(store, "a", "new")
+}
+
+func checkClose(store KVStore) error {
+	if err := store.Put([]byte("k"), []byte("v")); err != nil {
+		return err
+	}
+	if err := store.Close(); err != nil {
+		return err
+	}
+	if _, err := store.Get([]byte("k")); err == nil {
+		return fmt.Errorf("get succeeded after close")
+	}
+	if err := store.Put([]byte("k"), []byte("v")); err == nil {
+		return fmt.Errorf("put succeeded after close")
+	}
+	return nil
+}
+
+// checkBlockchainDB runs the BlockchainDB API on the store
+func checkBlockchainDB(store KVStore) error {
+	db, err := NewBlockchainDBWithStore(store, Config{})
+	if err != nil {
+		return err
+	}
+
+	if err := commitTestChain(db, 3); err != nil {
+		return err
+	}
+
+	head, err := db.GetLatestBlockNumber()
+	if err != nil || head != 3 {
+		return fmt.Errorf("head is %d (%v), want 3", head, err)
+	}
+	block, err := db.GetBlockByHash("0xblock2")
+	if err != nil || block.Number != 2 || len(block.Transactions) != 2 || block.PreviousHash != "0xblock1" {
+		return fmt.Errorf("block by hash: %+v (%v)", block, err)
+	}
+	tx, err := db.GetTransaction("0xtx3_1")
+	if err != nil || tx.Value.Int64() != 3 {
+		return fmt.Errorf("transaction: %+v (%v)", tx, err)
+	}
+	if location, err := db.GetTxLocation("0xtx3_1"); err != nil || location.BlockNumber != 3 || location.Index != 1 {
+		return fmt.Errorf("transaction location: %+v (%v)", location, err)
+	}
+	if receipt, err := db.GetReceipt("0xtx2_0"); err != nil || receipt.BlockNumber != 2 {
+		return fmt.Errorf("receipt: %+v (%v)", receipt, err)
+	}
+	if account, err := db.GetAccount("0xsender"); err != nil || account.Balance.Int64() != 97 || account.Nonce != 6 {
+		return fmt.Errorf("account: %+v (%v)", account, err)
+	}
+
+	var hashes []string
+	cursor := ""
+	for {
+		page, err := db.GetAddressTransactions("0xsender", cursor, 4, true)
+		if err != nil {
+			return err
+		}
+		for _, entry := range page.Transactions {
+			hashes = append(hashes, entry.Hash)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if err := expectKeys("address history", hashes, []string{"0xtx3_1", "0xtx3_0", "0xtx2_1", "0xtx2_0", "0xtx1_1", "0xtx1_0"}); err != nil {
+		return err
+	}
+	if page, err := db.GetAddressTransactions("0xrecipient0", "", 0, false); err != nil || len(page.Transactions) != 3 {
+		return fmt.Errorf("recipient history: %v", err)
+	}
+
+	if err := db.SaveValidator(&consensus.Validator{Address: "0xvalidator", Stake: big.NewInt(1), DelegatedStake: big.NewInt(0)}); err != nil {
+		return err
+	}
+	if validators, err := db.GetAllValidators(); err != nil || len(validators) != 1 {
+		return fmt.Errorf("validators: %d (%v)", len(validators), err)
+	}
+	if err := db.SaveMempoolTx("0xpending", map[string]string{"hash": "0xpending"}); err != nil {
+		return err
+	}
+	if pending, err := db.GetMempoolTransactions(); err != nil || len(pending) != 1 {
+		return fmt.Errorf("mempool journal: %d (%v)", len(pending), err)
+	}
+	if err := db.DeleteMempoolTx("0xpending"); err != nil {
+		return err
+	}
+
+	if indexed, err := db.RebuildIndexes(nil); err != nil || indexed != 3 {
+		return fmt.Errorf("rebuilt %d blocks (%v), want 3", indexed, err)
+	}
+	report, err := db.CheckConsistency(false)
+	if err != nil {
+		return err
+	}
+	if !report.Consistent() {
+		return fmt.Errorf("consistency: %v", report.Problems)
+	}
+	return nil
+}
+
+// checkPruning runs the BlockchainDB API on the store in pruned mode
+func checkPruning(store KVStore) error {
+	db, err := NewBlockchainDBWithStore(store, Config{PruneMode: PrunePruned, RetainBlocks: 2})
+	if err != nil {
+		return err
+	}
+	defer db.pruner.close()
+
+	if err := commitTestChain(db, 5); err != nil {
+		return err
+	}
+	// Without a checkpoint replay could start from, no block is pruned
+	if err := db.Prune(); err != nil {
+		return err
+	}
+	if _, err := db.GetBlock(1); err != nil {
+		return fmt.Errorf("block pruned with no checkpoint to replay from: %v", err)
+	}
+	if err := db.SaveCheckpoint(&consensus.ReplayCheckpoint{Height: 4, BlockHash: "0xblock4"}); err != nil {
+		return err
+	}
+	if err := db.Prune(); err != nil {
+		return err
+	}
+	if base, err := db.GetSnapshotBase(); err != nil || base == nil || base.Height != 4 {
+		return fmt.Errorf("snapshot base after pruning: %+v (%v), want the checkpoint at #4", base, err)
+	}
+
+	if _, err := db.GetBlock(3); !errors.Is(err, ErrPruned) {
+		return fmt.Errorf("block below the retained range returned %v, want ErrPruned", err)
+	}
+	if block, err := db.GetBlock(4); err != nil || block.Number != 4 {
+		return fmt.Errorf("retained block: %v", err)
+	}
+	if _, err := db.GetBlockByHash("0xblock1"); !errors.Is(err, ErrPruned) {
+		return fmt.Errorf("pruned block by hash returned %v, want ErrPruned", err)
+	}
+	if _, err := db.GetTransaction("0xtx2_0"); !errors.Is(err, ErrPruned) {
+		return fmt.Errorf("pruned transaction returned %v, want ErrPruned", err)
+	}
+	if _, err := db.GetReceipt("0xtx2_0"); !errors.Is(err, ErrPruned) {
+		return fmt.Errorf("pruned receipt returned %v, want ErrPruned", err)
+	}
+	if _, err := db.GetTransaction("0xmissing"); !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("unknown transaction returned %v, want ErrNotFound", err)
+	}
+	if _, err := db.GetAccountAt("0xsender", 3); !errors.Is(err, ErrPruned) {
+		return fmt.Errorf("pruned state returned %v, want ErrPruned", err)
+	}
+	if account, err := db.GetAccountAt("0xsender", 4); err != nil || account.Balance.Int64() != 96 {
+		return fmt.Errorf("retained state: %+v (%v)", account, err)
+	}
+	// Changed only in block 1, so its value at the retained heights comes
+	// from the entry kept below the range
+	if account, err := db.GetAccountAt("0xgenesis", 5); err != nil || account.Balance.Int64() != 1000 {
+		return fmt.Errorf("state last changed before the retained range: %+v (%v)", account, err)
+	}
+
+	page, err := db.GetAddressTransactions("0xsender", "", 0, false)
+	if err != nil {
+		return err
+	}
+	if len(page.Transactions) != 4 || page.Transactions[0].BlockNumber != 4 {
+		return fmt.Errorf("address history holds %d transactions, want the 4 of blocks 4-5", len(page.Transactions))
+	}
+	if indexed, err := db.RebuildIndexes(nil); err != nil || indexed != 2 {
+		return fmt.Errorf("rebuilt %d blocks (%v), want 2", indexed, err)
+	}
+	report, err := db.CheckConsistency(false)
+	if err != nil {
+		return err
+	}
+	if !report.Consistent() {
+		return fmt.Errorf("consistency: %v", report.Problems)
+	}
+	return nil
+}
+
+// commitTestChain commits blocks 1..blocks of two transfers each
+func commitTestChain(db *BlockchainDB, blocks uint64) error {
+	previous := consensus.GenesisHeader().Hash
+	for number := uint64(1); number <= blocks; number++ {
+		block := &consensus.Block{
+			Number:       number,
+			Hash:         fmt.Sprintf("0xblock%d", number),
+			PreviousHash: previous,
+			Timestamp:    int64(number),
+		}
+		var receipts []*consensus.Receipt
+		for i := 0; i < 2; i++ {
+			tx := &consensus.Transaction{
+				Hash:  fmt.Sprintf("0xtx%d_%d", number, i),
+				From:  "0xsender",
+				To:    fmt.Sprintf("0xrecipient%d", i),
+				Value: big.NewInt(int64(number)),
+				Nonce: number*2 + uint64(i),
+			}
+			block.Transactions = append(block.Transactions, tx)
+			receipts = append(receipts, &consensus.Receipt{TxHash: tx.Hash, TxIndex: uint(i), BlockNumber: number, Status: 1})
+		}
+		accounts := []*consensus.Account{{Address: "0xsender", Balance: big.NewInt(100 - int64(number)), Nonce: number * 2}}
+		if number == 1 {
+			accounts = append(accounts, &consensus.Account{Address: "0xgenesis", Balance: big.NewInt(1000)})
+		}
+		if err := db.CommitBlock(block, receipts, accounts, nil); err != nil {
+			return err
+		}
+		previous = block.Hash
+	}
+	return nil
+}
+
+// expectValue checks the value stored under key
+func expectValue(reader KVReader, key, want string) error {
+	got, err := reader.Get([]byte(key))
+	if err != nil {
+		return fmt.Errorf("get %q: %w", key, err)
+	}
+	if !bytes.Equal(got, []byte(want)) {
+		return fmt.Errorf("get %q returned %q, want %q", key, got, want)
+	}
+	return nil
+}
+
+// expectKeys checks an ordered list of keys
+func expectKeys(what string, got, want []string) error {
+	if len(got) != len(want) {
+		return fmt.Errorf("%s: got keys %q, want %q", what, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return fmt.Errorf("%s: got keys %q, want %q", what, got, want)
+		}
+	}
+	return nil
+}
@@ -4,15 +4,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/util"
+	"vnc-blockchain/consensus"
 )
 
 // BlockchainDB handles persistent storage for blockchain data
 type BlockchainDB struct {
-	db    *leveldb.DB
-	mutex sync.RWMutex
+	store  KVStore
+	mutex  sync.RWMutex
+	pruner *pruner
+	cache  *recordCache
+
+	blockFloor atomic.Uint64 // blocks below were pruned
+	stateFloor atomic.Uint64 // state history below was pruned
 }
 
 // Database key prefixes
@@ -23,177 +28,209 @@ const (
 	PrefixValidator   = "validator:"
 	PrefixMetadata    = "meta:"
 	PrefixReceipt     = "receipt:"
+	PrefixMempool     = "mempool:"
+	PrefixSupply      = "supply:"
 )
 
 // NewBlockchainDB creates a new blockchain database
 func NewBlockchainDB(dataDir string) (*BlockchainDB, error) {
-	db, err := leveldb.OpenFile(dataDir, nil)
+	return OpenBlockchainDB(Config{Backend: BackendLevelDB, DataDir: dataDir})
+}
+
+// NewReadOnlyBlockchainDB opens an existing database for offline tools.
+// Writes fail, and it can be opened while no node holds the database.
+func NewReadOnlyBlockchainDB(dataDir string) (*BlockchainDB, error) {
+	return OpenBlockchainDB(Config{Backend: BackendLevelDB, DataDir: dataDir, ReadOnly: true})
+}
+
+// OpenBlockchainDB opens a blockchain database on the backend selected by cfg
+func OpenBlockchainDB(cfg Config) (*BlockchainDB, error) {
+	store, err := OpenKVStore(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	blockchain := &BlockchainDB{
-		db: db,
+	blockchain, err := NewBlockchainDBWithStore(store, cfg)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if !cfg.ReadOnly {
+		if cfg.Backend == BackendMemory {
+			fmt.Println("💾 Database opened in memory")
+		} else {
+			fmt.Printf("💾 Database opened at: %s\n", cfg.DataDir)
+		}
 	}
-
-	fmt.Printf("💾 Database opened at: %s\n", dataDir)
 	return blockchain, nil
 }
 
-// SaveBlock saves a block to the database
-func (db *BlockchainDB) SaveBlock(blockNumber uint64, blockData interface{}) error {
-	db.mutex.Lock()
-	defer db.mutex.Unlock()
-
-	key := fmt.Sprintf("%s%d", PrefixBlock, blockNumber)
-	data, err := json.Marshal(blockData)
-	if err != nil {
-		return fmt.Errorf("failed to marshal block: %w", err)
+// NewBlockchainDBWithStore runs a blockchain database on an open store, with
+// the access and pruning settings of cfg. When writable, older schemas are
+// migrated.
+func NewBlockchainDBWithStore(store KVStore, cfg Config) (*BlockchainDB, error) {
+	if !validPruneMode(cfg.PruneMode) {
+		return nil, fmt.Errorf("unknown pruning mode %q", cfg.PruneMode)
 	}
 
-	if err := db.db.Put([]byte(key), data, nil); err != nil {
-		return fmt.Errorf("failed to save block: %w", err)
+	blockchain := &BlockchainDB{store: store, cache: newRecordCache(cfg.CacheSize)}
+	if err := blockchain.loadPruneFloors(); err != nil {
+		return nil, err
 	}
-
-	return nil
+	if err := blockchain.checkSchema(!cfg.ReadOnly); err != nil {
+		return nil, err
+	}
+	if !cfg.ReadOnly && cfg.PruneMode != "" && cfg.PruneMode != PruneArchive {
+		blockchain.startPruner(cfg.PruneMode, cfg.RetainBlocks)
+	}
+	return blockchain, nil
 }
 
-// GetBlock retrieves a block from the database
-func (db *BlockchainDB) GetBlock(blockNumber uint64) (map[string]interface{}, error) {
-	db.mutex.RLock()
-	defer db.mutex.RUnlock()
+// SaveBlock saves a block, with its transactions, to the database
+func (db *BlockchainDB) SaveBlock(block *consensus.Block) error {
+	key := fmt.Sprintf("%s%d", PrefixBlock, block.Number)
+	return db.putRecord(key, block, "block")
+}
 
-	key := fmt.Sprintf("%s%d", PrefixBlock, blockNumber)
-	data, err := db.db.Get([]byte(key), nil)
-	if err != nil {
-		return nil, fmt.Errorf("block not found: %w", err)
+// GetBlock retrieves a block from the database. Blocks are cached and
+// shared between callers, so they must not be modified.
+func (db *BlockchainDB) GetBlock(blockNumber uint64) (*consensus.Block, error) {
+	if err := db.checkBlockPruned(blockNumber); err != nil {
+		return nil, err
 	}
+	return db.readBlock(blockNumber)
+}
 
-	var block map[string]interface{}
-	if err := json.Unmarshal(data, &block); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal block: %w", err)
+// GetHeader retrieves a block without its transactions. Headers are
+// cached apart from blocks, so header walks do not evict full blocks.
+func (db *BlockchainDB) GetHeader(blockNumber uint64) (*consensus.Block, error) {
+	if err := db.checkBlockPruned(blockNumber); err != nil {
+		return nil, err
+	}
+	if cached, ok := db.cache.headers.get(blockNumber); ok {
+		return cached, nil
 	}
 
-	return block, nil
-}
-
-// SaveTransaction saves a transaction to the database
-func (db *BlockchainDB) SaveTransaction(txHash string, txData interface{}) error {
-	db.mutex.Lock()
-	defer db.mutex.Unlock()
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
 
-	key := fmt.Sprintf("%s%s", PrefixTransaction, txHash)
-	data, err := json.Marshal(txData)
-	if err != nil {
-		return fmt.Errorf("failed to marshal transaction: %w", err)
+	block := new(consensus.Block)
+	if _, err := db.loadRecord(fmt.Sprintf("%s%d", PrefixBlock, blockNumber), block, "block"); err != nil {
+		return nil, err
 	}
+	stripped := header(block)
+	db.cache.headers.add(blockNumber, stripped, headerCost)
+	return stripped, nil
+}
 
-	return db.db.Put([]byte(key), data, nil)
+// SaveTransaction saves a transaction to the database
+func (db *BlockchainDB) SaveTransaction(tx *consensus.Transaction) error {
+	key := fmt.Sprintf("%s%s", PrefixTransaction, tx.Hash)
+	return db.putRecord(key, tx, "transaction")
 }
 
 // GetTransaction retrieves a transaction from the database
-func (db *BlockchainDB) GetTransaction(txHash string) (map[string]interface{}, error) {
-	db.mutex.RLock()
-	defer db.mutex.RUnlock()
-
+func (db *BlockchainDB) GetTransaction(txHash string) (*consensus.Transaction, error) {
 	key := fmt.Sprintf("%s%s", PrefixTransaction, txHash)
-	data, err := db.db.Get([]byte(key), nil)
-	if err != nil {
-		return nil, fmt.Errorf("transaction not found: %w", err)
+	tx := new(consensus.Transaction)
+	if err := db.getRecord(key, tx, "transaction"); err != nil {
+		return nil, db.checkTxPruned(txHash, err)
 	}
-
-	var tx map[string]interface{}
-	if err := json.Unmarshal(data, &tx); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal transaction: %w", err)
-	}
-
 	return tx, nil
 }
 
-// SaveState saves account state to the database
-func (db *BlockchainDB) SaveState(address string, state interface{}) error {
-	db.mutex.Lock()
-	defer db.mutex.Unlock()
+// SaveAccount saves account state to the database
+func (db *BlockchainDB) SaveAccount(account *consensus.Account) error {
+	key := fmt.Sprintf("%s%s", PrefixState, account.Address)
+	return db.putRecord(key, account, "account")
+}
 
-	key := fmt.Sprintf("%s%s", PrefixState, address)
-	data, err := json.Marshal(state)
-	if err != nil {
-		return fmt.Errorf("failed to marshal state: %w", err)
+// GetAccount retrieves account state from the database
+func (db *BlockchainDB) GetAccount(address string) (*consensus.Account, error) {
+	if account, ok := db.cache.accounts.get(address); ok {
+		return copyAccount(account), nil
 	}
 
-	return db.db.Put([]byte(key), data, nil)
-}
-
-// GetState retrieves account state from the database
-func (db *BlockchainDB) GetState(address string) (map[string]interface{}, error) {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
 	key := fmt.Sprintf("%s%s", PrefixState, address)
-	data, err := db.db.Get([]byte(key), nil)
+	account := new(consensus.Account)
+	size, err := db.loadRecord(key, account, "account")
 	if err != nil {
-		return nil, fmt.Errorf("state not found: %w", err)
+		return nil, err
 	}
-
-	var state map[string]interface{}
-	if err := json.Unmarshal(data, &state); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
-	}
-
-	return state, nil
+	db.cache.accounts.add(address, account, size)
+	return copyAccount(account), nil
 }
 
 // SaveValidator saves validator information
-func (db *BlockchainDB) SaveValidator(address string, validatorData interface{}) error {
-	db.mutex.Lock()
-	defer db.mutex.Unlock()
-
-	key := fmt.Sprintf("%s%s", PrefixValidator, address)
-	data, err := json.Marshal(validatorData)
-	if err != nil {
-		return fmt.Errorf("failed to marshal validator: %w", err)
-	}
-
-	return db.db.Put([]byte(key), data, nil)
+func (db *BlockchainDB) SaveValidator(validator *consensus.Validator) error {
+	key := fmt.Sprintf("%s%s", PrefixValidator, validator.Address)
+	return db.putRecord(key, validator, "validator")
 }
 
 // GetValidator retrieves validator information
-func (db *BlockchainDB) GetValidator(address string) (map[string]interface{}, error) {
-	db.mutex.RLock()
-	defer db.mutex.RUnlock()
+func (db *BlockchainDB) GetValidator(address string) (*consensus.Validator, error) {
+	if validators, ok := db.cache.validators.get(struct{}{}); ok {
+		for _, validator := range validators {
+			if validator.Address == address {
+				copied := *validator
+				return &copied, nil
+			}
+		}
+		return nil, fmt.Errorf("validator not found: %w", ErrNotFound)
+	}
 
 	key := fmt.Sprintf("%s%s", PrefixValidator, address)
-	data, err := db.db.Get([]byte(key), nil)
-	if err != nil {
-		return nil, fmt.Errorf("validator not found: %w", err)
+	validator := new(consensus.Validator)
+	if err := db.getRecord(key, validator, "validator"); err != nil {
+		return nil, err
 	}
+	return validator, nil
+}
 
-	var validator map[string]interface{}
-	if err := json.Unmarshal(data, &validator); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal validator: %w", err)
+// GetAllValidators retrieves all validators. The set is cached whole.
+func (db *BlockchainDB) GetAllValidators() ([]*consensus.Validator, error) {
+	cached, ok := db.cache.validators.get(struct{}{})
+	if !ok {
+		var err error
+		if cached, err = db.loadValidators(); err != nil {
+			return nil, err
+		}
 	}
 
-	return validator, nil
+	validators := make([]*consensus.Validator, len(cached))
+	for i, validator := range cached {
+		copied := *validator
+		validators[i] = &copied
+	}
+	return validators, nil
 }
 
-// GetAllValidators retrieves all validators
-func (db *BlockchainDB) GetAllValidators() ([]map[string]interface{}, error) {
+// loadValidators reads the validator set and caches it
+func (db *BlockchainDB) loadValidators() ([]*consensus.Validator, error) {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
-	validators := make([]map[string]interface{}, 0)
-	iter := db.db.NewIterator(util.BytesPrefix([]byte(PrefixValidator)), nil)
+	validators := make([]*consensus.Validator, 0)
+	size := 0
+	iter := db.store.NewIterator(PrefixRange([]byte(PrefixValidator)))
 	defer iter.Release()
 
 	for iter.Next() {
-		var validator map[string]interface{}
-		if err := json.Unmarshal(iter.Value(), &validator); err != nil {
+		validator := new(consensus.Validator)
+		if err := decodeRecord(iter.Value(), validator); err != nil {
 			continue
 		}
 		validators = append(validators, validator)
+		size += len(iter.Value())
 	}
-
-	return validators, iter.Error()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	db.cache.validators.add(struct{}{}, validators, size)
+	return validators, nil
 }
 
 // SaveMetadata saves blockchain metadata
@@ -207,7 +244,7 @@ func (db *BlockchainDB) SaveMetadata(key string, value interface{}) error {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	return db.db.Put([]byte(metaKey), data, nil)
+	return db.store.Put([]byte(metaKey), data)
 }
 
 // GetMetadata retrieves blockchain metadata
@@ -216,7 +253,7 @@ func (db *BlockchainDB) GetMetadata(key string) (interface{}, error) {
 	defer db.mutex.RUnlock()
 
 	metaKey := fmt.Sprintf("%s%s", PrefixMetadata, key)
-	data, err := db.db.Get([]byte(metaKey), nil)
+	data, err := db.store.Get([]byte(metaKey))
 	if err != nil {
 		return nil, fmt.Errorf("metadata not found: %w", err)
 	}
@@ -229,64 +266,119 @@ func (db *BlockchainDB) GetMetadata(key string) (interface{}, error) {
 	return value, nil
 }
 
-// GetLatestBlockNumber retrieves the latest block number
-func (db *BlockchainDB) GetLatestBlockNumber() (uint64, error) {
-	value, err := db.GetMetadata("latest_block")
+// getMetadataInto decodes a metadata value into dst, reporting whether it exists
+func (db *BlockchainDB) getMetadataInto(key string, dst interface{}) (bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	data, err := db.store.Get([]byte(PrefixMetadata + key))
+	if err == ErrNotFound {
+		return false, nil
+	}
 	if err != nil {
-		return 0, nil // Start from genesis
+		return false, fmt.Errorf("failed to read metadata %s: %w", key, err)
 	}
-
-	if blockNum, ok := value.(float64); ok {
-		return uint64(blockNum), nil
+	if err := json.Unmarshal(data, dst); err != nil {
+		return false, fmt.Errorf("failed to unmarshal metadata %s: %w", key, err)
 	}
+	return true, nil
+}
 
-	return 0, nil
+// GetLatestBlockNumber retrieves the latest block number
+func (db *BlockchainDB) GetLatestBlockNumber() (uint64, error) {
+	var blockNum uint64
+	found, err := db.getMetadataInto(metaLatestBlock, &blockNum)
+	if err != nil || !found {
+		return 0, err // Start from genesis when no block was stored
+	}
+	return blockNum, nil
 }
 
 // SetLatestBlockNumber sets the latest block number
 func (db *BlockchainDB) SetLatestBlockNumber(blockNumber uint64) error {
-	return db.SaveMetadata("latest_block", blockNumber)
+	return db.SaveMetadata(metaLatestBlock, blockNumber)
 }
 
 // SaveReceipt saves a transaction receipt
-func (db *BlockchainDB) SaveReceipt(txHash string, receipt interface{}) error {
+func (db *BlockchainDB) SaveReceipt(receipt *consensus.Receipt) error {
+	key := fmt.Sprintf("%s%s", PrefixReceipt, receipt.TxHash)
+	return db.putRecord(key, receipt, "receipt")
+}
+
+// GetReceipt retrieves a transaction receipt
+func (db *BlockchainDB) GetReceipt(txHash string) (*consensus.Receipt, error) {
+	key := fmt.Sprintf("%s%s", PrefixReceipt, txHash)
+	receipt := new(consensus.Receipt)
+	if err := db.getRecord(key, receipt, "receipt"); err != nil {
+		return nil, db.checkTxPruned(txHash, err)
+	}
+	return receipt, nil
+}
+
+// GetSupply retrieves the supply breakdown committed with a block
+func (db *BlockchainDB) GetSupply(height uint64) (*consensus.SupplyInfo, error) {
+	if err := db.checkBlockPruned(height); err != nil {
+		return nil, err
+	}
+	info := new(consensus.SupplyInfo)
+	if err := db.getRecord(fmt.Sprintf("%s%d", PrefixSupply, height), info, "supply"); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// SaveMempoolTx journals a pending transaction
+func (db *BlockchainDB) SaveMempoolTx(txHash string, txData interface{}) error {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	key := fmt.Sprintf("%s%s", PrefixReceipt, txHash)
-	data, err := json.Marshal(receipt)
+	key := fmt.Sprintf("%s%s", PrefixMempool, txHash)
+	data, err := json.Marshal(txData)
 	if err != nil {
-		return fmt.Errorf("failed to marshal receipt: %w", err)
+		return fmt.Errorf("failed to marshal mempool transaction: %w", err)
 	}
 
-	return db.db.Put([]byte(key), data, nil)
+	return db.store.Put([]byte(key), data)
 }
 
-// GetReceipt retrieves a transaction receipt
-func (db *BlockchainDB) GetReceipt(txHash string) (map[string]interface{}, error) {
+// DeleteMempoolTx removes a transaction from the mempool journal
+func (db *BlockchainDB) DeleteMempoolTx(txHash string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	key := fmt.Sprintf("%s%s", PrefixMempool, txHash)
+	return db.store.Delete([]byte(key))
+}
+
+// GetMempoolTransactions returns every journaled pending transaction as raw JSON
+func (db *BlockchainDB) GetMempoolTransactions() ([]json.RawMessage, error) {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
-	key := fmt.Sprintf("%s%s", PrefixReceipt, txHash)
-	data, err := db.db.Get([]byte(key), nil)
-	if err != nil {
-		return nil, fmt.Errorf("receipt not found: %w", err)
-	}
+	txs := make([]json.RawMessage, 0)
+	iter := db.store.NewIterator(PrefixRange([]byte(PrefixMempool)))
+	defer iter.Release()
 
-	var receipt map[string]interface{}
-	if err := json.Unmarshal(data, &receipt); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal receipt: %w", err)
+	for iter.Next() {
+		data := make([]byte, len(iter.Value()))
+		copy(data, iter.Value())
+		txs = append(txs, data)
 	}
 
-	return receipt, nil
+	return txs, iter.Error()
 }
 
-// Close closes the database
+// Close stops pruning and closes the database
 func (db *BlockchainDB) Close() error {
+	if db.pruner != nil {
+		db.pruner.close()
+		db.pruner = nil
+	}
+
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	return db.db.Close()
+	return db.store.Close()
 }
 
 // GetStats returns database statistics
@@ -301,5 +393,11 @@ func (db *BlockchainDB) GetStats() (map[string]interface{}, error) {
 	validators, _ := db.GetAllValidators()
 	stats["validator_count"] = len(validators)
 
+	stats["schema_version"], _ = db.SchemaVersion()
+	stats["cache"] = db.CacheStats()
+	stats["prune_mode"] = db.PruneMode()
+	stats["pruned_blocks_below"] = db.blockFloor.Load()
+	stats["pruned_state_below"] = db.stateFloor.Load()
+
 	return stats, nil
 }
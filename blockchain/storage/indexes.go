@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"vnc-blockchain/consensus"
+)
+
+// Secondary index key prefixes. Indexes are derived from stored blocks and
+// can be rebuilt from them at any time.
+const (
+	PrefixIndex        = "idx:"
+	PrefixBlockHash    = PrefixIndex + "blockhash:" // block hash -> block number
+	PrefixTxLocation   = PrefixIndex + "txloc:"     // tx hash -> block number, index
+	PrefixAddressTx    = PrefixIndex + "addrtx:"    // address, block number, index -> tx hash
+	DefaultTxPageLimit = 50
+	MaxTxPageLimit     = 1000
+)
+
+// indexRebuildBatchBlocks is the number of blocks indexed per batch when
+// rebuilding
+const indexRebuildBatchBlocks = 1000
+
+// TxLocation is the position of a transaction in the chain
+type TxLocation struct {
+	Hash        string `json:"hash"`
+	BlockNumber uint64 `json:"block_number"`
+	Index       uint32 `json:"index"`
+}
+
+// AddressTx is a transaction sent from, to or sponsored by an address
+type AddressTx struct {
+	TxLocation
+	Transaction *consensus.Transaction `json:"transaction"`
+}
+
+// AddressTxPage is one page of an address's transactions. NextCursor is
+// empty on the last page.
+type AddressTxPage struct {
+	Transactions []*AddressTx `json:"transactions"`
+	NextCursor   string       `json:"next_cursor,omitempty"`
+}
+
+// GetBlockNumberByHash looks up the number of the block with the given hash
+func (db *BlockchainDB) GetBlockNumberByHash(hash string) (uint64, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	data, err := db.store.Get([]byte(PrefixBlockHash + hash))
+	if err != nil {
+		return 0, fmt.Errorf("block %s not found: %w", hash, err)
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("invalid block hash index for %s", hash)
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// GetBlockByHash retrieves the block with the given hash
+func (db *BlockchainDB) GetBlockByHash(hash string) (*consensus.Block, error) {
+	number, err := db.GetBlockNumberByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetBlock(number)
+}
+
+// GetTxLocation looks up the block and position of a transaction
+func (db *BlockchainDB) GetTxLocation(hash string) (*TxLocation, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	data, err := db.store.Get([]byte(PrefixTxLocation + hash))
+	if err != nil {
+		return nil, fmt.Errorf("transaction %s not found: %w", hash, err)
+	}
+	if len(data) != 12 {
+		return nil, fmt.Errorf("invalid location index for %s", hash)
+	}
+	return &TxLocation{
+		Hash:        hash,
+		BlockNumber: binary.BigEndian.Uint64(data),
+		Index:       binary.BigEndian.Uint32(data[8:]),
+	}, nil
+}
+
+// GetAddressTransactions returns a page of the transactions an address sent,
+// received or sponsored, oldest first or, with newestFirst, newest first.
+// Pass the previous page's NextCursor to continue after it.
+func (db *BlockchainDB) GetAddressTransactions(address, cursor string, limit int, newestFirst bool) (*AddressTxPage, error) {
+	if limit <= 0 {
+		limit = DefaultTxPageLimit
+	}
+	if limit > MaxTxPageLimit {
+		limit = MaxTxPageLimit
+	}
+
+	prefix := addressTxPrefix(address)
+	span := PrefixRange(prefix)
+	if cursor != "" {
+		position, err := hex.DecodeString(cursor)
+		if err != nil || len(position) != 12 {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		// The cursor is the position of the last entry returned
+		after := append(append([]byte(nil), prefix...), position...)
+		if newestFirst {
+			span.Limit = after
+		} else {
+			span.Start = append(after, 0)
+		}
+	}
+
+	db.mutex.RLock()
+	iter := db.store.NewIterator(span)
+	seek, advance := iter.First, iter.Next
+	if newestFirst {
+		seek, advance = iter.Last, iter.Prev
+	}
+
+	var locations []TxLocation
+	more := false
+	for ok := seek(); ok; ok = advance() {
+		if len(locations) == limit {
+			more = true
+			break
+		}
+		key := iter.Key()[len(prefix):]
+		if len(key) != 12 {
+			continue
+		}
+		locations = append(locations, TxLocation{
+			Hash:        string(iter.Value()),
+			BlockNumber: binary.BigEndian.Uint64(key),
+			Index:       binary.BigEndian.Uint32(key[8:]),
+		})
+	}
+	iter.Release()
+	err := iter.Error()
+	db.mutex.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read address index: %w", err)
+	}
+
+	page := &AddressTxPage{Transactions: make([]*AddressTx, 0, len(locations))}
+	for _, location := range locations {
+		tx, err := db.GetTransaction(location.Hash)
+		if err != nil {
+			return nil, err
+		}
+		page.Transactions = append(page.Transactions, &AddressTx{TxLocation: location, Transaction: tx})
+	}
+	if more {
+		last := locations[len(locations)-1]
+		page.NextCursor = hex.EncodeToString(txPosition(last.BlockNumber, last.Index))
+	}
+	return page, nil
+}
+
+// HasIndexes reports whether the head block is indexed. Databases written
+// before indexes were maintained need RebuildIndexes.
+func (db *BlockchainDB) HasIndexes() (bool, error) {
+	head, err := db.GetLatestBlockNumber()
+	if err != nil {
+		return false, err
+	}
+	block, err := db.GetBlock(head)
+	if err != nil {
+		return true, nil // Nothing stored to index
+	}
+	number, err := db.GetBlockNumberByHash(block.Hash)
+	return err == nil && number == head, nil
+}
+
+// RebuildIndexes drops every secondary index and rebuilds them from the
+// stored blocks up to the head. It returns the number of blocks indexed.
+// Entries of pruned blocks are not restored.
+func (db *BlockchainDB) RebuildIndexes(onProgress func(height uint64)) (uint64, error) {
+	head, err := db.GetLatestBlockNumber()
+	if err != nil {
+		return 0, err
+	}
+	if err := db.dropIndexes(); err != nil {
+		return 0, err
+	}
+
+	indexed := uint64(0)
+	batch := new(Batch)
+	for number := db.blockFloor.Load(); number <= head; number++ {
+		block, err := db.scanBlock(number)
+		if err != nil {
+			if number == 0 {
+				continue // Genesis is not stored
+			}
+			return indexed, err
+		}
+		indexBlock(batch, block)
+		indexed++
+
+		if number%indexRebuildBatchBlocks == 0 || number == head {
+			if err := db.writeBatch(batch); err != nil {
+				return indexed, fmt.Errorf("failed to write indexes: %w", err)
+			}
+			batch.Reset()
+			if onProgress != nil {
+				onProgress(number)
+			}
+		}
+	}
+	return indexed, nil
+}
+
+// dropIndexes deletes every secondary index entry
+func (db *BlockchainDB) dropIndexes() error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	batch := new(Batch)
+	iter := db.store.NewIterator(PrefixRange([]byte(PrefixIndex)))
+	for iter.Next() {
+		batch.Delete(iter.Key())
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("failed to read indexes: %w", err)
+	}
+	if err := db.write(batch, false); err != nil {
+		return fmt.Errorf("failed to drop indexes: %w", err)
+	}
+	return nil
+}
+
+// writeBatch writes a batch under the database lock
+func (db *BlockchainDB) writeBatch(batch *Batch) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return db.write(batch, false)
+}
+
+// indexBlock adds the index entries of a block to a batch
+func indexBlock(batch *Batch, block *consensus.Block) {
+	number := make([]byte, 8)
+	binary.BigEndian.PutUint64(number, block.Number)
+	batch.Put([]byte(PrefixBlockHash+block.Hash), number)
+
+	for i, tx := range block.Transactions {
+		position := txPosition(block.Number, uint32(i))
+		batch.Put([]byte(PrefixTxLocation+tx.Hash), position)
+		for _, address := range txAddresses(tx) {
+			batch.Put(append(addressTxPrefix(address), position...), []byte(tx.Hash))
+		}
+	}
+}
+
+// unindexBlock adds deletions of the index entries of a block to a batch
+func unindexBlock(batch *Batch, block *consensus.Block) {
+	batch.Delete([]byte(PrefixBlockHash + block.Hash))
+	for i, tx := range block.Transactions {
+		position := txPosition(block.Number, uint32(i))
+		batch.Delete([]byte(PrefixTxLocation + tx.Hash))
+		for _, address := range txAddresses(tx) {
+			batch.Delete(append(addressTxPrefix(address), position...))
+		}
+	}
+}
+
+// txAddresses returns the addresses a transaction is listed under
+func txAddresses(tx *consensus.Transaction) []string {
+	addresses := []string{tx.From}
+	if tx.To != "" && tx.To != tx.From {
+		addresses = append(addresses, tx.To)
+	}
+	if tx.FeePayer != "" && tx.FeePayer != tx.From && tx.FeePayer != tx.To {
+		addresses = append(addresses, tx.FeePayer)
+	}
+	return addresses
+}
+
+// addressTxPrefix is the key prefix of an address's entries. The address is
+// terminated so no address prefixes another.
+func addressTxPrefix(address string) []byte {
+	return []byte(PrefixAddressTx + address + "\x00")
+}
+
+// txPosition encodes a block number and index so keys sort chronologically
+func txPosition(blockNumber uint64, index uint32) []byte {
+	position := make([]byte, 12)
+	binary.BigEndian.PutUint64(position, blockNumber)
+	binary.BigEndian.PutUint32(position[8:], index)
+	return position
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRebuildIndexes(t *testing.T) {
+	db := openMemoryDB(t)
+	if err := commitTestChain(db, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.dropIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	if indexed, err := db.HasIndexes(); err != nil || indexed {
+		t.Fatalf("head reported indexed after the indexes were dropped (%v)", err)
+	}
+	if _, err := db.GetBlockByHash("0xblock2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("block by hash without indexes returned %v, want ErrNotFound", err)
+	}
+
+	// A stale entry, as left by an index of a rewound block, is dropped
+	if err := db.store.Put([]byte(PrefixBlockHash+"0xstale"), make([]byte, 8)); err != nil {
+		t.Fatal(err)
+	}
+
+	var progress []uint64
+	indexed, err := db.RebuildIndexes(func(height uint64) { progress = append(progress, height) })
+	if err != nil || indexed != 3 {
+		t.Fatalf("rebuilt %d blocks (%v), want 3", indexed, err)
+	}
+	if len(progress) != 1 || progress[0] != 3 {
+		t.Fatalf("progress reported at %v, want once at the head", progress)
+	}
+	if ok, err := db.HasIndexes(); err != nil || !ok {
+		t.Fatalf("head not indexed after the rebuild (%v)", err)
+	}
+	if block, err := db.GetBlockByHash("0xblock2"); err != nil || block.Number != 2 {
+		t.Fatalf("block by hash after the rebuild: %+v (%v)", block, err)
+	}
+	if location, err := db.GetTxLocation("0xtx3_1"); err != nil || location.BlockNumber != 3 || location.Index != 1 {
+		t.Fatalf("transaction location after the rebuild: %+v (%v)", location, err)
+	}
+	if page, err := db.GetAddressTransactions("0xrecipient1", "", 0, false); err != nil || len(page.Transactions) != 3 {
+		t.Fatalf("recipient history after the rebuild: %+v (%v)", page, err)
+	}
+	if _, err := db.GetBlockNumberByHash("0xstale"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("stale index entry survived the rebuild: %v", err)
+	}
+}
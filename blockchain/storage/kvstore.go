@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Storage backends
+const (
+	BackendLevelDB = "leveldb" // on-disk LevelDB, the default
+	BackendMemory  = "memory"  // in-memory, for tests and throwaway nodes
+)
+
+var (
+	// ErrNotFound is returned by Get when a key does not exist
+	ErrNotFound = errors.New("key not found")
+	// ErrClosed is returned by operations on a closed store
+	ErrClosed = errors.New("store closed")
+	// ErrReadOnly is returned by writes to a store opened read-only
+	ErrReadOnly = errors.New("store is read-only")
+)
+
+// KVReader reads keys and ranges of keys
+type KVReader interface {
+	// Get returns a copy of the value of key, or ErrNotFound
+	Get(key []byte) ([]byte, error)
+	// NewIterator iterates over a consistent view of the keys in r, in
+	// ascending byte order. A nil range covers every key.
+	NewIterator(r *Range) Iterator
+}
+
+// KVStore is an ordered key-value store the blockchain database runs on.
+// Implementations must be safe for concurrent use and pass the conformance tests.
+type KVStore interface {
+	KVReader
+	Put(key, value []byte) error
+	// Delete removes key; deleting a missing key is not an error
+	Delete(key []byte) error
+	// Write applies every operation in a batch atomically. With sync, the
+	// batch is durable when Write returns.
+	Write(batch *Batch, sync bool) error
+	// Snapshot returns a consistent read-only view of the current contents
+	Snapshot() (KVSnapshot, error)
+	Close() error
+}
+
+// KVSnapshot is a point-in-time view of a store. It must be released.
+type KVSnapshot interface {
+	KVReader
+	Release()
+}
+
+// Iterator walks keys in order. A new iterator is positioned before the
+// first key, so Next moves to the first key and Prev to none; First and
+// Last seek directly. Key and Value are valid until the iterator moves.
+type Iterator interface {
+	First() bool
+	Last() bool
+	Next() bool
+	Prev() bool
+	Key() []byte
+	Value() []byte
+	Release()
+	Error() error
+}
+
+// Range is the key range [Start, Limit). A nil Start or Limit is unbounded.
+type Range struct {
+	Start []byte
+	Limit []byte
+}
+
+// PrefixRange returns the range of keys starting with prefix
+func PrefixRange(prefix []byte) *Range {
+	var limit []byte
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			limit = make([]byte, i+1)
+			copy(limit, prefix)
+			limit[i]++
+			break
+		}
+	}
+	return &Range{Start: append([]byte(nil), prefix...), Limit: limit}
+}
+
+// batchOp is one write in a batch; a nil value is a delete
+type batchOp struct {
+	key   []byte
+	value []byte
+}
+
+// Batch collects writes to apply atomically with KVStore.Write
+type Batch struct {
+	ops []batchOp
+}
+
+// Put adds a write of key to the batch
+func (b *Batch) Put(key, value []byte) {
+	b.ops = append(b.ops, batchOp{
+		key:   append([]byte(nil), key...),
+		value: append([]byte{}, value...),
+	})
+}
+
+// Delete adds a deletion of key to the batch
+func (b *Batch) Delete(key []byte) {
+	b.ops = append(b.ops, batchOp{key: append([]byte(nil), key...)})
+}
+
+// Len returns the number of operations in the batch
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Reset empties the batch
+func (b *Batch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+// Replay calls put or del for each operation, in order
+func (b *Batch) Replay(put func(key, value []byte), del func(key []byte)) {
+	for _, op := range b.ops {
+		if op.value == nil {
+			del(op.key)
+		} else {
+			put(op.key, op.value)
+		}
+	}
+}
+
+// Config selects and locates the storage backend and sets how much
+// history it keeps
+type Config struct {
+	Backend  string // BackendLevelDB when empty
+	DataDir  string // database directory of on-disk backends
+	ReadOnly bool   // open an existing database without writing to it
+
+	PruneMode    PruneMode // PruneArchive when empty
+	RetainBlocks uint64    // blocks kept by pruning; DefaultRetainBlocks when 0
+
+	CacheSize int // byte budget of the record cache; DefaultCacheSize when 0
+}
+
+// OpenKVStore opens the backend selected by cfg
+func OpenKVStore(cfg Config) (KVStore, error) {
+	switch cfg.Backend {
+	case "", BackendLevelDB:
+		return OpenLevelDBStore(cfg.DataDir, cfg.ReadOnly)
+	case BackendMemory:
+		if cfg.ReadOnly {
+			return nil, fmt.Errorf("memory backend cannot be opened read-only")
+		}
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// LevelDBStore is a KVStore backed by goleveldb
+type LevelDBStore struct {
+	db *leveldb.DB
+}
+
+// OpenLevelDBStore opens or creates a LevelDB database in dataDir. A
+// read-only store must already exist.
+func OpenLevelDBStore(dataDir string, readOnly bool) (*LevelDBStore, error) {
+	var options *opt.Options
+	if readOnly {
+		options = &opt.Options{ReadOnly: true, ErrorIfMissing: true}
+	}
+	db, err := leveldb.OpenFile(dataDir, options)
+	if err != nil {
+		return nil, err
+	}
+	return &LevelDBStore{db: db}, nil
+}
+
+// Get returns the value of key
+func (s *LevelDBStore) Get(key []byte) ([]byte, error) {
+	value, err := s.db.Get(key, nil)
+	return value, levelDBError(err)
+}
+
+// Put stores value under key
+func (s *LevelDBStore) Put(key, value []byte) error {
+	return levelDBError(s.db.Put(key, value, nil))
+}
+
+// Delete removes key
+func (s *LevelDBStore) Delete(key []byte) error {
+	return levelDBError(s.db.Delete(key, nil))
+}
+
+// Write applies a batch atomically
+func (s *LevelDBStore) Write(batch *Batch, sync bool) error {
+	var options *opt.WriteOptions
+	if sync {
+		options = &opt.WriteOptions{Sync: true}
+	}
+	return levelDBError(s.db.Write(levelDBBatch(batch), options))
+}
+
+// NewIterator iterates over the keys in r
+func (s *LevelDBStore) NewIterator(r *Range) Iterator {
+	return s.db.NewIterator(levelDBRange(r), nil)
+}
+
+// Snapshot returns a point-in-time view of the database
+func (s *LevelDBStore) Snapshot() (KVSnapshot, error) {
+	snapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		return nil, levelDBError(err)
+	}
+	return &levelDBSnapshot{snapshot: snapshot}, nil
+}
+
+// Close closes the database
+func (s *LevelDBStore) Close() error {
+	return levelDBError(s.db.Close())
+}
+
+// levelDBSnapshot adapts a LevelDB snapshot to KVSnapshot
+type levelDBSnapshot struct {
+	snapshot *leveldb.Snapshot
+}
+
+func (s *levelDBSnapshot) Get(key []byte) ([]byte, error) {
+	value, err := s.snapshot.Get(key, nil)
+	return value, levelDBError(err)
+}
+
+func (s *levelDBSnapshot) NewIterator(r *Range) Iterator {
+	return s.snapshot.NewIterator(levelDBRange(r), nil)
+}
+
+func (s *levelDBSnapshot) Release() {
+	s.snapshot.Release()
+}
+
+// levelDBBatch converts a batch to its LevelDB form
+func levelDBBatch(batch *Batch) *leveldb.Batch {
+	converted := new(leveldb.Batch)
+	batch.Replay(converted.Put, converted.Delete)
+	return converted
+}
+
+// levelDBRange converts a range to its LevelDB form
+func levelDBRange(r *Range) *util.Range {
+	if r == nil {
+		return nil
+	}
+	return &util.Range{Start: r.Start, Limit: r.Limit}
+}
+
+// levelDBError maps LevelDB errors to the KVStore ones
+func levelDBError(err error) error {
+	switch err {
+	case leveldb.ErrNotFound:
+		return ErrNotFound
+	case leveldb.ErrClosed, leveldb.ErrSnapshotReleased:
+		return ErrClosed
+	case leveldb.ErrReadOnly:
+		return ErrReadOnly
+	}
+	return err
+}
+
+// LevelDB iterators satisfy Iterator directly
+var _ Iterator = iterator.Iterator(nil)
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a KVStore held in memory. Nothing survives Close.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	values map[string][]byte
+	keys   []string // sorted
+	closed bool
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{values: make(map[string][]byte)}
+}
+
+// Get returns a copy of the value of key
+func (s *MemoryStore) Get(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+	value, exists := s.values[string(key)]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+// Put stores a copy of value under key
+func (s *MemoryStore) Put(key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	s.put(string(key), append([]byte{}, value...))
+	return nil
+}
+
+// Delete removes key
+func (s *MemoryStore) Delete(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	s.delete(string(key))
+	return nil
+}
+
+// Write applies a batch atomically. Memory writes are never durable, so
+// sync has no effect.
+func (s *MemoryStore) Write(batch *Batch, sync bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	batch.Replay(
+		func(key, value []byte) { s.put(string(key), append([]byte{}, value...)) },
+		func(key []byte) { s.delete(string(key)) },
+	)
+	return nil
+}
+
+// NewIterator iterates over the keys in r as they are now
+func (s *MemoryStore) NewIterator(r *Range) Iterator {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return &memoryIterator{pos: -1, err: ErrClosed}
+	}
+	return newMemoryIterator(s.keys, s.values, r)
+}
+
+// Snapshot copies the current contents
+func (s *MemoryStore) Snapshot() (KVSnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+	snapshot := &memorySnapshot{
+		values: make(map[string][]byte, len(s.values)),
+		keys:   append([]string(nil), s.keys...),
+	}
+	for key, value := range s.values {
+		snapshot.values[key] = value // Values are never modified in place
+	}
+	return snapshot, nil
+}
+
+// Close discards the contents
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	s.closed = true
+	s.values = nil
+	s.keys = nil
+	return nil
+}
+
+// put stores value under key. Caller must hold s.mu.
+func (s *MemoryStore) put(key string, value []byte) {
+	if _, exists := s.values[key]; !exists {
+		i := sort.SearchStrings(s.keys, key)
+		s.keys = append(s.keys, "")
+		copy(s.keys[i+1:], s.keys[i:])
+		s.keys[i] = key
+	}
+	s.values[key] = value
+}
+
+// delete removes key. Caller must hold s.mu.
+func (s *MemoryStore) delete(key string) {
+	if _, exists := s.values[key]; !exists {
+		return
+	}
+	delete(s.values, key)
+	i := sort.SearchStrings(s.keys, key)
+	s.keys = append(s.keys[:i], s.keys[i+1:]...)
+}
+
+// memorySnapshot is a copy of a memory store's contents
+type memorySnapshot struct {
+	mu       sync.RWMutex
+	values   map[string][]byte
+	keys     []string
+	released bool
+}
+
+func (s *memorySnapshot) Get(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.released {
+		return nil, ErrClosed
+	}
+	value, exists := s.values[string(key)]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+func (s *memorySnapshot) NewIterator(r *Range) Iterator {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.released {
+		return &memoryIterator{pos: -1, err: ErrClosed}
+	}
+	return newMemoryIterator(s.keys, s.values, r)
+}
+
+func (s *memorySnapshot) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.released = true
+	s.values = nil
+	s.keys = nil
+}
+
+// memoryIterator walks a copy of the keys in a range
+type memoryIterator struct {
+	keys   []string
+	values [][]byte
+	pos    int // -1 before the first key, len(keys) after the last
+	err    error
+}
+
+// newMemoryIterator copies the entries of keys in r
+func newMemoryIterator(keys []string, values map[string][]byte, r *Range) *memoryIterator {
+	start, end := 0, len(keys)
+	if r != nil && r.Start != nil {
+		start = sort.SearchStrings(keys, string(r.Start))
+	}
+	if r != nil && r.Limit != nil {
+		end = sort.SearchStrings(keys, string(r.Limit))
+	}
+	if end < start {
+		end = start
+	}
+
+	iter := &memoryIterator{keys: append([]string(nil), keys[start:end]...), pos: -1}
+	iter.values = make([][]byte, len(iter.keys))
+	for i, key := range iter.keys {
+		iter.values[i] = values[key]
+	}
+	return iter
+}
+
+func (i *memoryIterator) First() bool {
+	i.pos = 0
+	return i.valid()
+}
+
+func (i *memoryIterator) Last() bool {
+	i.pos = len(i.keys) - 1
+	return i.valid()
+}
+
+func (i *memoryIterator) Next() bool {
+	if i.pos < len(i.keys) {
+		i.pos++
+	}
+	return i.valid()
+}
+
+func (i *memoryIterator) Prev() bool {
+	switch {
+	case i.pos == len(i.keys):
+		i.pos = len(i.keys) - 1 // Prev after the end moves to the last key
+	case i.pos >= 0:
+		i.pos--
+	}
+	return i.valid()
+}
+
+func (i *memoryIterator) Key() []byte {
+	if !i.valid() {
+		return nil
+	}
+	return []byte(i.keys[i.pos])
+}
+
+func (i *memoryIterator) Value() []byte {
+	if !i.valid() {
+		return nil
+	}
+	return i.values[i.pos]
+}
+
+func (i *memoryIterator) Release() {
+	i.keys = nil
+	i.values = nil
+	i.pos = -1
+}
+
+func (i *memoryIterator) Error() error {
+	return i.err
+}
+
+// valid reports whether the iterator is at a key
+func (i *memoryIterator) valid() bool {
+	return i.err == nil && i.pos >= 0 && i.pos < len(i.keys)
+}
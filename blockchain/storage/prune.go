@@ -0,0 +1,412 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	"vnc-blockchain/consensus"
+)
+
+// PruneMode selects how much history the database keeps
+type PruneMode string
+
+// Pruning modes. Data pruned under one mode is not restored by switching to
+// another.
+const (
+	PruneArchive PruneMode = "archive" // every block and all historical state
+	PruneFull    PruneMode = "full"    // every block, state for the recent blocks
+	PrunePruned  PruneMode = "pruned"  // the recent blocks and their state
+)
+
+// DefaultRetainBlocks is the number of recent blocks whose data pruning keeps
+const DefaultRetainBlocks = 10000
+
+// PrefixStateHistory holds account values by address and height
+const PrefixStateHistory = "statehist:"
+
+// Metadata keys of the pruned ranges
+const (
+	metaPrunedBlocksBelow = "pruned_blocks_below"
+	metaPrunedStateBelow  = "pruned_state_below"
+)
+
+const (
+	pruneBatchBlocks  = 100  // blocks deleted per batch
+	pruneBatchEntries = 1000 // state history entries deleted per batch
+)
+
+// ErrPruned is returned for data the pruning mode has deleted
+var ErrPruned = errors.New("pruned")
+
+// validPruneMode reports whether mode is known; empty means archive
+func validPruneMode(mode PruneMode) bool {
+	switch mode {
+	case "", PruneArchive, PruneFull, PrunePruned:
+		return true
+	}
+	return false
+}
+
+// pruner deletes data that fell out of the retained range, in the
+// background. Commits only signal it, so they never wait for pruning.
+type pruner struct {
+	db     *BlockchainDB
+	mode   PruneMode
+	retain uint64
+	mu     sync.Mutex // one pruning pass at a time
+	wake   chan uint64
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// startPruner runs the pruner for a mode that prunes
+func (db *BlockchainDB) startPruner(mode PruneMode, retain uint64) {
+	if retain == 0 {
+		retain = DefaultRetainBlocks
+	}
+	db.pruner = &pruner{
+		db:     db,
+		mode:   mode,
+		retain: retain,
+		wake:   make(chan uint64, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go db.pruner.run()
+
+	if head, err := db.GetLatestBlockNumber(); err == nil {
+		db.pruner.notify(head)
+	}
+}
+
+// run prunes after each signalled commit until stopped
+func (p *pruner) run() {
+	defer close(p.done)
+	for {
+		select {
+		case <-p.stop:
+			return
+		case head := <-p.wake:
+			if err := p.prune(head, false); err != nil {
+				fmt.Printf("⚠️  Pruning failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// notify signals a new head without blocking. A pending signal is replaced,
+// since only the newest head matters.
+func (p *pruner) notify(head uint64) {
+	select {
+	case <-p.wake:
+	default:
+	}
+	select {
+	case p.wake <- head:
+	default:
+	}
+}
+
+// close stops the pruner and waits for the pass in progress
+func (p *pruner) close() {
+	close(p.stop)
+	<-p.done
+}
+
+// stopped reports whether the pruner is shutting down
+func (p *pruner) stopped() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// prune deletes what falls below the retained range for head. Unless
+// forced, state history is pruned once a batch of heights has accumulated,
+// as the scan is proportional to the retained state.
+func (p *pruner) prune(head uint64, force bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if head < p.retain {
+		return nil
+	}
+	keepFrom := head - p.retain + 1
+
+	if p.mode == PrunePruned {
+		// Blocks are only pruned below a checkpoint replay can start from
+		base, superseded, err := p.db.nextSnapshotBase(keepFrom)
+		if err != nil {
+			return err
+		}
+		if base != nil {
+			if err := p.pruneBlocks(min(keepFrom, base.Height), base, superseded); err != nil {
+				return err
+			}
+		}
+	}
+	floor := p.db.stateFloor.Load()
+	if keepFrom > floor && (force || keepFrom >= floor+pruneBatchBlocks) {
+		return p.pruneState(keepFrom)
+	}
+	return nil
+}
+
+// pruneBlocks deletes blocks below keepFrom with their transactions,
+// receipts, supply records and address history entries. Block hash and
+// transaction location entries are kept so lookups of pruned data can
+// report it as pruned. The first batch also makes base, a checkpoint at or
+// above keepFrom, the snapshot base and deletes the checkpoints it
+// supersedes, so a restart replays from it rather than from genesis.
+func (p *pruner) pruneBlocks(keepFrom uint64, base *consensus.ReplayCheckpoint, superseded [][]byte) error {
+	for from := p.db.blockFloor.Load(); from < keepFrom; {
+		if p.stopped() {
+			return nil
+		}
+		to := from + pruneBatchBlocks
+		if to > keepFrom {
+			to = keepFrom
+		}
+
+		batch := new(Batch)
+		if base != nil {
+			data, err := json.Marshal(base)
+			if err != nil {
+				return fmt.Errorf("failed to marshal snapshot base: %w", err)
+			}
+			batch.Put([]byte(PrefixMetadata+metaSnapshotBase), data)
+			for _, key := range superseded {
+				batch.Delete(key)
+			}
+			base = nil
+		}
+		for number := from; number < to; number++ {
+			block, err := p.db.scanBlock(number)
+			if err != nil {
+				continue // Never stored, or genesis
+			}
+			for i, tx := range block.Transactions {
+				batch.Delete([]byte(PrefixTransaction + tx.Hash))
+				batch.Delete([]byte(PrefixReceipt + tx.Hash))
+				position := txPosition(number, uint32(i))
+				for _, address := range txAddresses(tx) {
+					batch.Delete(append(addressTxPrefix(address), position...))
+				}
+			}
+			batch.Delete([]byte(fmt.Sprintf("%s%d", PrefixSupply, number)))
+			batch.Delete([]byte(fmt.Sprintf("%s%d", PrefixBlock, number)))
+		}
+		if err := putBatchMetadata(batch, metaPrunedBlocksBelow, to); err != nil {
+			return err
+		}
+
+		// Readers see the data as pruned before it disappears
+		p.db.blockFloor.Store(to)
+		if err := p.db.writeBatch(batch); err != nil {
+			return fmt.Errorf("failed to prune blocks #%d-#%d: %w", from, to-1, err)
+		}
+		from = to
+	}
+	return nil
+}
+
+// pruneState deletes account history below keepFrom. For each address the
+// newest entry at or below keepFrom is kept, as it holds the value at
+// keepFrom.
+func (p *pruner) pruneState(keepFrom uint64) error {
+	p.db.stateFloor.Store(keepFrom)
+
+	batch := new(Batch)
+	iter := p.db.store.NewIterator(PrefixRange([]byte(PrefixStateHistory)))
+	var previous []byte // newest entry at or below keepFrom of the current address
+	for iter.Next() {
+		address, height, ok := parseStateHistoryKey(iter.Key())
+		if !ok {
+			continue
+		}
+		if previous != nil {
+			if prevAddress, _, _ := parseStateHistoryKey(previous); prevAddress != address {
+				previous = nil
+			}
+		}
+		if height > keepFrom {
+			continue
+		}
+		if previous != nil {
+			batch.Delete(previous)
+		}
+		previous = append(previous[:0:0], iter.Key()...)
+
+		if batch.Len() >= pruneBatchEntries {
+			if p.stopped() {
+				break
+			}
+			if err := p.db.writeBatch(batch); err != nil {
+				iter.Release()
+				return fmt.Errorf("failed to prune state history: %w", err)
+			}
+			batch.Reset()
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("failed to read state history: %w", err)
+	}
+	if err := putBatchMetadata(batch, metaPrunedStateBelow, keepFrom); err != nil {
+		return err
+	}
+	if err := p.db.writeBatch(batch); err != nil {
+		return fmt.Errorf("failed to prune state history: %w", err)
+	}
+	return nil
+}
+
+// Prune runs a pruning pass for the current head and waits for it. The
+// node prunes in the background; this is for tools and checks.
+func (db *BlockchainDB) Prune() error {
+	if db.pruner == nil {
+		return nil
+	}
+	head, err := db.GetLatestBlockNumber()
+	if err != nil {
+		return err
+	}
+	return db.pruner.prune(head, true)
+}
+
+// PruneMode returns the pruning mode the database was opened with
+func (db *BlockchainDB) PruneMode() PruneMode {
+	if db.pruner == nil {
+		return PruneArchive
+	}
+	return db.pruner.mode
+}
+
+// loadPruneFloors reads the pruned ranges from metadata
+func (db *BlockchainDB) loadPruneFloors() error {
+	for _, floor := range []struct {
+		key   string
+		value *atomic.Uint64
+	}{
+		{metaPrunedBlocksBelow, &db.blockFloor},
+		{metaPrunedStateBelow, &db.stateFloor},
+	} {
+		var below uint64
+		if _, err := db.getMetadataInto(floor.key, &below); err != nil {
+			return err
+		}
+		floor.value.Store(below)
+	}
+	return nil
+}
+
+// checkBlockPruned returns an ErrPruned error if a block was pruned
+func (db *BlockchainDB) checkBlockPruned(number uint64) error {
+	if floor := db.blockFloor.Load(); number > 0 && number < floor {
+		return fmt.Errorf("%w: block #%d is below the retained range starting at #%d", ErrPruned, number, floor)
+	}
+	return nil
+}
+
+// checkTxPruned explains a missing transaction or receipt whose block was pruned
+func (db *BlockchainDB) checkTxPruned(txHash string, err error) error {
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	location, locErr := db.GetTxLocation(txHash)
+	if locErr != nil {
+		return err
+	}
+	if pruneErr := db.checkBlockPruned(location.BlockNumber); pruneErr != nil {
+		return pruneErr
+	}
+	return err
+}
+
+// GetAccountAt returns an account as it was after the block at height
+func (db *BlockchainDB) GetAccountAt(address string, height uint64) (*consensus.Account, error) {
+	if floor := db.stateFloor.Load(); height < floor {
+		return nil, fmt.Errorf("%w: state at block #%d is below the retained range starting at #%d", ErrPruned, height, floor)
+	}
+
+	prefix := stateHistoryPrefix(address)
+	iter := db.store.NewIterator(&Range{Start: prefix, Limit: stateHistoryKey(address, height+1)})
+	defer iter.Release()
+
+	if !iter.Last() {
+		if err := iter.Error(); err != nil {
+			return nil, err
+		}
+		return &consensus.Account{Address: address, Balance: new(big.Int)}, nil
+	}
+	account := new(consensus.Account)
+	if err := decodeRecord(iter.Value(), account); err != nil {
+		return nil, fmt.Errorf("failed to decode account: %w", err)
+	}
+	return account, nil
+}
+
+// readBlock loads a block without the pruned check, through the cache
+func (db *BlockchainDB) readBlock(number uint64) (*consensus.Block, error) {
+	if block, ok := db.cache.blocks.get(number); ok {
+		return block, nil
+	}
+
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	block := new(consensus.Block)
+	size, err := db.loadRecord(fmt.Sprintf("%s%d", PrefixBlock, number), block, "block")
+	if err != nil {
+		return nil, err
+	}
+	db.cache.blocks.add(number, block, size)
+	return block, nil
+}
+
+// scanBlock loads a block around the cache, for walks over the whole chain
+// that would otherwise evict the recent blocks
+func (db *BlockchainDB) scanBlock(number uint64) (*consensus.Block, error) {
+	block := new(consensus.Block)
+	if err := db.getRecord(fmt.Sprintf("%s%d", PrefixBlock, number), block, "block"); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// putBatchMetadata adds a JSON metadata write to a batch
+func putBatchMetadata(batch *Batch, key string, value uint64) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	batch.Put([]byte(PrefixMetadata+key), data)
+	return nil
+}
+
+// stateHistoryPrefix is the key prefix of an address's history entries
+func stateHistoryPrefix(address string) []byte {
+	return []byte(PrefixStateHistory + address + "\x00")
+}
+
+// stateHistoryKey is the key of an account's value after a block
+func stateHistoryKey(address string, height uint64) []byte {
+	key := stateHistoryPrefix(address)
+	return binary.BigEndian.AppendUint64(key, height)
+}
+
+// parseStateHistoryKey splits a history key into address and height
+func parseStateHistoryKey(key []byte) (string, uint64, bool) {
+	rest := key[len(PrefixStateHistory):]
+	if len(rest) < 9 || rest[len(rest)-9] != 0 {
+		return "", 0, false
+	}
+	return string(rest[:len(rest)-9]), binary.BigEndian.Uint64(rest[len(rest)-8:]), true
+}
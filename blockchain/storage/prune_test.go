@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"vnc-blockchain/consensus"
+)
+
+// commitReplayableChain commits blocks 1..blocks, each holding a transfer
+// from one account, with the roots a replay of the chain computes
+func commitReplayableChain(t *testing.T, db *BlockchainDB, blocks uint64) {
+	t.Helper()
+	producer := consensus.NewDPoSBFT(consensus.Config{})
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	parent := consensus.GenesisHeader()
+	for number := uint64(1); number <= blocks; number++ {
+		tx := &consensus.Transaction{
+			Type:     consensus.TxTransfer,
+			From:     consensus.AddressOf(key),
+			To:       "0xrecipient",
+			Value:    big.NewInt(0),
+			GasPrice: big.NewInt(0),
+			Nonce:    number - 1,
+		}
+		consensus.SignTransaction(tx, key)
+		block := &consensus.Block{
+			Number:       number,
+			Hash:         fmt.Sprintf("0xblock%d", number),
+			PreviousHash: parent.Hash,
+			Timestamp:    int64(number),
+			Transactions: []*consensus.Transaction{tx},
+		}
+
+		// Replaying the block without its roots reports them: first the
+		// transaction root, then, once executed, the state root
+		for _, field := range []string{"tx_root", "state_root"} {
+			divergence := producer.ReplayBlock(block)
+			if divergence == nil || divergence.Field != field {
+				t.Fatalf("block #%d: replay reported %v, want a %s mismatch", number, divergence, field)
+			}
+			if field == "tx_root" {
+				block.TxRoot = divergence.Actual
+			} else {
+				block.StateRoot = divergence.Actual
+			}
+		}
+
+		if err := db.CommitBlock(block, nil, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		parent = block
+	}
+}
+
+func TestPrunedNodeReplaysFromCheckpoint(t *testing.T) {
+	cfg := Config{Backend: BackendLevelDB, DataDir: t.TempDir(), PruneMode: PrunePruned, RetainBlocks: 5}
+	db, err := OpenBlockchainDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitReplayableChain(t, db, 20)
+
+	// Checkpoints are saved as a node saves them while committing
+	synced, err := consensus.NewDPoSBFT(consensus.Config{}).VerifyChain(db, consensus.VerifyOptions{
+		CheckpointInterval: db.CheckpointInterval(),
+		OnCheckpoint:       db.SaveCheckpoint,
+	})
+	if err != nil || synced.Divergence != nil {
+		t.Fatalf("chain does not replay: %v %v", err, synced.Divergence)
+	}
+	if err := db.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = OpenBlockchainDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.GetBlock(1); !errors.Is(err, ErrPruned) {
+		t.Fatalf("block #1 returned %v, want ErrPruned", err)
+	}
+
+	// The restart replay of main.go
+	base, err := db.GetSnapshotBase()
+	if err != nil || base == nil {
+		t.Fatalf("no snapshot base after pruning: %v", err)
+	}
+	replay, err := consensus.NewDPoSBFT(consensus.Config{}).VerifyChain(db, consensus.VerifyOptions{Resume: base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Divergence != nil {
+		t.Fatalf("replay after restart diverged: %s", replay.Divergence)
+	}
+	if replay.From != base.Height+1 || replay.To != 20 || replay.StateRoot != synced.StateRoot {
+		t.Fatalf("replayed #%d-#%d to root %s, want #%d-#20 to root %s",
+			replay.From, replay.To, replay.StateRoot, base.Height+1, synced.StateRoot)
+	}
+}
+
+func TestPrunedReads(t *testing.T) {
+	for _, mode := range []PruneMode{PruneFull, PrunePruned} {
+		db, err := OpenBlockchainDB(Config{Backend: BackendMemory, PruneMode: mode, RetainBlocks: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if err := commitTestChain(db, 5); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SaveCheckpoint(&consensus.ReplayCheckpoint{Height: 4, BlockHash: "0xblock4"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Prune(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Both modes drop the state below the retained range
+		if _, err := db.GetAccountAt("0xsender", 3); !errors.Is(err, ErrPruned) {
+			t.Fatalf("%s: state at #3 returned %v, want ErrPruned", mode, err)
+		}
+		if account, err := db.GetAccountAt("0xsender", 4); err != nil || account.Balance.Int64() != 96 {
+			t.Fatalf("%s: retained state: %+v (%v)", mode, account, err)
+		}
+
+		// Only pruned mode drops blocks, and what was stored with them
+		reads := map[string]func() error{
+			"block":       func() error { _, err := db.GetBlock(2); return err },
+			"header":      func() error { _, err := db.GetHeader(2); return err },
+			"by hash":     func() error { _, err := db.GetBlockByHash("0xblock2"); return err },
+			"transaction": func() error { _, err := db.GetTransaction("0xtx2_1"); return err },
+			"receipt":     func() error { _, err := db.GetReceipt("0xtx2_1"); return err },
+		}
+		for name, read := range reads {
+			err := read()
+			if mode == PruneFull && err != nil {
+				t.Fatalf("%s: %s of block #2: %v", mode, name, err)
+			}
+			if mode == PrunePruned && !errors.Is(err, ErrPruned) {
+				t.Fatalf("%s: %s of block #2 returned %v, want ErrPruned", mode, name, err)
+			}
+		}
+		if _, err := db.GetSupply(2); mode == PrunePruned && !errors.Is(err, ErrPruned) {
+			t.Fatalf("%s: supply at #2 returned %v, want ErrPruned", mode, err)
+		}
+	}
+}
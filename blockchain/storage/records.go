@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+
+	"vnc-blockchain/consensus"
+)
+
+// Record formats, stored under meta:record_format before the schema was
+// versioned. Schema version 1 introduced the binary format.
+const (
+	RecordFormatJSON   = 0 // JSON values, written before the format was recorded
+	RecordFormatBinary = 1 // canonical binary encoding of the consensus codec
+)
+
+// metaRecordFormat is the metadata key holding the record format
+const metaRecordFormat = "record_format"
+
+// migrationBatchSize is the number of records rewritten per batch
+const migrationBatchSize = 1000
+
+// record is a value stored with the canonical binary encoding
+type record interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// recordPrefixes lists the prefixes holding typed records and how to
+// allocate a value for each
+var recordPrefixes = []struct {
+	prefix string
+	kind   string
+	alloc  func() record
+}{
+	{PrefixBlock, "block", func() record { return new(consensus.Block) }},
+	{PrefixTransaction, "transaction", func() record { return new(consensus.Transaction) }},
+	{PrefixReceipt, "receipt", func() record { return new(consensus.Receipt) }},
+	{PrefixValidator, "validator", func() record { return new(consensus.Validator) }},
+	{PrefixState, "account", func() record { return new(consensus.Account) }},
+	{PrefixSupply, "supply", func() record { return new(consensus.SupplyInfo) }},
+}
+
+// putRecord stores a record under key
+func (db *BlockchainDB) putRecord(key string, value record, kind string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	data, err := value.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", kind, err)
+	}
+	err = db.store.Put([]byte(key), data)
+	db.cache.invalidate([]byte(key))
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", kind, err)
+	}
+	return nil
+}
+
+// getRecord loads the record under key into value
+func (db *BlockchainDB) getRecord(key string, value record, kind string) error {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	_, err := db.loadRecord(key, value, kind)
+	return err
+}
+
+// loadRecord loads the record under key into value and returns its encoded
+// size. Caller must hold the read lock.
+func (db *BlockchainDB) loadRecord(key string, value record, kind string) (int, error) {
+	data, err := db.store.Get([]byte(key))
+	if err != nil {
+		return 0, fmt.Errorf("%s not found: %w", kind, err)
+	}
+	if err := decodeRecord(data, value); err != nil {
+		return 0, fmt.Errorf("failed to decode %s: %w", kind, err)
+	}
+	return len(data), nil
+}
+
+// decodeRecord decodes a stored value. Values still in the legacy JSON
+// format, as in an unmigrated database opened read-only, are accepted.
+func decodeRecord(data []byte, value record) error {
+	if isLegacyJSON(data) {
+		return json.Unmarshal(data, value)
+	}
+	return value.UnmarshalBinary(data)
+}
+
+// isLegacyJSON reports whether a value was written as a JSON object.
+// Binary records start with the codec version byte, never '{'.
+func isLegacyJSON(data []byte) bool {
+	return len(data) > 0 && data[0] == '{'
+}
+
+// migrateJSONRecords rewrites legacy JSON records in the binary format.
+// Records that cannot be decoded are left as they are and reported; reads
+// still accept them.
+func (db *BlockchainDB) migrateJSONRecords() error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for _, p := range recordPrefixes {
+		migrated, skipped := 0, 0
+		batch := new(Batch)
+
+		iter := db.store.NewIterator(PrefixRange([]byte(p.prefix)))
+		for iter.Next() {
+			if !isLegacyJSON(iter.Value()) {
+				continue
+			}
+			value := p.alloc()
+			if err := json.Unmarshal(iter.Value(), value); err != nil {
+				fmt.Printf("⚠️  Leaving unreadable %s record %s as JSON: %v\n", p.kind, iter.Key(), err)
+				skipped++
+				continue
+			}
+			data, err := value.MarshalBinary()
+			if err != nil {
+				iter.Release()
+				return fmt.Errorf("failed to encode %s record %s: %w", p.kind, iter.Key(), err)
+			}
+			batch.Put(iter.Key(), data)
+			migrated++
+
+			if batch.Len() >= migrationBatchSize {
+				if err := db.write(batch, false); err != nil {
+					iter.Release()
+					return fmt.Errorf("failed to write migrated %s records: %w", p.kind, err)
+				}
+				batch.Reset()
+				fmt.Printf("   %s: %d records migrated\n", p.kind, migrated)
+			}
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return fmt.Errorf("failed to read %s records: %w", p.kind, err)
+		}
+		if err := db.write(batch, false); err != nil {
+			return fmt.Errorf("failed to write migrated %s records: %w", p.kind, err)
+		}
+		if migrated > 0 || skipped > 0 {
+			fmt.Printf("   %s: %d records migrated, %d left as JSON\n", p.kind, migrated, skipped)
+		}
+	}
+	return nil
+}
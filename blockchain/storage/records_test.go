@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"vnc-blockchain/consensus"
+)
+
+// legacyStore returns a store holding records in the JSON format written
+// before the binary encoding, and one JSON record that cannot be decoded
+func legacyStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	store := NewMemoryStore()
+	records := map[string]interface{}{
+		PrefixState + "0xsender":   &consensus.Account{Address: "0xsender", Balance: big.NewInt(500), Nonce: 2},
+		PrefixTransaction + "0xtx": &consensus.Transaction{Hash: "0xtx", From: "0xsender", Value: big.NewInt(5)},
+	}
+	for key, value := range records {
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Put([]byte(key), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Put([]byte(PrefixState+"0xbroken"), []byte(`{"balance":`)); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestLegacyJSONRecordsMigrated(t *testing.T) {
+	store := legacyStore(t)
+	db, err := NewBlockchainDBWithStore(store, Config{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unmigrated records are still readable
+	if tx, err := db.GetTransaction("0xtx"); err != nil || tx.Value.Int64() != 5 {
+		t.Fatalf("legacy transaction read as %+v (%v)", tx, err)
+	}
+
+	if err := db.migrateJSONRecords(); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{PrefixState + "0xsender", PrefixTransaction + "0xtx"} {
+		data, _ := store.Get([]byte(key))
+		if len(data) == 0 || data[0] != consensus.CodecVersion {
+			t.Fatalf("%s not rewritten in the binary format: %q", key, data)
+		}
+	}
+	if account, err := db.GetAccount("0xsender"); err != nil || account.Balance.Int64() != 500 || account.Nonce != 2 {
+		t.Fatalf("migrated account read as %+v (%v)", account, err)
+	}
+
+	// A record that cannot be decoded is left as it was
+	if data, _ := store.Get([]byte(PrefixState + "0xbroken")); string(data) != `{"balance":` {
+		t.Fatalf("unreadable record rewritten as %q", data)
+	}
+}
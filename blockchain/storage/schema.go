@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// CurrentSchemaVersion is the schema this build writes. Opening a database
+// with an older schema runs the migrations from its version up to this one;
+// a newer schema is refused.
+const CurrentSchemaVersion = 3
+
+// metaSchemaVersion is the metadata key holding the schema version
+const metaSchemaVersion = "schema_version"
+
+// migration upgrades the schema from version-1 to version. Migrations must
+// be safe to rerun, as a crash can interrupt one before its version is
+// recorded.
+type migration struct {
+	version     int
+	description string
+	migrate     func(db *BlockchainDB) error
+}
+
+// migrations lists every schema upgrade in order; migrations[i] upgrades to
+// version i+1
+var migrations = []migration{
+	{1, "binary record encoding", (*BlockchainDB).migrateJSONRecords},
+	{2, "block, transaction and address indexes", (*BlockchainDB).migrateIndexes},
+	{3, "account state history", (*BlockchainDB).migrateStateHistory},
+}
+
+// SchemaVersion returns the schema version of the stored data
+func (db *BlockchainDB) SchemaVersion() (int, error) {
+	var version int
+	found, err := db.getMetadataInto(metaSchemaVersion, &version)
+	if err != nil || found {
+		return version, err
+	}
+	if db.isEmpty() {
+		return CurrentSchemaVersion, nil
+	}
+
+	// Databases written before the schema was versioned recorded only the
+	// record format, which the first migration introduced
+	format := RecordFormatJSON
+	if _, err := db.getMetadataInto(metaRecordFormat, &format); err != nil {
+		return 0, err
+	}
+	if format >= RecordFormatBinary {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// checkSchema refuses schemas newer than this build understands and, when
+// writable, migrates older ones to the current version. Each migration's
+// version is recorded as it completes, so an interrupted upgrade resumes.
+func (db *BlockchainDB) checkSchema(writable bool) error {
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > CurrentSchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than supported version %d; upgrade the node",
+			version, CurrentSchemaVersion)
+	}
+	if !writable {
+		return nil
+	}
+
+	if version < CurrentSchemaVersion {
+		fmt.Printf("🔄 Migrating database schema from v%d to v%d\n", version, CurrentSchemaVersion)
+	}
+	for _, m := range migrations[version:] {
+		start := time.Now()
+		fmt.Printf("   v%d: %s...\n", m.version, m.description)
+		if err := m.migrate(db); err != nil {
+			return fmt.Errorf("schema migration to v%d failed: %w", m.version, err)
+		}
+		if err := db.SaveMetadata(metaSchemaVersion, m.version); err != nil {
+			return err
+		}
+		fmt.Printf("   v%d: done in %v\n", m.version, time.Since(start).Round(time.Millisecond))
+	}
+	if version == CurrentSchemaVersion {
+		// Record the version of new databases
+		return db.SaveMetadata(metaSchemaVersion, CurrentSchemaVersion)
+	}
+	return nil
+}
+
+// isEmpty reports whether the database holds no typed records
+func (db *BlockchainDB) isEmpty() bool {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	for _, p := range recordPrefixes {
+		iter := db.store.NewIterator(PrefixRange([]byte(p.prefix)))
+		found := iter.Next()
+		iter.Release()
+		if found {
+			return false
+		}
+	}
+	return true
+}
+
+// migrateIndexes builds the secondary indexes of databases written before
+// they were maintained
+func (db *BlockchainDB) migrateIndexes() error {
+	indexed, err := db.HasIndexes()
+	if err != nil || indexed {
+		return err
+	}
+	_, err = db.RebuildIndexes(func(height uint64) {
+		fmt.Printf("   indexed through block #%d\n", height)
+	})
+	return err
+}
+
+// migrateStateHistory seeds the state history with the current value of
+// every account that has none, as the value at the head. History before the
+// head was never recorded, so if none existed it is marked pruned.
+func (db *BlockchainDB) migrateStateHistory() error {
+	head, err := db.GetLatestBlockNumber()
+	if err != nil {
+		return err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	iter := db.store.NewIterator(PrefixRange([]byte(PrefixStateHistory)))
+	hadHistory := iter.Next()
+	iter.Release()
+
+	seeded := 0
+	batch := new(Batch)
+	iter = db.store.NewIterator(PrefixRange([]byte(PrefixState)))
+	for iter.Next() {
+		address := string(iter.Key()[len(PrefixState):])
+		if hadHistory {
+			history := db.store.NewIterator(PrefixRange(stateHistoryPrefix(address)))
+			found := history.Next()
+			history.Release()
+			if found {
+				continue
+			}
+		}
+		batch.Put(stateHistoryKey(address, head), iter.Value())
+		seeded++
+
+		if batch.Len() >= migrationBatchSize {
+			if err := db.write(batch, false); err != nil {
+				iter.Release()
+				return fmt.Errorf("failed to write state history: %w", err)
+			}
+			batch.Reset()
+			fmt.Printf("   %d accounts seeded\n", seeded)
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("failed to read state: %w", err)
+	}
+
+	markPruned := !hadHistory && head > db.stateFloor.Load()
+	if markPruned {
+		if err := putBatchMetadata(batch, metaPrunedStateBelow, head); err != nil {
+			return err
+		}
+	}
+	if err := db.write(batch, true); err != nil {
+		return fmt.Errorf("failed to write state history: %w", err)
+	}
+	if markPruned {
+		db.stateFloor.Store(head)
+	}
+	if seeded > 0 {
+		fmt.Printf("   %d accounts seeded at block #%d\n", seeded, head)
+	}
+	return nil
+}
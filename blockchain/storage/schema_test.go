@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"vnc-blockchain/consensus"
+)
+
+// legacyChainStore is legacyStore with block 1, holding the legacy
+// transaction, stored as JSON and recorded as the head
+func legacyChainStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	store := legacyStore(t)
+	block := &consensus.Block{
+		Number:       1,
+		Hash:         "0xblock1",
+		PreviousHash: consensus.GenesisHeader().Hash,
+		Transactions: []*consensus.Transaction{{Hash: "0xtx", From: "0xsender"}},
+	}
+	data, err := json.Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put([]byte(PrefixBlock+"1"), data); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put([]byte(PrefixMetadata+metaLatestBlock), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestMigrationsInOrder(t *testing.T) {
+	if len(migrations) != CurrentSchemaVersion {
+		t.Fatalf("%d migrations for schema version %d", len(migrations), CurrentSchemaVersion)
+	}
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Fatalf("migrations[%d] upgrades to v%d, want v%d", i, m.version, i+1)
+		}
+	}
+}
+
+func TestUnversionedDatabaseMigrated(t *testing.T) {
+	store := legacyChainStore(t)
+
+	// Read-only opens leave the schema as it is
+	db, err := NewBlockchainDBWithStore(store, Config{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := db.SchemaVersion(); err != nil || version != 0 {
+		t.Fatalf("read-only open: schema v%d (%v), want v0", version, err)
+	}
+
+	db, err = NewBlockchainDBWithStore(store, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := db.SchemaVersion(); err != nil || version != CurrentSchemaVersion {
+		t.Fatalf("schema v%d (%v) after migrating, want v%d", version, err, CurrentSchemaVersion)
+	}
+
+	// Each migration ran on the output of the one before: the indexes and
+	// state history were built from the binary records
+	if data, _ := store.Get([]byte(PrefixBlock + "1")); data[0] != consensus.CodecVersion {
+		t.Fatalf("block not migrated to the binary format: %q", data)
+	}
+	if block, err := db.GetBlockByHash("0xblock1"); err != nil || block.Number != 1 {
+		t.Fatalf("block by hash after migrating: %+v (%v)", block, err)
+	}
+	if location, err := db.GetTxLocation("0xtx"); err != nil || location.BlockNumber != 1 {
+		t.Fatalf("transaction location after migrating: %+v (%v)", location, err)
+	}
+	if account, err := db.GetAccountAt("0xsender", 1); err != nil || account.Balance.Int64() != 500 {
+		t.Fatalf("state history after migrating: %+v (%v)", account, err)
+	}
+}
+
+func TestMigrationResumesFromRecordedVersion(t *testing.T) {
+	store := legacyChainStore(t)
+	if err := store.Put([]byte(PrefixMetadata+metaSchemaVersion), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewBlockchainDBWithStore(store, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the migration to v3 runs; records stay as they are
+	if data, _ := store.Get([]byte(PrefixBlock + "1")); data[0] != '{' {
+		t.Fatalf("migration before the recorded version rerun: block is %q", data)
+	}
+	if account, err := db.GetAccountAt("0xsender", 1); err != nil || account.Balance.Int64() != 500 {
+		t.Fatalf("state history not seeded: %+v (%v)", account, err)
+	}
+	if version, _ := db.SchemaVersion(); version != CurrentSchemaVersion {
+		t.Fatalf("schema v%d, want v%d", version, CurrentSchemaVersion)
+	}
+}
+
+func TestNewerSchemaRefused(t *testing.T) {
+	store := legacyChainStore(t)
+	if err := store.Put([]byte(PrefixMetadata+metaSchemaVersion), []byte("99")); err != nil {
+		t.Fatal(err)
+	}
+	for _, readOnly := range []bool{false, true} {
+		_, err := NewBlockchainDBWithStore(store, Config{ReadOnly: readOnly})
+		if err == nil || !strings.Contains(err.Error(), "newer than supported") {
+			t.Fatalf("read-only %v: opened schema v99 (%v)", readOnly, err)
+		}
+	}
+}
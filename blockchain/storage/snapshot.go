@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"vnc-blockchain/consensus"
+)
+
+// metaSnapshotBase is the metadata key of the checkpoint replay starts
+// from: the one a snapshot import started the chain from, or the one
+// pruning last moved it to
+const metaSnapshotBase = "snapshot_base"
+
+// PrefixCheckpoint holds replay checkpoints by height. A pruned database
+// keeps them so that, once it deletes the blocks below one, that checkpoint
+// becomes the snapshot base.
+const PrefixCheckpoint = "checkpoint:"
+
+// checkpointsRetained is the number of checkpoints saved per retained range
+const checkpointsRetained = 4
+
+// AdoptSnapshot makes an imported snapshot the base of an empty database.
+// The headers are stored as blocks without transactions, the accounts as
+// the state at the snapshot height, and everything below is marked pruned.
+func (db *BlockchainDB) AdoptSnapshot(base *consensus.ReplayCheckpoint, headers []*consensus.Block, accounts []*consensus.Account) error {
+	head, err := db.GetLatestBlockNumber()
+	if err != nil {
+		return err
+	}
+	if head != 0 {
+		return fmt.Errorf("database already holds blocks up to #%d", head)
+	}
+	if len(headers) == 0 || headers[len(headers)-1].Number != base.Height {
+		return fmt.Errorf("headers do not end at the snapshot height #%d", base.Height)
+	}
+
+	batch := new(Batch)
+	for _, header := range headers {
+		if err := putBatchRecord(batch, fmt.Sprintf("%s%d", PrefixBlock, header.Number), header); err != nil {
+			return err
+		}
+		indexBlock(batch, header)
+	}
+	for _, account := range accounts {
+		if err := putBatchRecord(batch, fmt.Sprintf("%s%s", PrefixState, account.Address), account); err != nil {
+			return err
+		}
+		if err := putBatchRecord(batch, string(stateHistoryKey(account.Address, base.Height)), account); err != nil {
+			return err
+		}
+	}
+	for _, validator := range base.Validators {
+		if err := putBatchRecord(batch, fmt.Sprintf("%s%s", PrefixValidator, validator.Address), validator); err != nil {
+			return err
+		}
+	}
+	checkpoint, err := json.Marshal(base)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot base: %w", err)
+	}
+	batch.Put([]byte(PrefixMetadata+metaSnapshotBase), checkpoint)
+	blockFloor := headers[0].Number
+	for _, meta := range []struct {
+		key   string
+		value uint64
+	}{
+		{metaPrunedBlocksBelow, blockFloor},
+		{metaPrunedStateBelow, base.Height},
+		{metaLatestBlock, base.Height},
+	} {
+		if err := putBatchMetadata(batch, meta.key, meta.value); err != nil {
+			return err
+		}
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if err := db.write(batch, true); err != nil {
+		return err
+	}
+	db.blockFloor.Store(blockFloor)
+	db.stateFloor.Store(base.Height)
+	return nil
+}
+
+// GetSnapshotBase returns the checkpoint replay starts from, or nil if the
+// chain replays from genesis
+func (db *BlockchainDB) GetSnapshotBase() (*consensus.ReplayCheckpoint, error) {
+	var base consensus.ReplayCheckpoint
+	found, err := db.getMetadataInto(metaSnapshotBase, &base)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &base, nil
+}
+
+// CheckpointInterval returns the number of heights between the replay
+// checkpoints the database keeps, or 0 if it keeps none. Only pruned mode
+// needs them, as it deletes the blocks replay would start from.
+func (db *BlockchainDB) CheckpointInterval() uint64 {
+	if db.pruner == nil || db.pruner.mode != PrunePruned {
+		return 0
+	}
+	if interval := db.pruner.retain / checkpointsRetained; interval > 0 {
+		return interval
+	}
+	return 1
+}
+
+// SaveCheckpoint stores a replay checkpoint of a committed block
+func (db *BlockchainDB) SaveCheckpoint(checkpoint *consensus.ReplayCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	batch := new(Batch)
+	batch.Put(checkpointKey(checkpoint.Height), data)
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return db.write(batch, true)
+}
+
+// nextSnapshotBase returns the checkpoint replay can start from once the
+// blocks below keepFrom are pruned: the newest saved checkpoint at or below
+// keepFrom that matches its stored block, or the current base if that is
+// newer. It returns nil if there is none. The keys of saved checkpoints the
+// base supersedes are returned with it.
+func (db *BlockchainDB) nextSnapshotBase(keepFrom uint64) (*consensus.ReplayCheckpoint, [][]byte, error) {
+	base, err := db.GetSnapshotBase()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type saved struct {
+		key    []byte
+		height uint64
+	}
+	var checkpoints []saved
+	db.mutex.RLock()
+	iter := db.store.NewIterator(PrefixRange([]byte(PrefixCheckpoint)))
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != len(PrefixCheckpoint)+8 {
+			continue
+		}
+		checkpoints = append(checkpoints, saved{
+			key:    append([]byte(nil), key...),
+			height: binary.BigEndian.Uint64(key[len(PrefixCheckpoint):]),
+		})
+	}
+	iter.Release()
+	err = iter.Error()
+	db.mutex.RUnlock()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+
+	// Keys are in height order; try the newest usable checkpoint first
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		height := checkpoints[i].height
+		if height > keepFrom || (base != nil && height <= base.Height) {
+			continue
+		}
+		data, err := db.store.Get(checkpoints[i].key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read checkpoint #%d: %w", height, err)
+		}
+		checkpoint := new(consensus.ReplayCheckpoint)
+		if err := json.Unmarshal(data, checkpoint); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal checkpoint #%d: %w", height, err)
+		}
+		// A checkpoint of a block since rewound by a repair is not used
+		if block, err := db.scanBlock(height); err != nil || block.Hash != checkpoint.BlockHash {
+			continue
+		}
+		base = checkpoint
+		break
+	}
+	if base == nil {
+		return nil, nil, nil
+	}
+
+	var superseded [][]byte
+	for _, checkpoint := range checkpoints {
+		if checkpoint.height <= base.Height {
+			superseded = append(superseded, checkpoint.key)
+		}
+	}
+	return base, superseded, nil
+}
+
+// checkpointKey is the key of the checkpoint at height
+func checkpointKey(height uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte(PrefixCheckpoint), height)
+}
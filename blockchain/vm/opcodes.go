@@ -0,0 +1,167 @@
+package vm
+
+// OpCode is a single VM instruction. Opcode values and gas costs follow
+// the EVM so existing tooling can target this interpreter, but only the
+// self-contained subset below is supported: there are no calls into other
+// contracts, no CREATE and no self-destruct.
+type OpCode byte
+
+const (
+	STOP OpCode = 0x00
+	ADD  OpCode = 0x01
+	MUL  OpCode = 0x02
+	SUB  OpCode = 0x03
+	DIV  OpCode = 0x04
+	MOD  OpCode = 0x06
+	EXP  OpCode = 0x0a
+
+	LT     OpCode = 0x10
+	GT     OpCode = 0x11
+	EQ     OpCode = 0x14
+	ISZERO OpCode = 0x15
+	AND    OpCode = 0x16
+	OR     OpCode = 0x17
+	XOR    OpCode = 0x18
+	NOT    OpCode = 0x19
+	BYTE   OpCode = 0x1a
+	SHL    OpCode = 0x1b
+	SHR    OpCode = 0x1c
+
+	SHA3 OpCode = 0x20
+
+	ADDRESS      OpCode = 0x30
+	CALLER       OpCode = 0x33
+	CALLVALUE    OpCode = 0x34
+	CALLDATALOAD OpCode = 0x35
+	CALLDATASIZE OpCode = 0x36
+	CALLDATACOPY OpCode = 0x37
+	CODESIZE     OpCode = 0x38
+	CODECOPY     OpCode = 0x39
+
+	TIMESTAMP OpCode = 0x42
+	NUMBER    OpCode = 0x43
+
+	POP      OpCode = 0x50
+	MLOAD    OpCode = 0x51
+	MSTORE   OpCode = 0x52
+	MSTORE8  OpCode = 0x53
+	SLOAD    OpCode = 0x54
+	SSTORE   OpCode = 0x55
+	JUMP     OpCode = 0x56
+	JUMPI    OpCode = 0x57
+	PC       OpCode = 0x58
+	MSIZE    OpCode = 0x59
+	GAS      OpCode = 0x5a
+	JUMPDEST OpCode = 0x5b
+
+	PUSH1  OpCode = 0x60
+	PUSH32 OpCode = 0x7f
+	DUP1   OpCode = 0x80
+	DUP16  OpCode = 0x8f
+	SWAP1  OpCode = 0x90
+	SWAP16 OpCode = 0x9f
+	LOG0   OpCode = 0xa0
+	LOG4   OpCode = 0xa4
+
+	RETURN  OpCode = 0xf3
+	REVERT  OpCode = 0xfd
+	INVALID OpCode = 0xfe
+)
+
+// Gas costs
+const (
+	GasZero        uint64 = 0
+	GasJumpDest    uint64 = 1
+	GasBase        uint64 = 2
+	GasVeryLow     uint64 = 3
+	GasLow         uint64 = 5
+	GasMid         uint64 = 8
+	GasHigh        uint64 = 10
+	GasExpByte     uint64 = 50
+	GasSha3        uint64 = 30
+	GasSha3Word    uint64 = 6
+	GasCopyWord    uint64 = 3
+	GasSload       uint64 = 200
+	GasSstoreSet   uint64 = 20000
+	GasSstoreReset uint64 = 5000
+	GasLog         uint64 = 375
+	GasLogTopic    uint64 = 375
+	GasLogByte     uint64 = 8
+	GasMemoryWord  uint64 = 3
+	GasQuadDivisor uint64 = 512
+	GasCodeDeposit uint64 = 200
+	GasTxCall      uint64 = 21000
+	GasTxDeploy    uint64 = 53000
+	GasTxDataByte  uint64 = 16
+	MaxStackDepth         = 1024
+	MaxMemorySize         = 1 << 20 // 1 MiB per execution
+	MaxCodeSize           = 24576
+)
+
+// staticGas is the fixed cost of each supported opcode. Opcodes missing
+// from the table are invalid.
+var staticGas = map[OpCode]uint64{
+	STOP: GasZero,
+	ADD:  GasVeryLow,
+	MUL:  GasLow,
+	SUB:  GasVeryLow,
+	DIV:  GasLow,
+	MOD:  GasLow,
+	EXP:  GasHigh,
+
+	LT:     GasVeryLow,
+	GT:     GasVeryLow,
+	EQ:     GasVeryLow,
+	ISZERO: GasVeryLow,
+	AND:    GasVeryLow,
+	OR:     GasVeryLow,
+	XOR:    GasVeryLow,
+	NOT:    GasVeryLow,
+	BYTE:   GasVeryLow,
+	SHL:    GasVeryLow,
+	SHR:    GasVeryLow,
+
+	SHA3: GasSha3,
+
+	ADDRESS:      GasBase,
+	CALLER:       GasBase,
+	CALLVALUE:    GasBase,
+	CALLDATALOAD: GasVeryLow,
+	CALLDATASIZE: GasBase,
+	CALLDATACOPY: GasVeryLow,
+	CODESIZE:     GasBase,
+	CODECOPY:     GasVeryLow,
+
+	TIMESTAMP: GasBase,
+	NUMBER:    GasBase,
+
+	POP:      GasBase,
+	MLOAD:    GasVeryLow,
+	MSTORE:   GasVeryLow,
+	MSTORE8:  GasVeryLow,
+	SLOAD:    GasSload,
+	SSTORE:   GasZero, // charged dynamically
+	JUMP:     GasMid,
+	JUMPI:    GasHigh,
+	PC:       GasBase,
+	MSIZE:    GasBase,
+	GAS:      GasBase,
+	JUMPDEST: GasJumpDest,
+
+	RETURN:  GasZero,
+	REVERT:  GasZero,
+	INVALID: GasZero,
+}
+
+// opGas returns the static gas of an opcode, including the PUSH, DUP,
+// SWAP and LOG ranges
+func opGas(op OpCode) (uint64, bool) {
+	switch {
+	case op >= PUSH1 && op <= PUSH32, op >= DUP1 && op <= DUP16, op >= SWAP1 && op <= SWAP16:
+		return GasVeryLow, true
+	case op >= LOG0 && op <= LOG4:
+		return GasLog + uint64(op-LOG0)*GasLogTopic, true
+	}
+	gas, ok := staticGas[op]
+	return gas, ok
+}
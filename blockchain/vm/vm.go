@@ -0,0 +1,592 @@
+package vm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Word is a 256-bit storage key or value
+type Word [32]byte
+
+// Execution errors
+var (
+	ErrOutOfGas          = errors.New("out of gas")
+	ErrStackUnderflow    = errors.New("stack underflow")
+	ErrStackOverflow     = errors.New("stack overflow")
+	ErrInvalidJump       = errors.New("invalid jump destination")
+	ErrInvalidOpCode     = errors.New("invalid opcode")
+	ErrMemoryLimit       = errors.New("memory limit exceeded")
+	ErrExecutionReverted = errors.New("execution reverted")
+	ErrCodeSizeExceeded  = errors.New("contract code size exceeded")
+)
+
+var (
+	tt256   = new(big.Int).Lsh(big.NewInt(1), 256)
+	tt256m1 = new(big.Int).Sub(tt256, big.NewInt(1))
+)
+
+// StateReader is the read-only view of chain state available to contracts.
+// Writes are buffered in the Result and applied by the caller on success.
+type StateReader interface {
+	GetStorage(address string, key Word) Word
+}
+
+// Context describes a single contract execution
+type Context struct {
+	Address     string   // executing contract
+	Caller      string   // transaction sender
+	Value       *big.Int // value sent with the call
+	Input       []byte   // call data
+	Code        []byte   // code being executed (init code on deploy)
+	Gas         uint64   // gas available for execution
+	BlockNumber uint64
+	Timestamp   int64
+}
+
+// Log is an event emitted by LOG0..LOG4
+type Log struct {
+	Address string
+	Topics  []Word
+	Data    []byte
+}
+
+// Result is the outcome of an execution
+type Result struct {
+	ReturnData    []byte
+	GasUsed       uint64
+	Logs          []Log
+	StorageWrites map[Word]Word // final values of written slots
+	WriteOrder    []Word        // first-write order for deterministic commits
+	Err           error
+}
+
+// Failed reports whether execution ended in an error or revert
+func (r *Result) Failed() bool {
+	return r.Err != nil
+}
+
+// interpreter holds the machine state of one execution
+type interpreter struct {
+	ctx       *Context
+	state     StateReader
+	stack     []*big.Int
+	memory    []byte
+	gas       uint64
+	jumpdests map[uint64]bool
+	result    *Result
+}
+
+// Execute runs contract code in a sandbox. The interpreter only sees the
+// Context and StateReader, is fully deterministic and charges gas for every
+// instruction; it never touches state directly.
+func Execute(ctx *Context, state StateReader) *Result {
+	in := &interpreter{
+		ctx:       ctx,
+		state:     state,
+		gas:       ctx.Gas,
+		jumpdests: analyzeJumpdests(ctx.Code),
+		result: &Result{
+			StorageWrites: make(map[Word]Word),
+		},
+	}
+
+	ret, err := in.run()
+	in.result.GasUsed = ctx.Gas - in.gas
+	in.result.ReturnData = ret
+	if err != nil {
+		// Discard side effects of failed executions
+		in.result.Err = err
+		in.result.Logs = nil
+		in.result.StorageWrites = make(map[Word]Word)
+		in.result.WriteOrder = nil
+		if err != ErrExecutionReverted {
+			in.result.GasUsed = ctx.Gas // Exceptional halts consume all gas
+		}
+	}
+	return in.result
+}
+
+// analyzeJumpdests finds valid JUMPDEST positions, skipping PUSH data
+func analyzeJumpdests(code []byte) map[uint64]bool {
+	dests := make(map[uint64]bool)
+	for pc := 0; pc < len(code); pc++ {
+		op := OpCode(code[pc])
+		if op == JUMPDEST {
+			dests[uint64(pc)] = true
+		} else if op >= PUSH1 && op <= PUSH32 {
+			pc += int(op-PUSH1) + 1
+		}
+	}
+	return dests
+}
+
+func (in *interpreter) useGas(amount uint64) error {
+	if in.gas < amount {
+		in.gas = 0
+		return ErrOutOfGas
+	}
+	in.gas -= amount
+	return nil
+}
+
+func (in *interpreter) push(v *big.Int) error {
+	if len(in.stack) >= MaxStackDepth {
+		return ErrStackOverflow
+	}
+	in.stack = append(in.stack, v)
+	return nil
+}
+
+func (in *interpreter) pop() (*big.Int, error) {
+	if len(in.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	v := in.stack[len(in.stack)-1]
+	in.stack = in.stack[:len(in.stack)-1]
+	return v, nil
+}
+
+func (in *interpreter) popN(n int) ([]*big.Int, error) {
+	if len(in.stack) < n {
+		return nil, ErrStackUnderflow
+	}
+	vals := make([]*big.Int, n)
+	for i := 0; i < n; i++ {
+		vals[i] = in.stack[len(in.stack)-1-i]
+	}
+	in.stack = in.stack[:len(in.stack)-n]
+	return vals, nil
+}
+
+// expandMemory grows memory to cover [offset, offset+size) and charges for it
+func (in *interpreter) expandMemory(offset, size *big.Int) (uint64, uint64, error) {
+	if size.Sign() == 0 {
+		return 0, 0, nil
+	}
+	if !offset.IsUint64() || !size.IsUint64() {
+		return 0, 0, ErrMemoryLimit
+	}
+	off, sz := offset.Uint64(), size.Uint64()
+	end := off + sz
+	if end < off || end > MaxMemorySize {
+		return 0, 0, ErrMemoryLimit
+	}
+
+	if end > uint64(len(in.memory)) {
+		newWords := (end + 31) / 32
+		oldWords := uint64(len(in.memory)) / 32
+		cost := memoryCost(newWords) - memoryCost(oldWords)
+		if err := in.useGas(cost); err != nil {
+			return 0, 0, err
+		}
+		in.memory = append(in.memory, make([]byte, newWords*32-uint64(len(in.memory)))...)
+	}
+	return off, sz, nil
+}
+
+func memoryCost(words uint64) uint64 {
+	return words*GasMemoryWord + words*words/GasQuadDivisor
+}
+
+func toWord(v *big.Int) Word {
+	var w Word
+	v.FillBytes(w[:])
+	return w
+}
+
+func fromWord(w Word) *big.Int {
+	return new(big.Int).SetBytes(w[:])
+}
+
+func u256(v *big.Int) *big.Int {
+	return v.And(v, tt256m1)
+}
+
+func boolWord(b bool) *big.Int {
+	if b {
+		return big.NewInt(1)
+	}
+	return big.NewInt(0)
+}
+
+// AddressToWord maps a chain address to a 256-bit word. Hex addresses are
+// decoded directly; other address formats are hashed.
+func AddressToWord(address string) Word {
+	var w Word
+	if raw, err := hex.DecodeString(strings.TrimPrefix(address, "0x")); err == nil && len(raw) <= 32 {
+		copy(w[32-len(raw):], raw)
+		return w
+	}
+	hash := sha256.Sum256([]byte(address))
+	copy(w[12:], hash[12:])
+	return w
+}
+
+// getData returns size bytes of data starting at offset, zero padded
+func getData(data []byte, offset, size uint64) []byte {
+	out := make([]byte, size)
+	if offset < uint64(len(data)) {
+		copy(out, data[offset:])
+	}
+	return out
+}
+
+func (in *interpreter) run() ([]byte, error) {
+	code := in.ctx.Code
+	var pc uint64
+
+	for pc < uint64(len(code)) {
+		op := OpCode(code[pc])
+		gas, ok := opGas(op)
+		if !ok || op == INVALID {
+			return nil, fmt.Errorf("%w: 0x%02x", ErrInvalidOpCode, byte(op))
+		}
+		if err := in.useGas(gas); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case op >= PUSH1 && op <= PUSH32:
+			n := uint64(op-PUSH1) + 1
+			if err := in.push(new(big.Int).SetBytes(getData(code, pc+1, n))); err != nil {
+				return nil, err
+			}
+			pc += n + 1
+			continue
+
+		case op >= DUP1 && op <= DUP16:
+			n := int(op-DUP1) + 1
+			if len(in.stack) < n {
+				return nil, ErrStackUnderflow
+			}
+			if err := in.push(new(big.Int).Set(in.stack[len(in.stack)-n])); err != nil {
+				return nil, err
+			}
+			pc++
+			continue
+
+		case op >= SWAP1 && op <= SWAP16:
+			n := int(op-SWAP1) + 1
+			if len(in.stack) <= n {
+				return nil, ErrStackUnderflow
+			}
+			top := len(in.stack) - 1
+			in.stack[top], in.stack[top-n] = in.stack[top-n], in.stack[top]
+			pc++
+			continue
+
+		case op >= LOG0 && op <= LOG4:
+			if err := in.opLog(int(op - LOG0)); err != nil {
+				return nil, err
+			}
+			pc++
+			continue
+		}
+
+		switch op {
+		case STOP:
+			return nil, nil
+
+		case ADD, MUL, SUB, DIV, MOD, EXP, LT, GT, EQ, AND, OR, XOR, BYTE, SHL, SHR:
+			args, err := in.popN(2)
+			if err != nil {
+				return nil, err
+			}
+			res, err := in.binaryOp(op, args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			in.push(res)
+
+		case ISZERO, NOT:
+			x, err := in.pop()
+			if err != nil {
+				return nil, err
+			}
+			if op == ISZERO {
+				in.push(boolWord(x.Sign() == 0))
+			} else {
+				in.push(new(big.Int).Xor(x, tt256m1))
+			}
+
+		case SHA3:
+			args, err := in.popN(2)
+			if err != nil {
+				return nil, err
+			}
+			off, sz, err := in.expandMemory(args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			if err := in.useGas(GasSha3Word * ((sz + 31) / 32)); err != nil {
+				return nil, err
+			}
+			h := sha3.NewLegacyKeccak256()
+			h.Write(in.memory[off : off+sz])
+			in.push(new(big.Int).SetBytes(h.Sum(nil)))
+
+		case ADDRESS:
+			w := AddressToWord(in.ctx.Address)
+			in.push(fromWord(w))
+		case CALLER:
+			w := AddressToWord(in.ctx.Caller)
+			in.push(fromWord(w))
+		case CALLVALUE:
+			in.push(new(big.Int).Set(in.ctx.Value))
+		case CALLDATASIZE:
+			in.push(new(big.Int).SetUint64(uint64(len(in.ctx.Input))))
+		case CODESIZE:
+			in.push(new(big.Int).SetUint64(uint64(len(code))))
+		case TIMESTAMP:
+			in.push(big.NewInt(in.ctx.Timestamp))
+		case NUMBER:
+			in.push(new(big.Int).SetUint64(in.ctx.BlockNumber))
+		case PC:
+			in.push(new(big.Int).SetUint64(pc))
+		case MSIZE:
+			in.push(new(big.Int).SetUint64(uint64(len(in.memory))))
+		case GAS:
+			in.push(new(big.Int).SetUint64(in.gas))
+
+		case CALLDATALOAD:
+			off, err := in.pop()
+			if err != nil {
+				return nil, err
+			}
+			var data []byte
+			if off.IsUint64() {
+				data = getData(in.ctx.Input, off.Uint64(), 32)
+			} else {
+				data = make([]byte, 32)
+			}
+			in.push(new(big.Int).SetBytes(data))
+
+		case CALLDATACOPY, CODECOPY:
+			args, err := in.popN(3)
+			if err != nil {
+				return nil, err
+			}
+			memOff, sz, err := in.expandMemory(args[0], args[2])
+			if err != nil {
+				return nil, err
+			}
+			if err := in.useGas(GasCopyWord * ((sz + 31) / 32)); err != nil {
+				return nil, err
+			}
+			src := in.ctx.Input
+			if op == CODECOPY {
+				src = code
+			}
+			var dataOff uint64 = ^uint64(0)
+			if args[1].IsUint64() {
+				dataOff = args[1].Uint64()
+			}
+			copy(in.memory[memOff:memOff+sz], getData(src, dataOff, sz))
+
+		case POP:
+			if _, err := in.pop(); err != nil {
+				return nil, err
+			}
+
+		case MLOAD:
+			off, err := in.pop()
+			if err != nil {
+				return nil, err
+			}
+			o, _, err := in.expandMemory(off, big.NewInt(32))
+			if err != nil {
+				return nil, err
+			}
+			in.push(new(big.Int).SetBytes(in.memory[o : o+32]))
+
+		case MSTORE, MSTORE8:
+			args, err := in.popN(2)
+			if err != nil {
+				return nil, err
+			}
+			size := int64(32)
+			if op == MSTORE8 {
+				size = 1
+			}
+			o, _, err := in.expandMemory(args[0], big.NewInt(size))
+			if err != nil {
+				return nil, err
+			}
+			if op == MSTORE8 {
+				in.memory[o] = byte(args[1].Uint64() & 0xff)
+			} else {
+				w := toWord(args[1])
+				copy(in.memory[o:o+32], w[:])
+			}
+
+		case SLOAD:
+			key, err := in.pop()
+			if err != nil {
+				return nil, err
+			}
+			in.push(fromWord(in.sload(toWord(key))))
+
+		case SSTORE:
+			args, err := in.popN(2)
+			if err != nil {
+				return nil, err
+			}
+			key, value := toWord(args[0]), toWord(args[1])
+			cost := GasSstoreReset
+			if in.sload(key) == (Word{}) && value != (Word{}) {
+				cost = GasSstoreSet
+			}
+			if err := in.useGas(cost); err != nil {
+				return nil, err
+			}
+			if _, exists := in.result.StorageWrites[key]; !exists {
+				in.result.WriteOrder = append(in.result.WriteOrder, key)
+			}
+			in.result.StorageWrites[key] = value
+
+		case JUMP:
+			dest, err := in.pop()
+			if err != nil {
+				return nil, err
+			}
+			if !dest.IsUint64() || !in.jumpdests[dest.Uint64()] {
+				return nil, ErrInvalidJump
+			}
+			pc = dest.Uint64()
+			continue
+
+		case JUMPI:
+			args, err := in.popN(2)
+			if err != nil {
+				return nil, err
+			}
+			if args[1].Sign() != 0 {
+				if !args[0].IsUint64() || !in.jumpdests[args[0].Uint64()] {
+					return nil, ErrInvalidJump
+				}
+				pc = args[0].Uint64()
+				continue
+			}
+
+		case JUMPDEST:
+			// no-op marker
+
+		case RETURN, REVERT:
+			args, err := in.popN(2)
+			if err != nil {
+				return nil, err
+			}
+			off, sz, err := in.expandMemory(args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			ret := make([]byte, sz)
+			copy(ret, in.memory[off:off+sz])
+			if op == REVERT {
+				return ret, ErrExecutionReverted
+			}
+			return ret, nil
+		}
+
+		pc++
+	}
+
+	return nil, nil
+}
+
+// sload reads a storage slot, preferring writes from this execution
+func (in *interpreter) sload(key Word) Word {
+	if value, exists := in.result.StorageWrites[key]; exists {
+		return value
+	}
+	return in.state.GetStorage(in.ctx.Address, key)
+}
+
+// binaryOp evaluates a two-operand opcode with 256-bit wraparound semantics
+func (in *interpreter) binaryOp(op OpCode, x, y *big.Int) (*big.Int, error) {
+	switch op {
+	case ADD:
+		return u256(new(big.Int).Add(x, y)), nil
+	case MUL:
+		return u256(new(big.Int).Mul(x, y)), nil
+	case SUB:
+		return u256(new(big.Int).Sub(x, y)), nil
+	case DIV:
+		if y.Sign() == 0 {
+			return big.NewInt(0), nil
+		}
+		return new(big.Int).Div(x, y), nil
+	case MOD:
+		if y.Sign() == 0 {
+			return big.NewInt(0), nil
+		}
+		return new(big.Int).Mod(x, y), nil
+	case EXP:
+		if err := in.useGas(GasExpByte * uint64((y.BitLen()+7)/8)); err != nil {
+			return nil, err
+		}
+		return new(big.Int).Exp(x, y, tt256), nil
+	case LT:
+		return boolWord(x.Cmp(y) < 0), nil
+	case GT:
+		return boolWord(x.Cmp(y) > 0), nil
+	case EQ:
+		return boolWord(x.Cmp(y) == 0), nil
+	case AND:
+		return new(big.Int).And(x, y), nil
+	case OR:
+		return new(big.Int).Or(x, y), nil
+	case XOR:
+		return new(big.Int).Xor(x, y), nil
+	case BYTE:
+		if !x.IsUint64() || x.Uint64() >= 32 {
+			return big.NewInt(0), nil
+		}
+		w := toWord(y)
+		return big.NewInt(int64(w[x.Uint64()])), nil
+	case SHL:
+		if !x.IsUint64() || x.Uint64() >= 256 {
+			return big.NewInt(0), nil
+		}
+		return u256(new(big.Int).Lsh(y, uint(x.Uint64()))), nil
+	case SHR:
+		if !x.IsUint64() || x.Uint64() >= 256 {
+			return big.NewInt(0), nil
+		}
+		return new(big.Int).Rsh(y, uint(x.Uint64())), nil
+	}
+	return nil, ErrInvalidOpCode
+}
+
+// opLog emits an event with n topics
+func (in *interpreter) opLog(n int) error {
+	args, err := in.popN(2 + n)
+	if err != nil {
+		return err
+	}
+	off, sz, err := in.expandMemory(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if err := in.useGas(GasLogByte * sz); err != nil {
+		return err
+	}
+
+	log := Log{
+		Address: in.ctx.Address,
+		Topics:  make([]Word, n),
+		Data:    make([]byte, sz),
+	}
+	for i := 0; i < n; i++ {
+		log.Topics[i] = toWord(args[2+i])
+	}
+	copy(log.Data, in.memory[off:off+sz])
+	in.result.Logs = append(in.result.Logs, log)
+	return nil
+}